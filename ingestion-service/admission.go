@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+)
+
+// admissionController caps the number of OTLP requests processed at once across the
+// HTTP and gRPC receivers and bounds how long each admitted request may take
+type admissionController struct {
+	slots          chan struct{}
+	queueTimeout   time.Duration
+	requestTimeout time.Duration
+	retryAfter     time.Duration
+	waitTime       metric.Float64Histogram
+	rejected       metric.Int64Counter
+}
+
+// newAdmissionController creates the admission controller for the configured limits and
+// registers its metrics
+func newAdmissionController(config *Config, tm *TelemetryManager) (*admissionController, error) {
+	ac := &admissionController{
+		slots:          make(chan struct{}, config.Performance.MaxConcurrentRequests),
+		queueTimeout:   config.Performance.AdmissionTimeout,
+		requestTimeout: config.Performance.RequestTimeout,
+		retryAfter:     config.Server.RetryAfter,
+	}
+
+	meter := tm.GetMeter()
+	var err error
+	ac.waitTime, err = meter.Float64Histogram("ingestion.admission.wait_time",
+		metric.WithDescription("Time OTLP requests waited for a free request slot"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admission wait time histogram: %w", err)
+	}
+	ac.rejected, err = meter.Int64Counter("ingestion.admission.rejected",
+		metric.WithDescription("OTLP requests rejected because every request slot was busy"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admission rejected counter: %w", err)
+	}
+	inFlight, err := meter.Int64ObservableGauge("ingestion.admission.in_flight",
+		metric.WithDescription("OTLP requests currently being processed"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admission in-flight gauge: %w", err)
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(inFlight, int64(len(ac.slots)))
+		return nil
+	}, inFlight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register admission metrics: %w", err)
+	}
+	return ac, nil
+}
+
+// admit waits up to the queue timeout for a request slot. On success it returns a context
+// carrying the request deadline and a release function that must be called when the
+// request completes.
+func (ac *admissionController) admit(ctx context.Context, protocol string) (context.Context, func(), bool) {
+	attrs := metric.WithAttributes(attribute.String("protocol", protocol))
+	start := time.Now()
+
+	timer := time.NewTimer(ac.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case ac.slots <- struct{}{}:
+	case <-timer.C:
+		ac.waitTime.Record(ctx, time.Since(start).Seconds(), attrs)
+		ac.rejected.Add(ctx, 1, attrs)
+		trace.SpanFromContext(ctx).AddEvent("admission.rejected")
+		return ctx, nil, false
+	case <-ctx.Done():
+		return ctx, nil, false
+	}
+	ac.waitTime.Record(ctx, time.Since(start).Seconds(), attrs)
+
+	ctx, cancel := context.WithTimeout(ctx, ac.requestTimeout)
+	return ctx, func() {
+		cancel()
+		<-ac.slots
+	}, true
+}
+
+// middleware applies admission control to an OTLP/HTTP handler, answering 429 with
+// Retry-After when no request slot frees up in time
+func (ac *admissionController) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, release, ok := ac.admit(r.Context(), "http")
+		if !ok {
+			contentType, supported := negotiateOTLPContentType(r.Header.Get("Content-Type"))
+			if !supported {
+				contentType = contentTypeJSON
+			}
+			setRetryAfter(w, ac.retryAfter)
+			writeOTLPHTTPError(w, contentType, http.StatusTooManyRequests, grpccodes.ResourceExhausted,
+				"too many concurrent requests")
+			return
+		}
+		defer release()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// unaryInterceptor applies admission control to OTLP/gRPC calls, answering
+// RESOURCE_EXHAUSTED with a retry delay when no request slot frees up in time
+func (ac *admissionController) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, release, ok := ac.admit(ctx, "grpc")
+	if !ok {
+		return nil, retryableStatus(grpccodes.ResourceExhausted, "too many concurrent requests", ac.retryAfter)
+	}
+	defer release()
+
+	return handler(ctx, req)
+}
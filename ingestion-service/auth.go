@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons reported on the ingestion.auth.failures metric
+const (
+	authFailureMissingCredentials = "missing_credentials"
+	authFailureInvalidAPIKey      = "invalid_api_key"
+	authFailureInvalidToken       = "invalid_token"
+	authFailureExpiredToken       = "expired_token"
+	authFailureMissingTenant      = "missing_tenant"
+)
+
+// authenticator checks the API key or bearer token of OTLP requests and resolves the
+// tenant it belongs to. API keys come from the config and a file of SHA-256 key hashes,
+// and bearer JWTs are verified against a local JWKS file; both files are reloaded when
+// they change.
+type authenticator struct {
+	config   AuthConfig
+	logger   *zap.Logger
+	jwt      jwtValidator
+	failures metric.Int64Counter
+
+	staticKeys map[[sha256.Size]byte]string
+	keys       atomic.Pointer[map[[sha256.Size]byte]string]
+	jwks       atomic.Pointer[jwksKeySet]
+	modTimes   map[string]time.Time
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// newAuthenticator loads the configured credentials and starts watching the key and JWKS
+// files. With authentication disabled every request is accepted without a tenant.
+func newAuthenticator(config *Config, tm *TelemetryManager, logger *zap.Logger) (*authenticator, error) {
+	a := &authenticator{
+		config: config.Auth,
+		logger: logger,
+		jwt: jwtValidator{
+			issuer:    config.Auth.JWT.Issuer,
+			audience:  config.Auth.JWT.Audience,
+			clockSkew: config.Auth.JWT.ClockSkew,
+		},
+		staticKeys: make(map[[sha256.Size]byte]string, len(config.Auth.APIKeys)),
+		stop:       make(chan struct{}),
+	}
+
+	var err error
+	a.failures, err = tm.GetMeter().Int64Counter("ingestion.auth.failures",
+		metric.WithDescription("OTLP requests rejected by authentication, by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auth failures counter: %w", err)
+	}
+	if !a.config.Enabled {
+		return a, nil
+	}
+
+	for _, key := range a.config.APIKeys {
+		a.staticKeys[sha256.Sum256([]byte(key.Key))] = key.Tenant
+	}
+	a.modTimes, err = a.fileModTimes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load auth credentials: %w", err)
+	}
+	if err := a.reloadKeys(); err != nil {
+		return nil, err
+	}
+	if a.config.JWT.Enabled {
+		if err := a.reloadJWKS(); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.config.KeyFile != "" || a.config.JWT.Enabled {
+		go a.watch()
+	}
+	return a, nil
+}
+
+// Close stops watching the credential files
+func (a *authenticator) Close() {
+	a.stopOnce.Do(func() { close(a.stop) })
+}
+
+// authenticate returns the tenant of an API key or bearer token, or the failure reason
+func (a *authenticator) authenticate(apiKey, bearer string) (string, string, error) {
+	switch {
+	case bearer != "" && a.config.JWT.Enabled && strings.Count(bearer, ".") == 2:
+		return a.authenticateToken(bearer)
+	case bearer != "" && a.config.BearerAPIKeys:
+		apiKey = bearer
+	case bearer != "":
+		return "", authFailureInvalidToken, errors.New("bearer token is not a JWT and bearer API keys are disabled")
+	case apiKey == "":
+		return "", authFailureMissingCredentials, errors.New("missing API key or bearer token")
+	}
+
+	tenant, ok := (*a.keys.Load())[sha256.Sum256([]byte(apiKey))]
+	if !ok {
+		return "", authFailureInvalidAPIKey, errors.New("invalid API key")
+	}
+	return tenant, "", nil
+}
+
+// authenticateToken validates a bearer JWT and returns the tenant in its tenant claim
+func (a *authenticator) authenticateToken(token string) (string, string, error) {
+	claims, err := a.jwt.validate(token, *a.jwks.Load(), time.Now())
+	if errors.Is(err, errTokenExpired) {
+		return "", authFailureExpiredToken, err
+	}
+	if err != nil {
+		return "", authFailureInvalidToken, err
+	}
+	tenant, _ := claims[a.config.JWT.TenantClaim].(string)
+	if tenant == "" {
+		return "", authFailureMissingTenant, fmt.Errorf("token has no %q claim", a.config.JWT.TenantClaim)
+	}
+	return tenant, "", nil
+}
+
+// authorize authenticates a request, recording failures, and returns ctx carrying the tenant
+func (a *authenticator) authorize(ctx context.Context, protocol, apiKey, bearer string) (context.Context, error) {
+	if !a.config.Enabled {
+		return ctx, nil
+	}
+	tenant, reason, err := a.authenticate(apiKey, bearer)
+	if err != nil {
+		a.failures.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("reason", reason),
+			attribute.String("protocol", protocol),
+		))
+		trace.SpanFromContext(ctx).AddEvent("auth.rejected", trace.WithAttributes(attribute.String("reason", reason)))
+		return ctx, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", tenant))
+	return contextWithTenant(ctx, tenant), nil
+}
+
+// middleware authenticates OTLP/HTTP requests, answering 401 when the credentials are
+// missing or invalid. Keys are read from X-API-Key or an Authorization bearer token.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.authorize(r.Context(), "http", r.Header.Get("X-API-Key"), bearerToken(r.Header.Get("Authorization")))
+		if err != nil {
+			contentType, supported := negotiateOTLPContentType(r.Header.Get("Content-Type"))
+			if !supported {
+				contentType = contentTypeJSON
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeOTLPHTTPError(w, contentType, http.StatusUnauthorized, grpccodes.Unauthenticated, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// unaryInterceptor authenticates OTLP/gRPC calls from the x-api-key or authorization
+// metadata, answering UNAUTHENTICATED when the credentials are missing or invalid
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var apiKey, bearer string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			apiKey = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			bearer = bearerToken(values[0])
+		}
+	}
+	ctx, err := a.authorize(ctx, "grpc", apiKey, bearer)
+	if err != nil {
+		return nil, status.Error(grpccodes.Unauthenticated, err.Error())
+	}
+
+	return handler(ctx, req)
+}
+
+// bearerToken returns the token of a "Bearer <token>" authorization value
+func bearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// watch polls the key and JWKS files and reloads whichever changed. A reload that fails
+// keeps the previous credentials and is retried on the next tick.
+func (a *authenticator) watch() {
+	ticker := time.NewTicker(a.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			modTimes, err := a.fileModTimes()
+			if err != nil {
+				a.logger.Warn("Failed to check auth credential files", zap.Error(err))
+				continue
+			}
+			if a.config.KeyFile != "" && !modTimes[a.config.KeyFile].Equal(a.modTimes[a.config.KeyFile]) {
+				if err := a.reloadKeys(); err != nil {
+					a.logger.Error("Failed to reload API key file, keeping the previous keys", zap.Error(err))
+				} else {
+					a.modTimes[a.config.KeyFile] = modTimes[a.config.KeyFile]
+					a.logger.Info("Reloaded API key file", zap.String("path", a.config.KeyFile))
+				}
+			}
+			if a.config.JWT.Enabled && !modTimes[a.config.JWT.JWKSFile].Equal(a.modTimes[a.config.JWT.JWKSFile]) {
+				if err := a.reloadJWKS(); err != nil {
+					a.logger.Error("Failed to reload JWKS file, keeping the previous keys", zap.Error(err))
+				} else {
+					a.modTimes[a.config.JWT.JWKSFile] = modTimes[a.config.JWT.JWKSFile]
+					a.logger.Info("Reloaded JWKS file", zap.String("path", a.config.JWT.JWKSFile))
+				}
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// fileModTimes returns the modification times of the key and JWKS files
+func (a *authenticator) fileModTimes() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 2)
+	paths := []string{a.config.KeyFile}
+	if a.config.JWT.Enabled {
+		paths = append(paths, a.config.JWT.JWKSFile)
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// reloadKeys rebuilds the API key table from the configured keys and the key file
+func (a *authenticator) reloadKeys() error {
+	keys := make(map[[sha256.Size]byte]string, len(a.staticKeys))
+	for hash, tenant := range a.staticKeys {
+		keys[hash] = tenant
+	}
+	if a.config.KeyFile != "" {
+		fileKeys, err := loadAPIKeyFile(a.config.KeyFile)
+		if err != nil {
+			return err
+		}
+		for hash, tenant := range fileKeys {
+			keys[hash] = tenant
+		}
+	}
+	a.keys.Store(&keys)
+	return nil
+}
+
+// reloadJWKS loads the signing keys of the JWKS file
+func (a *authenticator) reloadJWKS() error {
+	keys, err := loadJWKSFile(a.config.JWT.JWKSFile)
+	if err != nil {
+		return err
+	}
+	a.jwks.Store(&keys)
+	return nil
+}
+
+// loadAPIKeyFile reads a file of "<sha256 hex of key> <tenant>" lines; blank lines and
+// lines starting with # are ignored
+func loadAPIKeyFile(path string) (map[[sha256.Size]byte]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	keys := make(map[[sha256.Size]byte]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("failed to parse API key file line %d: expected a key hash and a tenant", line)
+		}
+		digest, err := hex.DecodeString(strings.TrimPrefix(fields[0], "sha256:"))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("failed to parse API key file line %d: invalid SHA-256 hash", line)
+		}
+		keys[[sha256.Size]byte(digest)] = fields[1]
+	}
+	return keys, nil
+}
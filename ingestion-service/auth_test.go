@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newTestAuthenticator returns an enabled authenticator with the given keys and JWKS,
+// without reading any files
+func newTestAuthenticator(config AuthConfig, keys map[string]string, jwks jwksKeySet) *authenticator {
+	config.Enabled = true
+	a := &authenticator{
+		config: config,
+		logger: zap.NewNop(),
+		jwt:    jwtValidator{clockSkew: time.Minute},
+		stop:   make(chan struct{}),
+	}
+	table := make(map[[sha256.Size]byte]string, len(keys))
+	for key, tenant := range keys {
+		table[sha256.Sum256([]byte(key))] = tenant
+	}
+	a.keys.Store(&table)
+	a.jwks.Store(&jwks)
+	return a
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := newTestJWTKeys(t)
+	exp := time.Now().Add(time.Hour).Unix()
+	token := signTestJWT(t, "ES256", "p256", keys.p256, map[string]interface{}{"exp": exp, "tenant": "team-jwt"})
+	noTenant := signTestJWT(t, "ES256", "p256", keys.p256, map[string]interface{}{"exp": exp})
+	expired := signTestJWT(t, "ES256", "p256", keys.p256, map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix(), "tenant": "team-jwt"})
+	apiKeys := map[string]string{"key-a": "team-a"}
+
+	tests := []struct {
+		name       string
+		config     AuthConfig
+		apiKey     string
+		bearer     string
+		wantTenant string
+		wantReason string
+	}{
+		{name: "API key header", apiKey: "key-a", wantTenant: "team-a"},
+		{name: "unknown API key", apiKey: "key-b", wantReason: authFailureInvalidAPIKey},
+		{name: "no credentials", wantReason: authFailureMissingCredentials},
+		{name: "bearer API key refused by default", bearer: "key-a", wantReason: authFailureInvalidToken},
+		{name: "bearer API key when enabled", config: AuthConfig{BearerAPIKeys: true}, bearer: "key-a", wantTenant: "team-a"},
+		{name: "unknown bearer API key when enabled", config: AuthConfig{BearerAPIKeys: true}, bearer: "key-b", wantReason: authFailureInvalidAPIKey},
+		{name: "bearer takes precedence over the API key header", config: AuthConfig{BearerAPIKeys: true}, apiKey: "key-b", bearer: "key-a", wantTenant: "team-a"},
+		{name: "JWT", config: AuthConfig{JWT: JWTAuthConfig{Enabled: true, TenantClaim: "tenant"}}, bearer: token, wantTenant: "team-jwt"},
+		{name: "JWT without tenant claim", config: AuthConfig{JWT: JWTAuthConfig{Enabled: true, TenantClaim: "tenant"}}, bearer: noTenant, wantReason: authFailureMissingTenant},
+		{name: "expired JWT", config: AuthConfig{JWT: JWTAuthConfig{Enabled: true, TenantClaim: "tenant"}}, bearer: expired, wantReason: authFailureExpiredToken},
+		{name: "tampered JWT", config: AuthConfig{JWT: JWTAuthConfig{Enabled: true, TenantClaim: "tenant"}}, bearer: token[:len(token)-4] + "AAAA", wantReason: authFailureInvalidToken},
+		{name: "JWT is not an API key", config: AuthConfig{BearerAPIKeys: true}, bearer: token, wantReason: authFailureInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuthenticator(tt.config, apiKeys, keys.keySet())
+			tenant, reason, err := a.authenticate(tt.apiKey, tt.bearer)
+			if tt.wantReason != "" {
+				if err == nil || reason != tt.wantReason {
+					t.Fatalf("authenticate() = %q, %q, %v; want reason %q", tenant, reason, err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil || tenant != tt.wantTenant {
+				t.Fatalf("authenticate() = %q, %q, %v; want tenant %q", tenant, reason, err, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		authorization string
+		want          string
+	}{
+		{authorization: "Bearer abc", want: "abc"},
+		{authorization: "bearer  abc ", want: "abc"},
+		{authorization: "Basic abc", want: ""},
+		{authorization: "abc", want: ""},
+		{authorization: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := bearerToken(tt.authorization); got != tt.want {
+			t.Errorf("bearerToken(%q) = %q, want %q", tt.authorization, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAPIKeyFile(t *testing.T) {
+	hash := sha256.Sum256([]byte("key-a"))
+	digest := hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "hash and tenant", content: digest + " team-a\n", want: "team-a"},
+		{name: "sha256 prefix, comments and blank lines", content: "# keys\n\nsha256:" + digest + "\tteam-a\n", want: "team-a"},
+		{name: "missing tenant", content: digest + "\n", wantErr: true},
+		{name: "not hex", content: "xyz team-a\n", wantErr: true},
+		{name: "wrong hash length", content: digest[:32] + " team-a\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadAPIKeyFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("loadAPIKeyFile() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadAPIKeyFile() error = %v", err)
+			}
+			if got[hash] != tt.want {
+				t.Errorf("loadAPIKeyFile() tenant = %q, want %q", got[hash], tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchRetriesFailedReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	// Files are replaced with their final modification time, so the watcher never reads a
+	// partly written file
+	writeKeys := func(content string, modTime time.Time) {
+		t.Helper()
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(tmp, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := sha256.Sum256([]byte("key-a"))
+	start := time.Now().Add(-time.Hour)
+	writeKeys("", start)
+
+	a := newTestAuthenticator(AuthConfig{KeyFile: path, ReloadInterval: 10 * time.Millisecond}, nil, nil)
+	var err error
+	if a.modTimes, err = a.fileModTimes(); err != nil {
+		t.Fatal(err)
+	}
+	go a.watch()
+	defer a.Close()
+
+	// A broken file keeps the previous keys
+	writeKeys("not a key file\n", start.Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if _, _, err := a.authenticate("key-a", ""); err == nil {
+		t.Fatal("authenticate() succeeded before a valid key file was written")
+	}
+
+	// Fixing the file without changing its modification time must still be picked up,
+	// since the failed reload did not record it
+	writeKeys(hex.EncodeToString(hash[:])+" team-a\n", start.Add(time.Minute))
+	deadline := time.Now().Add(time.Second)
+	for {
+		tenant, _, err := a.authenticate("key-a", "")
+		if err == nil && tenant == "team-a" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("authenticate() = %q, %v after fixing the key file", tenant, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
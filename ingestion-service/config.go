@@ -3,9 +3,15 @@ package main
 import (
 	"fmt"
 	"os"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+	"telemorph-prime/ingestion-service/internal/spool"
 )
 
 // Config represents the application configuration
@@ -16,38 +22,104 @@ type Config struct {
 	OpenTelemetry OpenTelemetryConfig `yaml:"opentelemetry"`
 	Health        HealthConfig        `yaml:"health"`
 	Performance   PerformanceConfig   `yaml:"performance"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Tenancy       TenancyConfig       `yaml:"tenancy"`
+	RateLimits    RateLimitConfig     `yaml:"rate_limits"`
+	Validation    ValidationConfig    `yaml:"validation"`
+	Redaction     RedactionConfig     `yaml:"redaction"`
+	Admin         AdminConfig         `yaml:"admin"`
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	GRPCEndpoint   string        `yaml:"grpc_endpoint"`
-	HTTPEndpoint   string        `yaml:"http_endpoint"`
-	HealthEndpoint string        `yaml:"health_endpoint"`
-	ReadTimeout    time.Duration `yaml:"read_timeout"`
-	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	GRPCEndpoint            string            `yaml:"grpc_endpoint"`
+	HTTPEndpoint            string            `yaml:"http_endpoint"`
+	HealthEndpoint          string            `yaml:"health_endpoint"`
+	AdminEndpoint           string            `yaml:"admin_endpoint"`
+	ReadTimeout             time.Duration     `yaml:"read_timeout"`
+	WriteTimeout            time.Duration     `yaml:"write_timeout"`
+	MaxRequestBodySize      int64             `yaml:"max_request_body_size"`
+	MaxDecompressedBodySize int64             `yaml:"max_decompressed_body_size"`
+	RetryAfter              time.Duration     `yaml:"retry_after"`
+	TLS                     ListenerTLSConfig `yaml:"tls"`
+}
+
+// ListenerTLSConfig holds the TLS configuration of each listener
+type ListenerTLSConfig struct {
+	GRPC   ServerTLSConfig `yaml:"grpc"`
+	HTTP   ServerTLSConfig `yaml:"http"`
+	Health ServerTLSConfig `yaml:"health"`
+	Admin  ServerTLSConfig `yaml:"admin"`
+}
+
+// ServerTLSConfig holds the TLS configuration of a listener
+type ServerTLSConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ClientCAFile   string        `yaml:"client_ca_file"`
+	ClientAuth     string        `yaml:"client_auth"`
+	MinVersion     string        `yaml:"min_version"`
+	CipherPolicy   string        `yaml:"cipher_policy"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
 }
 
 // KafkaConfig holds Kafka configuration
 type KafkaConfig struct {
-	Brokers  []string       `yaml:"brokers"`
-	Topics   TopicsConfig   `yaml:"topics"`
-	Producer ProducerConfig `yaml:"producer"`
+	Brokers       []string            `yaml:"brokers"`
+	Topics        TopicsConfig        `yaml:"topics"`
+	Producer      ProducerConfig      `yaml:"producer"`
+	OutputMode    string              `yaml:"output_mode"`
+	PartitionKeys PartitionKeysConfig `yaml:"partition_keys"`
+	Spool         SpoolConfig         `yaml:"spool"`
 }
 
 // TopicsConfig holds Kafka topic names
 type TopicsConfig struct {
+	Traces     string                 `yaml:"traces"`
+	Metrics    string                 `yaml:"metrics"`
+	Logs       string                 `yaml:"logs"`
+	DeadLetter DeadLetterTopicsConfig `yaml:"dead_letter"`
+}
+
+// DeadLetterTopicsConfig holds the dead-letter topic names for undeliverable and invalid payloads
+type DeadLetterTopicsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Traces  string `yaml:"traces"`
+	Metrics string `yaml:"metrics"`
+	Logs    string `yaml:"logs"`
+}
+
+// PartitionKeysConfig holds the Kafka partition key strategy for each signal
+type PartitionKeysConfig struct {
 	Traces  string `yaml:"traces"`
 	Metrics string `yaml:"metrics"`
 	Logs    string `yaml:"logs"`
 }
 
+// SpoolConfig holds the disk spool configuration for messages Kafka could not accept
+type SpoolConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Directory       string        `yaml:"directory"`
+	MaxBytes        int64         `yaml:"max_bytes"`
+	MaxAge          time.Duration `yaml:"max_age"`
+	SegmentBytes    int64         `yaml:"segment_bytes"`
+	EvictionPolicy  string        `yaml:"eviction_policy"`
+	SyncInterval    time.Duration `yaml:"sync_interval"`
+	ReplayInterval  time.Duration `yaml:"replay_interval"`
+	ReplayBatchSize int           `yaml:"replay_batch_size"`
+}
+
 // ProducerConfig holds Kafka producer configuration
 type ProducerConfig struct {
-	RequiredAcks string        `yaml:"required_acks"`
-	RetryMax     int           `yaml:"retry_max"`
-	Compression  string        `yaml:"compression"`
-	BatchSize    int           `yaml:"batch_size"`
-	BatchTimeout time.Duration `yaml:"batch_timeout"`
+	RequiredAcks   string        `yaml:"required_acks"`
+	RetryMax       int           `yaml:"retry_max"`
+	Compression    string        `yaml:"compression"`
+	BatchSize      int           `yaml:"batch_size"`
+	BatchTimeout   time.Duration `yaml:"batch_timeout"`
+	Durability     string        `yaml:"durability"`
+	QueueSize      int           `yaml:"queue_size"`
+	EnqueueTimeout time.Duration `yaml:"enqueue_timeout"`
 }
 
 // LoggingConfig holds logging configuration
@@ -64,6 +136,108 @@ type SamplingConfig struct {
 	Thereafter int `yaml:"thereafter"`
 }
 
+// AuthConfig holds the authentication configuration of the OTLP receivers
+type AuthConfig struct {
+	Enabled        bool           `yaml:"enabled"`
+	APIKeys        []APIKeyConfig `yaml:"api_keys"`
+	KeyFile        string         `yaml:"key_file"`
+	BearerAPIKeys  bool           `yaml:"bearer_api_keys"`
+	JWT            JWTAuthConfig  `yaml:"jwt"`
+	ReloadInterval time.Duration  `yaml:"reload_interval"`
+}
+
+// APIKeyConfig maps a static API key to the tenant it authenticates
+type APIKeyConfig struct {
+	Key    string `yaml:"key"`
+	Tenant string `yaml:"tenant"`
+}
+
+// JWTAuthConfig holds the bearer JWT validation configuration
+type JWTAuthConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	JWKSFile    string        `yaml:"jwks_file"`
+	Issuer      string        `yaml:"issuer"`
+	Audience    string        `yaml:"audience"`
+	TenantClaim string        `yaml:"tenant_claim"`
+	ClockSkew   time.Duration `yaml:"clock_skew"`
+}
+
+// TenancyConfig holds how requests are attributed to tenants and the per-tenant settings
+type TenancyConfig struct {
+	Enabled           bool           `yaml:"enabled"`
+	Header            string         `yaml:"header"`
+	ResourceAttribute string         `yaml:"resource_attribute"`
+	DefaultTenant     string         `yaml:"default_tenant"`
+	RejectUnknown     bool           `yaml:"reject_unknown"`
+	Defaults          TenantConfig   `yaml:"defaults"`
+	Tenants           []TenantConfig `yaml:"tenants"`
+}
+
+// TenantConfig holds the topic overrides, enabled signals and quotas of a tenant
+type TenantConfig struct {
+	ID      string             `yaml:"id"`
+	Topics  TenantTopicsConfig `yaml:"topics"`
+	Signals []string           `yaml:"signals"`
+	Quota   TenantQuotaConfig  `yaml:"quota"`
+}
+
+// TenantTopicsConfig holds the topic templates of a tenant's signals
+type TenantTopicsConfig struct {
+	Traces  string `yaml:"traces"`
+	Metrics string `yaml:"metrics"`
+	Logs    string `yaml:"logs"`
+}
+
+// TenantQuotaConfig holds the request limits of a tenant
+type TenantQuotaConfig struct {
+	MaxConcurrentRequests int   `yaml:"max_concurrent_requests"`
+	MaxItemsPerRequest    int64 `yaml:"max_items_per_request"`
+}
+
+// RateLimitConfig holds the ingestion rate limits, applied globally and per tenant and
+// service.name. Tenants and services that are not listed get the tenant and service limits.
+type RateLimitConfig struct {
+	Enabled  bool                        `yaml:"enabled"`
+	Global   RateLimitsConfig            `yaml:"global"`
+	Tenant   RateLimitsConfig            `yaml:"tenant"`
+	Service  RateLimitsConfig            `yaml:"service"`
+	Tenants  map[string]RateLimitsConfig `yaml:"tenants"`
+	Services map[string]RateLimitsConfig `yaml:"services"`
+}
+
+// RateLimitsConfig holds the token-bucket rates and bursts of one rate limit scope
+type RateLimitsConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	RequestsBurst     int     `yaml:"requests_burst"`
+	BytesPerSecond    float64 `yaml:"bytes_per_second"`
+	BytesBurst        int     `yaml:"bytes_burst"`
+	ItemsPerSecond    float64 `yaml:"items_per_second"`
+	ItemsBurst        int     `yaml:"items_burst"`
+}
+
+// ValidationConfig holds the validation mode of each signal: reject, drop_item, warn or off
+type ValidationConfig struct {
+	Traces  string `yaml:"traces"`
+	Metrics string `yaml:"metrics"`
+	Logs    string `yaml:"logs"`
+}
+
+// RedactionConfig holds the processors applied, in order, to item attributes and log bodies
+type RedactionConfig struct {
+	Enabled    bool                       `yaml:"enabled"`
+	Processors []RedactionProcessorConfig `yaml:"processors"`
+}
+
+// RedactionProcessorConfig holds one redaction step: allow_keys, deny_keys, mask, hash or truncate
+type RedactionProcessorConfig struct {
+	Type        string   `yaml:"type"`
+	Keys        []string `yaml:"keys"`
+	Pattern     string   `yaml:"pattern"`
+	Replacement string   `yaml:"replacement"`
+	Salt        string   `yaml:"salt"`
+	MaxLength   int      `yaml:"max_length"`
+}
+
 // OpenTelemetryConfig holds OpenTelemetry configuration
 type OpenTelemetryConfig struct {
 	ServiceName    string         `yaml:"service_name"`
@@ -71,6 +245,7 @@ type OpenTelemetryConfig struct {
 	Environment    string         `yaml:"environment"`
 	Tracing        TracingConfig  `yaml:"tracing"`
 	Metrics        MetricsConfig  `yaml:"metrics"`
+	Logs           LogsConfig     `yaml:"logs"`
 	Resource       ResourceConfig `yaml:"resource"`
 }
 
@@ -85,17 +260,50 @@ type TracingConfig struct {
 
 // MetricsConfig holds metrics configuration
 type MetricsConfig struct {
-	Enabled  bool          `yaml:"enabled"`
-	Exporter string        `yaml:"exporter"`
-	OTLP     OTLPConfig    `yaml:"otlp"`
-	Interval time.Duration `yaml:"interval"`
+	Enabled     bool          `yaml:"enabled"`
+	Exporter    string        `yaml:"exporter"`
+	OTLP        OTLPConfig    `yaml:"otlp"`
+	Interval    time.Duration `yaml:"interval"`
+	Temporality string        `yaml:"temporality"`
+}
+
+// LogsConfig holds configuration for exporting the service's own logs
+type LogsConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Exporter      string        `yaml:"exporter"`
+	Level         string        `yaml:"level"`
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	QueueSize     int           `yaml:"queue_size"`
 }
 
 // OTLPConfig holds OTLP exporter configuration
 type OTLPConfig struct {
-	Endpoint string `yaml:"endpoint"`
-	Protocol string `yaml:"protocol"`
-	Insecure bool   `yaml:"insecure"`
+	Endpoint    string            `yaml:"endpoint"`
+	Protocol    string            `yaml:"protocol"`
+	Insecure    bool              `yaml:"insecure"`
+	TLS         OTLPTLSConfig     `yaml:"tls"`
+	Headers     map[string]string `yaml:"headers"`
+	Compression string            `yaml:"compression"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	Retry       OTLPRetryConfig   `yaml:"retry"`
+}
+
+// OTLPTLSConfig holds the TLS settings for connecting to an OTLP collector
+type OTLPTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// OTLPRetryConfig holds the retry policy for failed OTLP exports
+type OTLPRetryConfig struct {
+	Enabled         *bool         `yaml:"enabled"`
+	InitialInterval time.Duration `yaml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval"`
+	MaxElapsedTime  time.Duration `yaml:"max_elapsed_time"`
 }
 
 // JaegerConfig holds Jaeger exporter configuration
@@ -122,17 +330,29 @@ type AttributeConfig struct {
 
 // HealthConfig holds health check configuration
 type HealthConfig struct {
-	Enabled           bool   `yaml:"enabled"`
-	Endpoint          string `yaml:"endpoint"`
-	ReadinessEndpoint string `yaml:"readiness_endpoint"`
-	LivenessEndpoint  string `yaml:"liveness_endpoint"`
-	MetricsEndpoint   string `yaml:"metrics_endpoint"`
+	Enabled                  bool          `yaml:"enabled"`
+	Endpoint                 string        `yaml:"endpoint"`
+	ReadinessEndpoint        string        `yaml:"readiness_endpoint"`
+	LivenessEndpoint         string        `yaml:"liveness_endpoint"`
+	MetricsEndpoint          string        `yaml:"metrics_endpoint"`
+	CheckTimeout             time.Duration `yaml:"check_timeout"`
+	SpoolMinFreeBytes        int64         `yaml:"spool_min_free_bytes"`
+	QueueSaturationThreshold float64       `yaml:"queue_saturation_threshold"`
+	ProducerStallTimeout     time.Duration `yaml:"producer_stall_timeout"`
+}
+
+// AdminConfig holds the admin listener configuration
+type AdminConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	DeadLetterEndpoint string        `yaml:"dead_letter_endpoint"`
+	KafkaTimeout       time.Duration `yaml:"kafka_timeout"`
 }
 
 // PerformanceConfig holds performance-related configuration
 type PerformanceConfig struct {
 	MaxConcurrentRequests   int           `yaml:"max_concurrent_requests"`
 	RequestTimeout          time.Duration `yaml:"request_timeout"`
+	AdmissionTimeout        time.Duration `yaml:"admission_timeout"`
 	GracefulShutdownTimeout time.Duration `yaml:"graceful_shutdown_timeout"`
 }
 
@@ -163,9 +383,353 @@ func LoadConfig(configPath string) (*Config, error) {
 	// Set defaults for missing values
 	setDefaults(&config)
 
+	if err := validateConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &config, nil
 }
 
+// validateConfig checks configuration values that have no safe fallback
+func validateConfig(config *Config) error {
+	switch config.Kafka.OutputMode {
+	case outputModeEnvelope, outputModeFlatten:
+	default:
+		return fmt.Errorf("unknown kafka.output_mode %q", config.Kafka.OutputMode)
+	}
+	switch config.Kafka.Producer.Durability {
+	case durabilityFireAndForget, durabilityEnqueue, durabilityBrokerAck:
+	default:
+		return fmt.Errorf("unknown kafka.producer.durability %q", config.Kafka.Producer.Durability)
+	}
+	if config.Kafka.Producer.QueueSize < 0 {
+		return fmt.Errorf("kafka.producer.queue_size must not be negative, got %d", config.Kafka.Producer.QueueSize)
+	}
+	switch spool.EvictionPolicy(config.Kafka.Spool.EvictionPolicy) {
+	case spool.EvictDropOldest, spool.EvictRejectNew:
+	default:
+		return fmt.Errorf("unknown kafka.spool.eviction_policy %q", config.Kafka.Spool.EvictionPolicy)
+	}
+	if config.Kafka.Spool.SyncInterval < 0 {
+		return fmt.Errorf("kafka.spool.sync_interval must not be negative")
+	}
+	if err := validatePartitionKey(otlp.SignalTraces, config.Kafka.PartitionKeys.Traces); err != nil {
+		return err
+	}
+	if err := validatePartitionKey(otlp.SignalMetrics, config.Kafka.PartitionKeys.Metrics); err != nil {
+		return err
+	}
+	if err := validatePartitionKey(otlp.SignalLogs, config.Kafka.PartitionKeys.Logs); err != nil {
+		return err
+	}
+	switch config.OpenTelemetry.Metrics.Temporality {
+	case temporalityCumulative, temporalityDelta:
+	default:
+		return fmt.Errorf("unknown opentelemetry.metrics.temporality %q", config.OpenTelemetry.Metrics.Temporality)
+	}
+	if err := validateServerTLSConfig("server.tls.grpc", config.Server.TLS.GRPC); err != nil {
+		return err
+	}
+	if err := validateServerTLSConfig("server.tls.http", config.Server.TLS.HTTP); err != nil {
+		return err
+	}
+	if err := validateServerTLSConfig("server.tls.health", config.Server.TLS.Health); err != nil {
+		return err
+	}
+	if err := validateServerTLSConfig("server.tls.admin", config.Server.TLS.Admin); err != nil {
+		return err
+	}
+	if config.Admin.KafkaTimeout < 0 {
+		return fmt.Errorf("admin.kafka_timeout must not be negative")
+	}
+	if err := validateAuthConfig(config.Auth); err != nil {
+		return err
+	}
+	if err := validateTenancyConfig(config); err != nil {
+		return err
+	}
+	if err := validateRateLimitConfig(config.RateLimits); err != nil {
+		return err
+	}
+	for name, mode := range map[string]string{
+		"validation.traces":  config.Validation.Traces,
+		"validation.metrics": config.Validation.Metrics,
+		"validation.logs":    config.Validation.Logs,
+	} {
+		switch mode {
+		case validationModeReject, validationModeDropItem, validationModeWarn, validationModeOff:
+		default:
+			return fmt.Errorf("unknown %s mode %q", name, mode)
+		}
+	}
+	if err := validateRedactionConfig(config.Redaction); err != nil {
+		return err
+	}
+	if err := validateOTLPConfig("opentelemetry.tracing.otlp", config.OpenTelemetry.Tracing.OTLP); err != nil {
+		return err
+	}
+	if err := validateOTLPConfig("opentelemetry.metrics.otlp", config.OpenTelemetry.Metrics.OTLP); err != nil {
+		return err
+	}
+	if config.OpenTelemetry.Logs.BatchSize < 0 {
+		return fmt.Errorf("opentelemetry.logs.batch_size must not be negative, got %d", config.OpenTelemetry.Logs.BatchSize)
+	}
+	if config.OpenTelemetry.Logs.QueueSize < 0 {
+		return fmt.Errorf("opentelemetry.logs.queue_size must not be negative, got %d", config.OpenTelemetry.Logs.QueueSize)
+	}
+	if config.OpenTelemetry.Logs.FlushInterval <= 0 {
+		return fmt.Errorf("opentelemetry.logs.flush_interval must be positive, got %s", config.OpenTelemetry.Logs.FlushInterval)
+	}
+	if config.Performance.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("performance.max_concurrent_requests must be positive, got %d", config.Performance.MaxConcurrentRequests)
+	}
+	if t := config.Health.QueueSaturationThreshold; t < 0 || t > 1 {
+		return fmt.Errorf("health.queue_saturation_threshold must be between 0 and 1, got %g", t)
+	}
+	return nil
+}
+
+// validateAuthConfig checks that enabled authentication has a credential source
+func validateAuthConfig(config AuthConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+	if len(config.APIKeys) == 0 && config.KeyFile == "" && !config.JWT.Enabled {
+		return fmt.Errorf("auth is enabled but no api_keys, key_file or jwt is configured")
+	}
+	for i, key := range config.APIKeys {
+		if key.Key == "" || key.Tenant == "" {
+			return fmt.Errorf("auth.api_keys[%d] must set both key and tenant", i)
+		}
+	}
+	if config.JWT.Enabled && config.JWT.JWKSFile == "" {
+		return fmt.Errorf("auth.jwt.jwks_file is required when auth.jwt is enabled")
+	}
+	if config.ReloadInterval <= 0 {
+		return fmt.Errorf("auth.reload_interval must be positive, got %s", config.ReloadInterval)
+	}
+	return nil
+}
+
+// validateTenancyConfig checks the tenant settings and that topic templates are only
+// used with tenancy enabled
+func validateTenancyConfig(config *Config) error {
+	tenancy := config.Tenancy
+	topics := map[string]string{
+		"kafka.topics.traces":              config.Kafka.Topics.Traces,
+		"kafka.topics.metrics":             config.Kafka.Topics.Metrics,
+		"kafka.topics.logs":                config.Kafka.Topics.Logs,
+		"kafka.topics.dead_letter.traces":  config.Kafka.Topics.DeadLetter.Traces,
+		"kafka.topics.dead_letter.metrics": config.Kafka.Topics.DeadLetter.Metrics,
+		"kafka.topics.dead_letter.logs":    config.Kafka.Topics.DeadLetter.Logs,
+	}
+	for name, topic := range topics {
+		if !tenancy.Enabled && strings.Contains(topic, tenantPlaceholder) {
+			return fmt.Errorf("%s uses %s but tenancy is disabled", name, tenantPlaceholder)
+		}
+	}
+	if !tenancy.Enabled {
+		return nil
+	}
+
+	if tenancy.DefaultTenant != "" && !validTenantID(tenancy.DefaultTenant) {
+		return fmt.Errorf("invalid tenancy.default_tenant %q", tenancy.DefaultTenant)
+	}
+	if err := validateTenantConfig("tenancy.defaults", tenancy.Defaults); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(tenancy.Tenants))
+	for i, tenant := range tenancy.Tenants {
+		name := fmt.Sprintf("tenancy.tenants[%d]", i)
+		if !validTenantID(tenant.ID) {
+			return fmt.Errorf("invalid %s.id %q", name, tenant.ID)
+		}
+		if seen[tenant.ID] {
+			return fmt.Errorf("duplicate %s.id %q", name, tenant.ID)
+		}
+		seen[tenant.ID] = true
+		if err := validateTenantConfig(name, tenant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTenantConfig checks the signals and quotas of a tenant
+func validateTenantConfig(name string, config TenantConfig) error {
+	for _, signal := range config.Signals {
+		switch otlp.Signal(signal) {
+		case otlp.SignalTraces, otlp.SignalMetrics, otlp.SignalLogs:
+		default:
+			return fmt.Errorf("unknown signal %q in %s.signals", signal, name)
+		}
+	}
+	if config.Quota.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("%s.quota.max_concurrent_requests must not be negative, got %d", name, config.Quota.MaxConcurrentRequests)
+	}
+	if config.Quota.MaxItemsPerRequest < 0 {
+		return fmt.Errorf("%s.quota.max_items_per_request must not be negative, got %d", name, config.Quota.MaxItemsPerRequest)
+	}
+	return nil
+}
+
+// validateRateLimitConfig checks that no rate or burst is negative
+func validateRateLimitConfig(config RateLimitConfig) error {
+	scopes := map[string]RateLimitsConfig{
+		"rate_limits.global":  config.Global,
+		"rate_limits.tenant":  config.Tenant,
+		"rate_limits.service": config.Service,
+	}
+	for tenant, limits := range config.Tenants {
+		scopes[fmt.Sprintf("rate_limits.tenants[%q]", tenant)] = limits
+	}
+	for service, limits := range config.Services {
+		scopes[fmt.Sprintf("rate_limits.services[%q]", service)] = limits
+	}
+	for name, limits := range scopes {
+		if limits.RequestsPerSecond < 0 || limits.BytesPerSecond < 0 || limits.ItemsPerSecond < 0 {
+			return fmt.Errorf("%s rates must not be negative", name)
+		}
+		if limits.RequestsBurst < 0 || limits.BytesBurst < 0 || limits.ItemsBurst < 0 {
+			return fmt.Errorf("%s bursts must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// validateRedactionConfig checks that each redaction processor has the settings its type needs
+func validateRedactionConfig(config RedactionConfig) error {
+	for i, processor := range config.Processors {
+		name := fmt.Sprintf("redaction.processors[%d]", i)
+		for _, key := range processor.Keys {
+			if _, err := path.Match(key, ""); err != nil {
+				return fmt.Errorf("invalid %s key pattern %q: %w", name, key, err)
+			}
+		}
+		switch processor.Type {
+		case redactAllowKeys, redactDenyKeys:
+			if len(processor.Keys) == 0 {
+				return fmt.Errorf("%s of type %s requires keys", name, processor.Type)
+			}
+		case redactMask:
+			if processor.Pattern == "" {
+				return fmt.Errorf("%s of type mask requires a pattern", name)
+			}
+			if _, err := regexp.Compile(processor.Pattern); err != nil {
+				return fmt.Errorf("invalid %s pattern: %w", name, err)
+			}
+		case redactHash:
+			if len(processor.Keys) == 0 || processor.Salt == "" {
+				return fmt.Errorf("%s of type hash requires keys and a salt", name)
+			}
+		case redactTruncate:
+			if processor.MaxLength <= 0 {
+				return fmt.Errorf("%s of type truncate requires a positive max_length", name)
+			}
+		default:
+			return fmt.Errorf("unknown %s type %q", name, processor.Type)
+		}
+	}
+	return nil
+}
+
+// validateOTLPConfig checks the connection settings of an OTLP exporter
+func validateOTLPConfig(name string, config OTLPConfig) error {
+	if config.Insecure && config.TLS.CAFile != "" {
+		return fmt.Errorf("%s.tls.ca_file is set but %s.insecure disables TLS", name, name)
+	}
+	if config.Insecure && (config.TLS.CertFile != "" || config.TLS.KeyFile != "") {
+		return fmt.Errorf("%s.tls client certificates are set but %s.insecure disables TLS", name, name)
+	}
+	if (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		return fmt.Errorf("%s.tls.cert_file and %s.tls.key_file must be set together", name, name)
+	}
+	switch config.Compression {
+	case otlpCompressionNone, otlpCompressionGzip:
+	default:
+		return fmt.Errorf("unknown %s.compression %q", name, config.Compression)
+	}
+	if config.Timeout < 0 {
+		return fmt.Errorf("%s.timeout must not be negative, got %s", name, config.Timeout)
+	}
+	return nil
+}
+
+// validateServerTLSConfig checks a listener's TLS settings
+func validateServerTLSConfig(name string, config ServerTLSConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.CertFile == "" || config.KeyFile == "" {
+		return fmt.Errorf("%s.cert_file and %s.key_file are required when TLS is enabled", name, name)
+	}
+	if _, ok := tlsVersions[config.MinVersion]; !ok {
+		return fmt.Errorf("unknown %s.min_version %q", name, config.MinVersion)
+	}
+	switch config.CipherPolicy {
+	case cipherPolicyDefault, cipherPolicyModern:
+	default:
+		return fmt.Errorf("unknown %s.cipher_policy %q", name, config.CipherPolicy)
+	}
+	switch config.ClientAuth {
+	case clientAuthNone:
+	case clientAuthOptional, clientAuthRequire:
+		if config.ClientCAFile == "" {
+			return fmt.Errorf("%s.client_ca_file is required when %s.client_auth is %q", name, name, config.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("unknown %s.client_auth %q", name, config.ClientAuth)
+	}
+	if config.ReloadInterval <= 0 {
+		return fmt.Errorf("%s.reload_interval must be positive, got %s", name, config.ReloadInterval)
+	}
+	return nil
+}
+
+// setOTLPDefaults sets default values for an OTLP exporter
+func setOTLPDefaults(config *OTLPConfig) {
+	if config.Compression == "" {
+		config.Compression = otlpCompressionNone
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Retry.Enabled == nil {
+		// Retrying is the exporters' own default, kept when retry is not configured
+		enabled := true
+		config.Retry.Enabled = &enabled
+	}
+	if config.Retry.InitialInterval == 0 {
+		config.Retry.InitialInterval = 5 * time.Second
+	}
+	if config.Retry.MaxInterval == 0 {
+		config.Retry.MaxInterval = 30 * time.Second
+	}
+	if config.Retry.MaxElapsedTime == 0 {
+		config.Retry.MaxElapsedTime = time.Minute
+	}
+}
+
+// setServerTLSDefaults sets default values for a listener's TLS settings
+func setServerTLSDefaults(config *ServerTLSConfig) {
+	if config.MinVersion == "" {
+		config.MinVersion = "1.2"
+	}
+	if config.CipherPolicy == "" {
+		config.CipherPolicy = cipherPolicyDefault
+	}
+	if config.ClientAuth == "" {
+		if config.ClientCAFile != "" {
+			config.ClientAuth = clientAuthRequire
+		} else {
+			config.ClientAuth = clientAuthNone
+		}
+	}
+	if config.ReloadInterval == 0 {
+		config.ReloadInterval = 30 * time.Second
+	}
+}
+
 // setDefaults sets default values for configuration
 func setDefaults(config *Config) {
 	// Server defaults
@@ -178,12 +742,28 @@ func setDefaults(config *Config) {
 	if config.Server.HealthEndpoint == "" {
 		config.Server.HealthEndpoint = "0.0.0.0:8080"
 	}
+	if config.Server.AdminEndpoint == "" {
+		config.Server.AdminEndpoint = "127.0.0.1:8081"
+	}
 	if config.Server.ReadTimeout == 0 {
 		config.Server.ReadTimeout = 5 * time.Second
 	}
 	if config.Server.WriteTimeout == 0 {
 		config.Server.WriteTimeout = 10 * time.Second
 	}
+	if config.Server.MaxRequestBodySize == 0 {
+		config.Server.MaxRequestBodySize = 8 << 20 // 8 MiB
+	}
+	if config.Server.MaxDecompressedBodySize == 0 {
+		config.Server.MaxDecompressedBodySize = 32 << 20 // 32 MiB
+	}
+	if config.Server.RetryAfter == 0 {
+		config.Server.RetryAfter = 5 * time.Second
+	}
+	setServerTLSDefaults(&config.Server.TLS.GRPC)
+	setServerTLSDefaults(&config.Server.TLS.HTTP)
+	setServerTLSDefaults(&config.Server.TLS.Health)
+	setServerTLSDefaults(&config.Server.TLS.Admin)
 
 	// Kafka defaults
 	if len(config.Kafka.Brokers) == 0 {
@@ -198,6 +778,57 @@ func setDefaults(config *Config) {
 	if config.Kafka.Topics.Logs == "" {
 		config.Kafka.Topics.Logs = "otel.logs"
 	}
+	if config.Kafka.Topics.DeadLetter.Traces == "" {
+		config.Kafka.Topics.DeadLetter.Traces = config.Kafka.Topics.Traces + ".dlq"
+	}
+	if config.Kafka.Topics.DeadLetter.Metrics == "" {
+		config.Kafka.Topics.DeadLetter.Metrics = config.Kafka.Topics.Metrics + ".dlq"
+	}
+	if config.Kafka.Topics.DeadLetter.Logs == "" {
+		config.Kafka.Topics.DeadLetter.Logs = config.Kafka.Topics.Logs + ".dlq"
+	}
+	if config.Kafka.OutputMode == "" {
+		config.Kafka.OutputMode = outputModeEnvelope
+	}
+	if config.Kafka.Producer.Durability == "" {
+		config.Kafka.Producer.Durability = durabilityBrokerAck
+	}
+	if config.Kafka.Producer.QueueSize == 0 {
+		config.Kafka.Producer.QueueSize = 10000
+	}
+	if config.Kafka.Producer.EnqueueTimeout == 0 {
+		config.Kafka.Producer.EnqueueTimeout = time.Second
+	}
+	if config.Kafka.Spool.Directory == "" {
+		config.Kafka.Spool.Directory = "spool"
+	}
+	if config.Kafka.Spool.MaxBytes == 0 {
+		config.Kafka.Spool.MaxBytes = 1 << 30 // 1 GiB
+	}
+	if config.Kafka.Spool.MaxAge == 0 {
+		config.Kafka.Spool.MaxAge = 24 * time.Hour
+	}
+	if config.Kafka.Spool.SegmentBytes == 0 {
+		config.Kafka.Spool.SegmentBytes = 16 << 20 // 16 MiB
+	}
+	if config.Kafka.Spool.EvictionPolicy == "" {
+		config.Kafka.Spool.EvictionPolicy = string(spool.EvictDropOldest)
+	}
+	if config.Kafka.Spool.ReplayInterval == 0 {
+		config.Kafka.Spool.ReplayInterval = 5 * time.Second
+	}
+	if config.Kafka.Spool.ReplayBatchSize == 0 {
+		config.Kafka.Spool.ReplayBatchSize = 500
+	}
+	if config.Kafka.PartitionKeys.Traces == "" {
+		config.Kafka.PartitionKeys.Traces = partitionKeyTraceID
+	}
+	if config.Kafka.PartitionKeys.Metrics == "" {
+		config.Kafka.PartitionKeys.Metrics = partitionKeyServiceMetric
+	}
+	if config.Kafka.PartitionKeys.Logs == "" {
+		config.Kafka.PartitionKeys.Logs = partitionKeyResourceHash
+	}
 
 	// Logging defaults
 	if config.Logging.Level == "" {
@@ -217,6 +848,60 @@ func setDefaults(config *Config) {
 	if config.OpenTelemetry.Environment == "" {
 		config.OpenTelemetry.Environment = "development"
 	}
+	if config.OpenTelemetry.Metrics.Interval == 0 {
+		config.OpenTelemetry.Metrics.Interval = 60 * time.Second
+	}
+	if config.OpenTelemetry.Metrics.Temporality == "" {
+		config.OpenTelemetry.Metrics.Temporality = temporalityCumulative
+	}
+	setOTLPDefaults(&config.OpenTelemetry.Tracing.OTLP)
+	setOTLPDefaults(&config.OpenTelemetry.Metrics.OTLP)
+	if config.OpenTelemetry.Logs.Level == "" {
+		config.OpenTelemetry.Logs.Level = "warn"
+	}
+	if config.OpenTelemetry.Logs.BatchSize == 0 {
+		config.OpenTelemetry.Logs.BatchSize = 512
+	}
+	if config.OpenTelemetry.Logs.FlushInterval == 0 {
+		config.OpenTelemetry.Logs.FlushInterval = 5 * time.Second
+	}
+	if config.OpenTelemetry.Logs.QueueSize == 0 {
+		config.OpenTelemetry.Logs.QueueSize = 4096
+	}
+
+	// Auth defaults
+	if config.Auth.ReloadInterval == 0 {
+		config.Auth.ReloadInterval = 30 * time.Second
+	}
+	if config.Auth.JWT.TenantClaim == "" {
+		config.Auth.JWT.TenantClaim = "tenant"
+	}
+	if config.Auth.JWT.ClockSkew == 0 {
+		config.Auth.JWT.ClockSkew = time.Minute
+	}
+
+	// Tenancy defaults
+	if config.Tenancy.Header == "" {
+		config.Tenancy.Header = "X-Scope-OrgID"
+	}
+
+	// Redaction defaults
+	for i := range config.Redaction.Processors {
+		if config.Redaction.Processors[i].Type == redactMask && config.Redaction.Processors[i].Replacement == "" {
+			config.Redaction.Processors[i].Replacement = "****"
+		}
+	}
+
+	// Validation defaults
+	if config.Validation.Traces == "" {
+		config.Validation.Traces = validationModeWarn
+	}
+	if config.Validation.Metrics == "" {
+		config.Validation.Metrics = validationModeWarn
+	}
+	if config.Validation.Logs == "" {
+		config.Validation.Logs = validationModeWarn
+	}
 
 	// Health defaults
 	if config.Health.Endpoint == "" {
@@ -231,6 +916,26 @@ func setDefaults(config *Config) {
 	if config.Health.MetricsEndpoint == "" {
 		config.Health.MetricsEndpoint = "/metrics"
 	}
+	if config.Health.CheckTimeout == 0 {
+		config.Health.CheckTimeout = 2 * time.Second
+	}
+	if config.Health.SpoolMinFreeBytes == 0 {
+		config.Health.SpoolMinFreeBytes = 100 * 1024 * 1024
+	}
+	if config.Health.QueueSaturationThreshold == 0 {
+		config.Health.QueueSaturationThreshold = 0.9
+	}
+	if config.Health.ProducerStallTimeout == 0 {
+		config.Health.ProducerStallTimeout = 2 * time.Minute
+	}
+
+	// Admin defaults
+	if config.Admin.DeadLetterEndpoint == "" {
+		config.Admin.DeadLetterEndpoint = "/admin/dlq"
+	}
+	if config.Admin.KafkaTimeout == 0 {
+		config.Admin.KafkaTimeout = 5 * time.Second
+	}
 
 	// Performance defaults
 	if config.Performance.MaxConcurrentRequests == 0 {
@@ -239,8 +944,10 @@ func setDefaults(config *Config) {
 	if config.Performance.RequestTimeout == 0 {
 		config.Performance.RequestTimeout = 30 * time.Second
 	}
+	if config.Performance.AdmissionTimeout == 0 {
+		config.Performance.AdmissionTimeout = 100 * time.Millisecond
+	}
 	if config.Performance.GracefulShutdownTimeout == 0 {
 		config.Performance.GracefulShutdownTimeout = 30 * time.Second
 	}
 }
-
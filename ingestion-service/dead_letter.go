@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+	"telemorph-prime/ingestion-service/kafkatrace"
+)
+
+// Dead-letter reasons, sent in the dlq.reason header
+const (
+	deadLetterReasonDecode        = "decode_failed"
+	deadLetterReasonMarshal       = "marshal_failed"
+	deadLetterReasonRejected      = "rejected_by_kafka"
+	deadLetterReasonUndeliverable = "delivery_failed"
+	deadLetterReasonInvalid       = "validation_failed"
+)
+
+// Dead-letter record headers
+const (
+	headerDeadLetterReason        = "dlq.reason"
+	headerDeadLetterError         = "dlq.error"
+	headerDeadLetterOriginalTopic = "dlq.original_topic"
+	headerDeadLetterTenant        = "dlq.tenant"
+	headerDeadLetterSize          = "dlq.size"
+	headerDeadLetterSHA256        = "dlq.sha256"
+)
+
+// deadLetterMarker is the metadata of dead-letter messages, so that a failure to
+// deliver one is logged rather than dead-lettered again
+type deadLetterMarker struct{}
+
+// deadLetterReason classifies a permanent delivery error
+func deadLetterReason(err error) string {
+	var marshalerErr *json.MarshalerError
+	var unsupportedTypeErr *json.UnsupportedTypeError
+	var unsupportedValueErr *json.UnsupportedValueError
+	if errors.As(err, &marshalerErr) || errors.As(err, &unsupportedTypeErr) || errors.As(err, &unsupportedValueErr) {
+		return deadLetterReasonMarshal
+	}
+	return deadLetterReasonRejected
+}
+
+// deadLetterTopic returns the dead-letter topic of a tenant's signal, or an empty string
+// if dead-lettering is disabled
+func (kp *KafkaProducer) deadLetterTopic(signal, tenant string) string {
+	dlq := kp.config.Kafka.Topics.DeadLetter
+	if !dlq.Enabled {
+		return ""
+	}
+	var topic string
+	switch otlp.Signal(signal) {
+	case otlp.SignalTraces:
+		topic = dlq.Traces
+	case otlp.SignalMetrics:
+		topic = dlq.Metrics
+	case otlp.SignalLogs:
+		topic = dlq.Logs
+	default:
+		return ""
+	}
+	return expandTenantTopic(topic, tenant)
+}
+
+// deadLetter publishes a copy of a message that could not be delivered to its signal's
+// dead-letter topic. The copy keeps the original key and headers and adds the failure
+// reason, original topic, tenant and the trace context of ctx. Dead-letter delivery is
+// best effort: it never blocks and failures are only logged.
+func (kp *KafkaProducer) deadLetter(ctx context.Context, message *sarama.ProducerMessage, reason string, cause error) {
+	tenant := tenantFromContext(ctx)
+	if tenant == "" {
+		tenant = messageHeader(message, "tenant_id")
+	}
+	topic := kp.deadLetterTopic(messageHeader(message, "signal_type"), tenant)
+	if topic == "" || messageHeader(message, headerDeadLetterReason) != "" {
+		return
+	}
+
+	dlqMessage := &sarama.ProducerMessage{
+		Topic:    topic,
+		Key:      message.Key,
+		Value:    message.Value,
+		Headers:  append([]sarama.RecordHeader(nil), message.Headers...),
+		Metadata: deadLetterMarker{},
+	}
+	kp.publishDeadLetter(ctx, dlqMessage, message.Topic, reason, cause)
+}
+
+// deadLetterUndecodable records a request body that could not be decoded on the signal's
+// dead-letter topic. The body cannot be redacted, so it is not published: the record has
+// no value and carries the body's size and SHA-256 hash in its headers instead.
+func (kp *KafkaProducer) deadLetterUndecodable(ctx context.Context, signal otlpSignal, body []byte, contentType string, cause error) {
+	digest := sha256.Sum256(body)
+	kp.deadLetterRequest(ctx, signal, nil, []sarama.RecordHeader{
+		{Key: []byte("content_type"), Value: []byte(contentType)},
+		{Key: []byte(headerDeadLetterSize), Value: []byte(strconv.Itoa(len(body)))},
+		{Key: []byte(headerDeadLetterSHA256), Value: []byte(hex.EncodeToString(digest[:]))},
+	}, deadLetterReasonDecode, cause)
+}
+
+// deadLetterInvalid publishes a decoded request that failed validation to the signal's
+// dead-letter topic as OTLP/JSON. The payload is expected to be redacted already.
+func (kp *KafkaProducer) deadLetterInvalid(ctx context.Context, signal otlpSignal, payload otlp.Payload, cause error) {
+	value, err := json.Marshal(payload)
+	if err != nil {
+		kp.logger.Error("Failed to encode invalid payload for the dead-letter topic",
+			zap.Error(err),
+			zap.String("signal_type", signal.name),
+		)
+		return
+	}
+	kp.deadLetterRequest(ctx, signal, sarama.ByteEncoder(value), []sarama.RecordHeader{
+		{Key: []byte("content_type"), Value: []byte(contentTypeJSON)},
+	}, deadLetterReasonInvalid, cause)
+}
+
+// deadLetterRequest publishes a record describing a refused request to the signal's
+// dead-letter topic
+func (kp *KafkaProducer) deadLetterRequest(ctx context.Context, signal otlpSignal, value sarama.Encoder, headers []sarama.RecordHeader, reason string, cause error) {
+	tenant := tenantFromContext(ctx)
+	topic := kp.deadLetterTopic(signal.name, tenant)
+	if topic == "" {
+		return
+	}
+
+	dlqMessage := &sarama.ProducerMessage{
+		Topic:    topic,
+		Value:    value,
+		Headers:  append([]sarama.RecordHeader{{Key: []byte("signal_type"), Value: []byte(signal.name)}}, headers...),
+		Metadata: deadLetterMarker{},
+	}
+	kp.publishDeadLetter(ctx, dlqMessage, kp.signalTopic(signal, tenant), reason, cause)
+}
+
+// publishDeadLetter adds the dead-letter headers to a message and enqueues it without waiting
+func (kp *KafkaProducer) publishDeadLetter(ctx context.Context, message *sarama.ProducerMessage, originalTopic, reason string, cause error) {
+	carrier := kafkatrace.NewProducerHeaderCarrier(message)
+	carrier.Set(headerDeadLetterReason, reason)
+	carrier.Set(headerDeadLetterOriginalTopic, originalTopic)
+	if cause != nil {
+		carrier.Set(headerDeadLetterError, cause.Error())
+	}
+	if tenant := tenantFromContext(ctx); tenant != "" {
+		carrier.Set(headerDeadLetterTenant, tenant)
+	} else if tenant := messageHeader(message, "tenant_id"); tenant != "" {
+		carrier.Set(headerDeadLetterTenant, tenant)
+	}
+	kafkatrace.Inject(ctx, message)
+
+	if err := kp.enqueue(ctx, message, false); err != nil {
+		kp.logger.Error("Failed to send message to dead-letter topic",
+			zap.Error(err),
+			zap.String("topic", message.Topic),
+			zap.String("reason", reason),
+		)
+		return
+	}
+	kp.telemetryManager.LogWithTraceContext(ctx, zap.WarnLevel, "Sent message to dead-letter topic",
+		zap.String("topic", message.Topic),
+		zap.String("original_topic", originalTopic),
+		zap.String("reason", reason),
+		zap.NamedError("cause", cause),
+	)
+}
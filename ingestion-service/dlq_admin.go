@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+const (
+	// maxDeadLetterSamples caps the sample query parameter of the DLQ admin endpoint
+	maxDeadLetterSamples = 100
+	// deadLetterSampleTimeout bounds how long sampling waits for records from one partition
+	deadLetterSampleTimeout = 2 * time.Second
+)
+
+// deadLetterTopicStatus describes the contents of one dead-letter topic. Count is left
+// out for a tenant reading a topic it shares with other tenants.
+type deadLetterTopicStatus struct {
+	Signal  string             `json:"signal"`
+	Topic   string             `json:"topic"`
+	Count   *int64             `json:"count,omitempty"`
+	Samples []deadLetterSample `json:"samples,omitempty"`
+}
+
+// deadLetterSample is one record read back from a dead-letter topic
+type deadLetterSample struct {
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers"`
+	Value     interface{}       `json:"value"`
+}
+
+// deadLetterInspector reads the dead-letter topics for the admin endpoint. It keeps its
+// own client, connected on first use, with the network timeouts bounded by the admin
+// Kafka timeout.
+type deadLetterInspector struct {
+	config *Config
+	tm     *TelemetryManager
+	client *lazyKafkaClient
+}
+
+// newDeadLetterInspector creates the dead-letter topic reader of the admin endpoint
+func newDeadLetterInspector(config *Config, tm *TelemetryManager) *deadLetterInspector {
+	timeout := config.Admin.KafkaTimeout
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Net.DialTimeout = timeout
+	saramaConfig.Net.ReadTimeout = timeout
+	saramaConfig.Net.WriteTimeout = timeout
+	saramaConfig.Metadata.Retry.Max = 0
+	saramaConfig.Metadata.Full = false
+
+	return &deadLetterInspector{
+		config: config,
+		tm:     tm,
+		client: newLazyKafkaClient(config.Kafka.Brokers, saramaConfig),
+	}
+}
+
+// Close closes the inspector's Kafka client
+func (d *deadLetterInspector) Close() error {
+	return d.client.close()
+}
+
+// ServeHTTP serves the number of records in each dead-letter topic and, with ?sample=N,
+// the N most recent records. ?signal= limits the response to one signal and ?tenant=
+// selects the tenant of templated topics. With authentication enabled the tenant is
+// always the caller's, and samples of shared topics are limited to the caller's records.
+func (d *deadLetterInspector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := d.tm.CreateSpan(r.Context(), "dlq.admin",
+		trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.url", r.URL.String()),
+		),
+	)
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dlq := d.config.Kafka.Topics.DeadLetter
+	tenant := r.URL.Query().Get("tenant")
+	if tenant != "" && !validTenantID(tenant) {
+		http.Error(w, fmt.Sprintf("Invalid tenant %q", tenant), http.StatusBadRequest)
+		return
+	}
+	restricted := d.config.Auth.Enabled
+	if restricted {
+		caller := tenantFromContext(r.Context())
+		if tenant != "" && tenant != caller {
+			http.Error(w, fmt.Sprintf("Not allowed to inspect tenant %q", tenant), http.StatusForbidden)
+			return
+		}
+		tenant = caller
+	}
+	templates := map[string]string{
+		string(otlp.SignalTraces):  dlq.Traces,
+		string(otlp.SignalMetrics): dlq.Metrics,
+		string(otlp.SignalLogs):    dlq.Logs,
+	}
+	signals := []string{string(otlp.SignalTraces), string(otlp.SignalMetrics), string(otlp.SignalLogs)}
+	if signal := r.URL.Query().Get("signal"); signal != "" {
+		if _, ok := templates[signal]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown signal %q", signal), http.StatusBadRequest)
+			return
+		}
+		signals = []string{signal}
+	}
+
+	samples := 0
+	if v := r.URL.Query().Get("sample"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "sample must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		samples = min(n, maxDeadLetterSamples)
+	}
+
+	client, err := d.client.get(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to connect to Kafka")
+		d.tm.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to connect to Kafka", zap.Error(err))
+		http.Error(w, "Failed to connect to Kafka", http.StatusServiceUnavailable)
+		return
+	}
+
+	statuses := make([]deadLetterTopicStatus, 0, len(signals))
+	for _, signal := range signals {
+		topic := expandTenantTopic(templates[signal], tenant)
+		// A tenant only sees its own records of a topic shared with other tenants
+		onlyTenant := ""
+		if restricted && !strings.Contains(templates[signal], tenantPlaceholder) {
+			onlyTenant = tenant
+		}
+		status, err := inspectDeadLetterTopic(client, signal, topic, samples, onlyTenant)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to inspect dead-letter topic")
+			d.tm.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to inspect dead-letter topic",
+				zap.Error(err),
+				zap.String("topic", topic),
+			)
+			http.Error(w, "Failed to inspect dead-letter topic", http.StatusServiceUnavailable)
+			return
+		}
+		statuses = append(statuses, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"topics": statuses})
+
+	span.SetAttributes(attribute.Int("http.status_code", http.StatusOK))
+	span.SetStatus(codes.Ok, "Dead-letter topics inspected")
+}
+
+// inspectDeadLetterTopic counts the records in a dead-letter topic and samples the most
+// recent ones. A topic that does not exist yet is reported as empty. With onlyTenant set,
+// the count is left out and only that tenant's samples are kept.
+func inspectDeadLetterTopic(client sarama.Client, signal, topic string, samples int, onlyTenant string) (deadLetterTopicStatus, error) {
+	status := deadLetterTopicStatus{Signal: signal, Topic: topic}
+	var count int64
+	if onlyTenant == "" {
+		status.Count = &count
+	}
+
+	partitions, err := client.Partitions(topic)
+	if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to list partitions of %s: %w", topic, err)
+	}
+
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return status, fmt.Errorf("failed to get oldest offset of %s/%d: %w", topic, partition, err)
+		}
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return status, fmt.Errorf("failed to get newest offset of %s/%d: %w", topic, partition, err)
+		}
+		count += newest - oldest
+
+		if samples > 0 && newest > oldest {
+			partitionSamples, err := sampleDeadLetterPartition(client, topic, partition, max(oldest, newest-int64(samples)), newest)
+			if err != nil {
+				return status, err
+			}
+			for _, sample := range partitionSamples {
+				if onlyTenant == "" || sample.Headers[headerDeadLetterTenant] == onlyTenant {
+					status.Samples = append(status.Samples, sample)
+				}
+			}
+		}
+	}
+
+	// Keep the most recent samples across all partitions
+	sort.Slice(status.Samples, func(i, j int) bool {
+		return status.Samples[i].Timestamp.After(status.Samples[j].Timestamp)
+	})
+	if len(status.Samples) > samples {
+		status.Samples = status.Samples[:samples]
+	}
+	return status, nil
+}
+
+// sampleDeadLetterPartition reads the records of a partition from offset start up to end
+func sampleDeadLetterPartition(client sarama.Client, topic string, partition int32, start, end int64) ([]deadLetterSample, error) {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	partitionConsumer, err := consumer.ConsumePartition(topic, partition, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume %s/%d: %w", topic, partition, err)
+	}
+	defer partitionConsumer.Close()
+
+	timeout := time.NewTimer(deadLetterSampleTimeout)
+	defer timeout.Stop()
+
+	var samples []deadLetterSample
+	for {
+		select {
+		case message, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return samples, nil
+			}
+			samples = append(samples, newDeadLetterSample(message))
+			if message.Offset >= end-1 {
+				return samples, nil
+			}
+		case <-timeout.C:
+			return samples, nil
+		}
+	}
+}
+
+// newDeadLetterSample converts a consumed record, keeping JSON values readable and
+// encoding binary values such as protobuf request bodies as base64
+func newDeadLetterSample(message *sarama.ConsumerMessage) deadLetterSample {
+	sample := deadLetterSample{
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Timestamp: message.Timestamp,
+		Key:       string(message.Key),
+		Headers:   make(map[string]string, len(message.Headers)),
+	}
+	for _, h := range message.Headers {
+		sample.Headers[string(h.Key)] = string(h.Value)
+	}
+
+	switch {
+	case json.Valid(message.Value):
+		sample.Value = json.RawMessage(message.Value)
+	case utf8.Valid(message.Value):
+		sample.Value = string(message.Value)
+	default:
+		sample.Value = message.Value
+	}
+	return sample
+}
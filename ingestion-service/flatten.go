@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Kafka output modes
+const (
+	outputModeEnvelope = "envelope"
+	outputModeFlatten  = "flatten"
+)
+
+// kafkaRecord is a single message value with its partition key and any record-specific headers
+type kafkaRecord struct {
+	key     string
+	value   interface{}
+	headers map[string]string
+}
+
+// SpanRecord is a flattened span as published in flatten output mode
+type SpanRecord struct {
+	TraceID            string                   `json:"trace_id"`
+	SpanID             string                   `json:"span_id"`
+	ParentSpanID       string                   `json:"parent_span_id,omitempty"`
+	TraceState         string                   `json:"trace_state,omitempty"`
+	ServiceName        string                   `json:"service_name"`
+	OperationName      string                   `json:"operation_name"`
+	SpanKind           string                   `json:"span_kind"`
+	StartTime          uint64                   `json:"start_time"`
+	EndTime            uint64                   `json:"end_time"`
+	DurationNanos      uint64                   `json:"duration_nanos"`
+	Attributes         map[string]interface{}   `json:"attributes"`
+	ResourceAttributes map[string]interface{}   `json:"resource_attributes"`
+	ScopeName          string                   `json:"scope_name,omitempty"`
+	ScopeVersion       string                   `json:"scope_version,omitempty"`
+	ScopeAttributes    map[string]interface{}   `json:"scope_attributes,omitempty"`
+	Events             []map[string]interface{} `json:"events"`
+	Links              []map[string]interface{} `json:"links,omitempty"`
+	StatusCode         string                   `json:"status_code"`
+	StatusMessage      string                   `json:"status_message,omitempty"`
+	IngestTimestamp    int64                    `json:"ingest_timestamp"`
+}
+
+// MetricRecord is a flattened metric data point as published in flatten output mode
+type MetricRecord struct {
+	MetricName             string                 `json:"metric_name"`
+	MetricType             string                 `json:"metric_type"`
+	Description            string                 `json:"description,omitempty"`
+	Unit                   string                 `json:"unit,omitempty"`
+	Value                  *float64               `json:"value,omitempty"`
+	Count                  *uint64                `json:"count,omitempty"`
+	Sum                    *float64               `json:"sum,omitempty"`
+	Min                    *float64               `json:"min,omitempty"`
+	Max                    *float64               `json:"max,omitempty"`
+	BucketCounts           []uint64               `json:"bucket_counts,omitempty"`
+	ExplicitBounds         []float64              `json:"explicit_bounds,omitempty"`
+	Scale                  *int32                 `json:"scale,omitempty"`
+	ZeroCount              *uint64                `json:"zero_count,omitempty"`
+	PositiveBuckets        *otlp.Buckets          `json:"positive_buckets,omitempty"`
+	NegativeBuckets        *otlp.Buckets          `json:"negative_buckets,omitempty"`
+	Quantiles              map[string]float64     `json:"quantiles,omitempty"`
+	AggregationTemporality string                 `json:"aggregation_temporality,omitempty"`
+	Timestamp              uint64                 `json:"timestamp"`
+	StartTimestamp         uint64                 `json:"start_timestamp,omitempty"`
+	ServiceName            string                 `json:"service_name"`
+	Labels                 map[string]interface{} `json:"labels"`
+	ResourceAttributes     map[string]interface{} `json:"resource_attributes"`
+	ScopeName              string                 `json:"scope_name,omitempty"`
+	ScopeVersion           string                 `json:"scope_version,omitempty"`
+	ScopeAttributes        map[string]interface{} `json:"scope_attributes,omitempty"`
+	IngestTimestamp        int64                  `json:"ingest_timestamp"`
+}
+
+// LogRecord is a flattened log record as published in flatten output mode
+type LogRecord struct {
+	TraceID            string                 `json:"trace_id,omitempty"`
+	SpanID             string                 `json:"span_id,omitempty"`
+	ServiceName        string                 `json:"service_name"`
+	LogLevel           string                 `json:"log_level"`
+	SeverityNumber     int32                  `json:"severity_number"`
+	Message            string                 `json:"message"`
+	Timestamp          uint64                 `json:"timestamp"`
+	ObservedTimestamp  uint64                 `json:"observed_timestamp,omitempty"`
+	Attributes         map[string]interface{} `json:"attributes"`
+	ResourceAttributes map[string]interface{} `json:"resource_attributes"`
+	ScopeName          string                 `json:"scope_name,omitempty"`
+	ScopeVersion       string                 `json:"scope_version,omitempty"`
+	ScopeAttributes    map[string]interface{} `json:"scope_attributes,omitempty"`
+	IngestTimestamp    int64                  `json:"ingest_timestamp"`
+}
+
+// flattenPayload explodes an OTLP payload into one Kafka record per span, data point or log record
+func flattenPayload(payload otlp.Payload, keyStrategy string, ingestTime time.Time) []kafkaRecord {
+	switch p := payload.(type) {
+	case *otlp.Traces:
+		return flattenTraces(p, keyStrategy, ingestTime)
+	case *otlp.Metrics:
+		return flattenMetrics(p, keyStrategy, ingestTime)
+	case *otlp.Logs:
+		return flattenLogs(p, keyStrategy, ingestTime)
+	default:
+		return nil
+	}
+}
+
+// flattenTraces converts each span into a SpanRecord
+func flattenTraces(traces *otlp.Traces, keyStrategy string, ingestTime time.Time) []kafkaRecord {
+	records := make([]kafkaRecord, 0, traces.ItemCount())
+	for _, rs := range traces.ResourceSpans {
+		rk := newResourceKey(rs.Resource)
+		resourceAttrs := otlp.AttributeMap(rs.Resource.Attributes)
+		for _, ss := range rs.ScopeSpans {
+			scopeAttrs := scopeAttributeMap(ss.Scope)
+			for _, span := range ss.Spans {
+				record := SpanRecord{
+					TraceID:            span.TraceID,
+					SpanID:             span.SpanID,
+					ParentSpanID:       span.ParentSpanID,
+					TraceState:         span.TraceState,
+					ServiceName:        rk.serviceName,
+					OperationName:      span.Name,
+					SpanKind:           spanKindName(span.Kind),
+					StartTime:          uint64(span.StartTimeUnixNano),
+					EndTime:            uint64(span.EndTimeUnixNano),
+					Attributes:         otlp.AttributeMap(span.Attributes),
+					ResourceAttributes: resourceAttrs,
+					ScopeName:          ss.Scope.Name,
+					ScopeVersion:       ss.Scope.Version,
+					ScopeAttributes:    scopeAttrs,
+					Events:             make([]map[string]interface{}, 0, len(span.Events)),
+					StatusCode:         statusCodeName(span.Status.Code),
+					StatusMessage:      span.Status.Message,
+					IngestTimestamp:    ingestTime.UnixNano(),
+				}
+				if span.EndTimeUnixNano > span.StartTimeUnixNano {
+					record.DurationNanos = uint64(span.EndTimeUnixNano - span.StartTimeUnixNano)
+				}
+				for _, event := range span.Events {
+					record.Events = append(record.Events, map[string]interface{}{
+						"name":       event.Name,
+						"timestamp":  uint64(event.TimeUnixNano),
+						"attributes": otlp.AttributeMap(event.Attributes),
+					})
+				}
+				for _, link := range span.Links {
+					record.Links = append(record.Links, map[string]interface{}{
+						"trace_id":   link.TraceID,
+						"span_id":    link.SpanID,
+						"attributes": otlp.AttributeMap(link.Attributes),
+					})
+				}
+				records = append(records, kafkaRecord{key: spanPartitionKey(keyStrategy, rk, span), value: record})
+			}
+		}
+	}
+	return records
+}
+
+// flattenMetrics converts each metric data point into a MetricRecord
+func flattenMetrics(metrics *otlp.Metrics, keyStrategy string, ingestTime time.Time) []kafkaRecord {
+	records := make([]kafkaRecord, 0, metrics.ItemCount())
+	for _, rm := range metrics.ResourceMetrics {
+		rk := newResourceKey(rm.Resource)
+		resourceAttrs := otlp.AttributeMap(rm.Resource.Attributes)
+		for _, sm := range rm.ScopeMetrics {
+			scopeAttrs := scopeAttributeMap(sm.Scope)
+			for _, metric := range sm.Metrics {
+				base := MetricRecord{
+					MetricName:         metric.Name,
+					MetricType:         metricTypeName(metric),
+					Description:        metric.Description,
+					Unit:               metric.Unit,
+					ServiceName:        rk.serviceName,
+					ResourceAttributes: resourceAttrs,
+					ScopeName:          sm.Scope.Name,
+					ScopeVersion:       sm.Scope.Version,
+					ScopeAttributes:    scopeAttrs,
+					IngestTimestamp:    ingestTime.UnixNano(),
+				}
+				key := metricPartitionKey(keyStrategy, rk, metric)
+				for _, record := range metricDataPointRecords(metric, base) {
+					records = append(records, kafkaRecord{key: key, value: record})
+				}
+			}
+		}
+	}
+	return records
+}
+
+// metricDataPointRecords returns one MetricRecord per data point, copying the shared fields from base
+func metricDataPointRecords(metric otlp.Metric, base MetricRecord) []MetricRecord {
+	records := make([]MetricRecord, 0, metric.DataPointCount())
+	switch {
+	case metric.Gauge != nil:
+		for _, dp := range metric.Gauge.DataPoints {
+			records = append(records, numberDataPointRecord(base, dp))
+		}
+	case metric.Sum != nil:
+		base.AggregationTemporality = temporalityName(metric.Sum.AggregationTemporality)
+		for _, dp := range metric.Sum.DataPoints {
+			records = append(records, numberDataPointRecord(base, dp))
+		}
+	case metric.Histogram != nil:
+		base.AggregationTemporality = temporalityName(metric.Histogram.AggregationTemporality)
+		for _, dp := range metric.Histogram.DataPoints {
+			record := base
+			record.Labels = otlp.AttributeMap(dp.Attributes)
+			record.Timestamp = uint64(dp.TimeUnixNano)
+			record.StartTimestamp = uint64(dp.StartTimeUnixNano)
+			count := uint64(dp.Count)
+			record.Count = &count
+			record.Sum = dp.Sum
+			record.Min = dp.Min
+			record.Max = dp.Max
+			record.BucketCounts = uint64Slice(dp.BucketCounts)
+			record.ExplicitBounds = dp.ExplicitBounds
+			records = append(records, record)
+		}
+	case metric.ExponentialHistogram != nil:
+		base.AggregationTemporality = temporalityName(metric.ExponentialHistogram.AggregationTemporality)
+		for _, dp := range metric.ExponentialHistogram.DataPoints {
+			dp := dp
+			record := base
+			record.Labels = otlp.AttributeMap(dp.Attributes)
+			record.Timestamp = uint64(dp.TimeUnixNano)
+			record.StartTimestamp = uint64(dp.StartTimeUnixNano)
+			count, zeroCount := uint64(dp.Count), uint64(dp.ZeroCount)
+			record.Count = &count
+			record.Sum = dp.Sum
+			record.Min = dp.Min
+			record.Max = dp.Max
+			record.Scale = &dp.Scale
+			record.ZeroCount = &zeroCount
+			record.PositiveBuckets = &dp.Positive
+			record.NegativeBuckets = &dp.Negative
+			records = append(records, record)
+		}
+	case metric.Summary != nil:
+		for _, dp := range metric.Summary.DataPoints {
+			dp := dp
+			record := base
+			record.Labels = otlp.AttributeMap(dp.Attributes)
+			record.Timestamp = uint64(dp.TimeUnixNano)
+			record.StartTimestamp = uint64(dp.StartTimeUnixNano)
+			count := uint64(dp.Count)
+			record.Count = &count
+			record.Sum = &dp.Sum
+			record.Quantiles = make(map[string]float64, len(dp.QuantileValues))
+			for _, q := range dp.QuantileValues {
+				record.Quantiles[fmt.Sprintf("%g", q.Quantile)] = q.Value
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// numberDataPointRecord fills in a MetricRecord for a gauge or sum data point
+func numberDataPointRecord(base MetricRecord, dp otlp.NumberDataPoint) MetricRecord {
+	record := base
+	value := dp.Value()
+	record.Value = &value
+	record.Labels = otlp.AttributeMap(dp.Attributes)
+	record.Timestamp = uint64(dp.TimeUnixNano)
+	record.StartTimestamp = uint64(dp.StartTimeUnixNano)
+	return record
+}
+
+// flattenLogs converts each log record into a LogRecord
+func flattenLogs(logs *otlp.Logs, keyStrategy string, ingestTime time.Time) []kafkaRecord {
+	records := make([]kafkaRecord, 0, logs.ItemCount())
+	for _, rl := range logs.ResourceLogs {
+		rk := newResourceKey(rl.Resource)
+		resourceAttrs := otlp.AttributeMap(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			scopeAttrs := scopeAttributeMap(sl.Scope)
+			for _, lr := range sl.LogRecords {
+				record := LogRecord{
+					TraceID:            lr.TraceID,
+					SpanID:             lr.SpanID,
+					ServiceName:        rk.serviceName,
+					LogLevel:           logLevelName(lr.SeverityText, lr.SeverityNumber),
+					SeverityNumber:     lr.SeverityNumber,
+					Timestamp:          uint64(lr.TimeUnixNano),
+					ObservedTimestamp:  uint64(lr.ObservedTimeUnixNano),
+					Attributes:         otlp.AttributeMap(lr.Attributes),
+					ResourceAttributes: resourceAttrs,
+					ScopeName:          sl.Scope.Name,
+					ScopeVersion:       sl.Scope.Version,
+					ScopeAttributes:    scopeAttrs,
+					IngestTimestamp:    ingestTime.UnixNano(),
+				}
+				// Fall back to the observed time when the source did not set a timestamp
+				if record.Timestamp == 0 {
+					record.Timestamp = record.ObservedTimestamp
+				}
+				if lr.Body != nil {
+					record.Message = lr.Body.AsString()
+				}
+				records = append(records, kafkaRecord{key: logPartitionKey(keyStrategy, rk, lr), value: record})
+			}
+		}
+	}
+	return records
+}
+
+// scopeAttributeMap returns the scope's attributes, or nil when it has none
+func scopeAttributeMap(scope otlp.Scope) map[string]interface{} {
+	if len(scope.Attributes) == 0 {
+		return nil
+	}
+	return otlp.AttributeMap(scope.Attributes)
+}
+
+// uint64Slice converts OTLP counters to plain integers
+func uint64Slice(values []otlp.Uint64) []uint64 {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]uint64, len(values))
+	for i, v := range values {
+		out[i] = uint64(v)
+	}
+	return out
+}
+
+// spanKindName returns the name of an OTLP span kind
+func spanKindName(kind int32) string {
+	switch kind {
+	case 1:
+		return "INTERNAL"
+	case 2:
+		return "SERVER"
+	case 3:
+		return "CLIENT"
+	case 4:
+		return "PRODUCER"
+	case 5:
+		return "CONSUMER"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+// statusCodeName returns the name of an OTLP span status code
+func statusCodeName(code int32) string {
+	switch code {
+	case otlp.StatusCodeOk:
+		return "OK"
+	case otlp.StatusCodeError:
+		return "ERROR"
+	default:
+		return "UNSET"
+	}
+}
+
+// metricTypeName returns the flattened metric type, reporting monotonic sums as counters
+func metricTypeName(metric otlp.Metric) string {
+	if metric.Sum != nil && metric.Sum.IsMonotonic {
+		return "counter"
+	}
+	return metric.Type()
+}
+
+// temporalityName returns the name of an OTLP aggregation temporality
+func temporalityName(temporality int32) string {
+	switch temporality {
+	case otlp.AggregationTemporalityDelta:
+		return "delta"
+	case otlp.AggregationTemporalityCumulative:
+		return "cumulative"
+	default:
+		return ""
+	}
+}
+
+// logLevelName returns the log level, preferring the severity text sent by the client
+func logLevelName(severityText string, severityNumber int32) string {
+	if severityText != "" {
+		return severityText
+	}
+	switch {
+	case severityNumber >= 21:
+		return "FATAL"
+	case severityNumber >= 17:
+		return "ERROR"
+	case severityNumber >= 13:
+		return "WARN"
+	case severityNumber >= 9:
+		return "INFO"
+	case severityNumber >= 5:
+		return "DEBUG"
+	case severityNumber >= 1:
+		return "TRACE"
+	default:
+		return "UNSPECIFIED"
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// testResource is a resource of the checkout service for flattened test payloads
+const testResource = `"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]}`
+
+func TestFlattenPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal otlp.Signal
+		body   string
+		want   []string // JSON of each record's value
+	}{
+		{
+			name:   "span",
+			signal: otlp.SignalTraces,
+			body: `{"resourceSpans":[{` + testResource + `,"scopeSpans":[{"scope":{"name":"http","version":"1.0"},"spans":[{` +
+				testTraceID + `,` + testSpanID + `,"name":"GET /cart","kind":2,"startTimeUnixNano":"100","endTimeUnixNano":"350",` +
+				`"attributes":[{"key":"http.status_code","value":{"intValue":"200"}}],` +
+				`"events":[{"name":"retry","timeUnixNano":"200"}],"status":{"code":2,"message":"timeout"}}]}]}]}`,
+			want: []string{`{"trace_id":"0102030405060708090a0b0c0d0e0f10","span_id":"0102030405060708","service_name":"checkout",` +
+				`"operation_name":"GET /cart","span_kind":"SERVER","start_time":100,"end_time":350,"duration_nanos":250,` +
+				`"attributes":{"http.status_code":200},"resource_attributes":{"service.name":"checkout"},"scope_name":"http","scope_version":"1.0",` +
+				`"events":[{"attributes":{},"name":"retry","timestamp":200}],"status_code":"ERROR","status_message":"timeout","ingest_timestamp":7}`},
+		},
+		{
+			name:   "one record per span",
+			signal: otlp.SignalTraces,
+			body: `{"resourceSpans":[{` + testResource + `,"scopeSpans":[{"spans":[{` + testTraceID + `,"spanId":"01"},{` +
+				testTraceID + `,"spanId":"02","startTimeUnixNano":"5","endTimeUnixNano":"4"}]}]}]}`,
+			want: []string{
+				`{"trace_id":"0102030405060708090a0b0c0d0e0f10","span_id":"01","service_name":"checkout","operation_name":"","span_kind":"UNSPECIFIED",` +
+					`"start_time":0,"end_time":0,"duration_nanos":0,"attributes":{},"resource_attributes":{"service.name":"checkout"},` +
+					`"events":[],"status_code":"UNSET","ingest_timestamp":7}`,
+				`{"trace_id":"0102030405060708090a0b0c0d0e0f10","span_id":"02","service_name":"checkout","operation_name":"","span_kind":"UNSPECIFIED",` +
+					`"start_time":5,"end_time":4,"duration_nanos":0,"attributes":{},"resource_attributes":{"service.name":"checkout"},` +
+					`"events":[],"status_code":"UNSET","ingest_timestamp":7}`,
+			},
+		},
+		{
+			name:   "monotonic sum data points",
+			signal: otlp.SignalMetrics,
+			body: `{"resourceMetrics":[{` + testResource + `,"scopeMetrics":[{"metrics":[{"name":"requests","unit":"1","sum":{` +
+				`"aggregationTemporality":1,"isMonotonic":true,"dataPoints":[` +
+				`{"asInt":"3","timeUnixNano":"20","attributes":[{"key":"route","value":{"stringValue":"/a"}}]},` +
+				`{"asDouble":1.5,"timeUnixNano":"30"}]}}]}]}]}`,
+			want: []string{
+				`{"metric_name":"requests","metric_type":"counter","unit":"1","value":3,"aggregation_temporality":"delta","timestamp":20,` +
+					`"service_name":"checkout","labels":{"route":"/a"},"resource_attributes":{"service.name":"checkout"},"ingest_timestamp":7}`,
+				`{"metric_name":"requests","metric_type":"counter","unit":"1","value":1.5,"aggregation_temporality":"delta","timestamp":30,` +
+					`"service_name":"checkout","labels":{},"resource_attributes":{"service.name":"checkout"},"ingest_timestamp":7}`,
+			},
+		},
+		{
+			name:   "histogram data point",
+			signal: otlp.SignalMetrics,
+			body: `{"resourceMetrics":[{` + testResource + `,"scopeMetrics":[{"metrics":[{"name":"latency","histogram":{` +
+				`"aggregationTemporality":2,"dataPoints":[{"count":"3","sum":6,"bucketCounts":["1","2"],"explicitBounds":[2],` +
+				`"startTimeUnixNano":"10","timeUnixNano":"20"}]}}]}]}]}`,
+			want: []string{`{"metric_name":"latency","metric_type":"histogram","count":3,"sum":6,"bucket_counts":[1,2],"explicit_bounds":[2],` +
+				`"aggregation_temporality":"cumulative","timestamp":20,"start_timestamp":10,"service_name":"checkout","labels":{},` +
+				`"resource_attributes":{"service.name":"checkout"},"ingest_timestamp":7}`},
+		},
+		{
+			name:   "summary data point",
+			signal: otlp.SignalMetrics,
+			body: `{"resourceMetrics":[{` + testResource + `,"scopeMetrics":[{"metrics":[{"name":"size","summary":{"dataPoints":[` +
+				`{"count":"2","sum":8,"quantileValues":[{"quantile":0.5,"value":3},{"quantile":1,"value":5}],"timeUnixNano":"20"}]}}]}]}]}`,
+			want: []string{`{"metric_name":"size","metric_type":"summary","count":2,"sum":8,"quantiles":{"0.5":3,"1":5},"timestamp":20,` +
+				`"service_name":"checkout","labels":{},"resource_attributes":{"service.name":"checkout"},"ingest_timestamp":7}`},
+		},
+		{
+			name:   "log record",
+			signal: otlp.SignalLogs,
+			body: `{"resourceLogs":[{` + testResource + `,"scopeLogs":[{"logRecords":[` +
+				`{"severityNumber":17,"observedTimeUnixNano":"40","body":{"stringValue":"payment failed"},` + testTraceID + `},` +
+				`{"severityText":"notice","severityNumber":10,"timeUnixNano":"50","body":{"intValue":"7"}}]}]}]}`,
+			want: []string{
+				`{"trace_id":"0102030405060708090a0b0c0d0e0f10","service_name":"checkout","log_level":"ERROR","severity_number":17,` +
+					`"message":"payment failed","timestamp":40,"observed_timestamp":40,"attributes":{},"resource_attributes":{"service.name":"checkout"},"ingest_timestamp":7}`,
+				`{"service_name":"checkout","log_level":"notice","severity_number":10,"message":"7","timestamp":50,"attributes":{},` +
+					`"resource_attributes":{"service.name":"checkout"},"ingest_timestamp":7}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := decodeTestPayload(t, tt.signal, tt.body)
+			records := flattenPayload(payload, partitionKeyNone, time.Unix(0, 7))
+			if len(records) != len(tt.want) {
+				t.Fatalf("flattenPayload() returned %d records, want %d", len(records), len(tt.want))
+			}
+			for i, record := range records {
+				got, err := json.Marshal(record.value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want[i] {
+					t.Errorf("record %d =\n%s\nwant\n%s", i, got, tt.want[i])
+				}
+				if record.key != "" {
+					t.Errorf("record %d key = %q, want none", i, record.key)
+				}
+			}
+		})
+	}
+}
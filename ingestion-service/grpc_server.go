@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // register the gzip compressor used by OTLP exporters
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// otlpGRPCReceiver implements the OTLP/gRPC trace, metrics and logs services
+type otlpGRPCReceiver struct {
+	config        *Config
+	kafkaProducer *KafkaProducer
+	tenants       *tenantRegistry
+	limiter       *rateLimiter
+	validator     *payloadValidator
+	redactor      *payloadRedactor
+	tm            *TelemetryManager
+	signals       otlpSignals
+}
+
+// traceService adapts the receiver to the OTLP TraceService
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	receiver *otlpGRPCReceiver
+}
+
+// Export handles an OTLP/gRPC trace export request
+func (s *traceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	resp, err := s.receiver.export(ctx, s.receiver.signals.traces, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*coltracepb.ExportTraceServiceResponse), nil
+}
+
+// metricsService adapts the receiver to the OTLP MetricsService
+type metricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	receiver *otlpGRPCReceiver
+}
+
+// Export handles an OTLP/gRPC metrics export request
+func (s *metricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	resp, err := s.receiver.export(ctx, s.receiver.signals.metrics, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*colmetricspb.ExportMetricsServiceResponse), nil
+}
+
+// logsService adapts the receiver to the OTLP LogsService
+type logsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	receiver *otlpGRPCReceiver
+}
+
+// Export handles an OTLP/gRPC logs export request
+func (s *logsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	resp, err := s.receiver.export(ctx, s.receiver.signals.logs, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*collogspb.ExportLogsServiceResponse), nil
+}
+
+// export converts an OTLP request to the OTLP model and sends it to Kafka, returning the
+// signal's export response or a gRPC status error on failure
+func (rcv *otlpGRPCReceiver) export(ctx context.Context, signal otlpSignal, req proto.Message) (proto.Message, error) {
+	ctx, span := rcv.tm.CreateSpan(ctx, fmt.Sprintf("otlp.%s.receive", signal.name),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.Int("rpc.request.size", proto.Size(req)),
+			attribute.String("otlp.signal", signal.name),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	statusCode := grpccodes.OK
+	defer func() {
+		rcv.tm.metrics.recordRequest(ctx, signal.name, "grpc", proto.Size(req), start, statusCode.String())
+	}()
+
+	payload, err := otlp.FromProto(req)
+	if err != nil {
+		if body, marshalErr := proto.Marshal(req); marshalErr == nil {
+			rcv.kafkaProducer.deadLetterUndecodable(ctx, signal, body, contentTypeProtobuf, err)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Invalid OTLP payload")
+		statusCode = grpccodes.InvalidArgument
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", grpccodes.InvalidArgument.String()))
+		return nil, status.Errorf(grpccodes.InvalidArgument, "invalid %s payload: %v", signal.name, err)
+	}
+
+	result, err := processOTLPData(ctx, rcv.tm, rcv.kafkaProducer, rcv.tenants, rcv.limiter, rcv.validator, rcv.redactor, signal, "grpc", payload, proto.Size(req))
+	var invalid *validationError
+	if errors.As(err, &invalid) {
+		rcv.kafkaProducer.deadLetterInvalid(ctx, signal, payload, err)
+		span.SetStatus(codes.Error, "Invalid OTLP payload")
+		statusCode = grpccodes.InvalidArgument
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", grpccodes.InvalidArgument.String()))
+		return nil, status.Error(grpccodes.InvalidArgument, err.Error())
+	}
+	var limitErr *rateLimitError
+	if errors.As(err, &limitErr) {
+		span.SetStatus(codes.Error, "Rate limit exceeded")
+		statusCode = grpccodes.ResourceExhausted
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", grpccodes.ResourceExhausted.String()))
+		return nil, retryableStatus(grpccodes.ResourceExhausted, limitErr.Error(), limitErr.retryAfter)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, fmt.Sprintf("Failed to send %s to Kafka", signal.name))
+		statusCode = grpccodes.Unavailable
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", grpccodes.Unavailable.String()))
+		// Unavailable is retryable per the OTLP specification, so SDKs will resend the batch
+		return nil, retryableStatus(grpccodes.Unavailable, fmt.Sprintf("failed to send %s to Kafka: %v", signal.name, err), rcv.config.Server.RetryAfter)
+	}
+
+	span.SetAttributes(
+		attribute.String("rpc.grpc.status_code", grpccodes.OK.String()),
+		attribute.Int64(fmt.Sprintf("otlp.rejected_%s", signal.itemsField), result.rejected),
+	)
+	return signal.newResponse(result), nil
+}
+
+// retryableStatus returns a status carrying the retry delay clients should wait, which OTLP
+// exporters require before retrying RESOURCE_EXHAUSTED
+func retryableStatus(code grpccodes.Code, message string, retryAfter time.Duration) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// startGRPCOTLPServerWithTracing starts the OTLP/gRPC server with tracing.
+// It returns nil if the listener could not be opened.
+func startGRPCOTLPServerWithTracing(config *Config, kafkaProducer *KafkaProducer, auth *authenticator, tenants *tenantRegistry, limiter *rateLimiter, validator *payloadValidator, redactor *payloadRedactor, admission *admissionController, health *healthRegistry, serverTLS *tlsReloader, logger *zap.Logger, tm *TelemetryManager) *grpc.Server {
+	listener, err := net.Listen("tcp", config.Server.GRPCEndpoint)
+	if err != nil {
+		logger.Error("gRPC OTLP server failed to listen", zap.Error(err))
+		health.ReportFatal(fmt.Errorf("gRPC OTLP server failed to listen: %w", err))
+		if serverTLS != nil {
+			serverTLS.Close()
+		}
+		return nil
+	}
+
+	// Instrument the server with OpenTelemetry gRPC stats handler; the receive limit
+	// applies after decompression, matching the HTTP receiver's decompressed cap
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.MaxRecvMsgSize(int(config.Server.MaxDecompressedBodySize)),
+		grpc.ChainUnaryInterceptor(auth.unaryInterceptor, tenants.unaryInterceptor, admission.unaryInterceptor),
+	}
+	if serverTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(serverTLS.TLSConfig())))
+	}
+	server := grpc.NewServer(opts...)
+
+	receiver := &otlpGRPCReceiver{
+		config:        config,
+		kafkaProducer: kafkaProducer,
+		tenants:       tenants,
+		limiter:       limiter,
+		validator:     validator,
+		redactor:      redactor,
+		tm:            tm,
+		signals:       newOTLPSignals(config),
+	}
+	coltracepb.RegisterTraceServiceServer(server, &traceService{receiver: receiver})
+	colmetricspb.RegisterMetricsServiceServer(server, &metricsService{receiver: receiver})
+	collogspb.RegisterLogsServiceServer(server, &logsService{receiver: receiver})
+
+	logger.Info("gRPC OTLP server starting", zap.String("endpoint", config.Server.GRPCEndpoint), zap.Bool("tls", serverTLS != nil))
+	go func() {
+		if serverTLS != nil {
+			defer serverTLS.Close()
+		}
+		if err := server.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			logger.Error("gRPC OTLP server failed", zap.Error(err))
+			health.ReportFatal(fmt.Errorf("gRPC OTLP server failed: %w", err))
+		}
+	}()
+	return server
+}
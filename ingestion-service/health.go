@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// healthChecker is a single named probe run by the readiness or liveness endpoint
+type healthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// healthCheckResult is the outcome of one probe, reported in the endpoint's JSON body
+type healthCheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// healthRegistry holds the readiness and liveness probes. Readiness also reports whether
+// the service has finished starting and has not begun shutting down, and liveness fails
+// once a fatal error has been reported.
+type healthRegistry struct {
+	timeout   time.Duration
+	ready     atomic.Bool
+	fatalErr  atomic.Pointer[error]
+	mu        sync.RWMutex
+	readiness []healthChecker
+	liveness  []healthChecker
+}
+
+// newHealthRegistry creates a registry whose probes each get timeout to complete
+func newHealthRegistry(timeout time.Duration) *healthRegistry {
+	return &healthRegistry{timeout: timeout}
+}
+
+// AddReadiness registers a probe that must pass for the service to receive traffic
+func (h *healthRegistry) AddReadiness(checker healthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, checker)
+}
+
+// AddLiveness registers a probe that must pass for the service to keep running
+func (h *healthRegistry) AddLiveness(checker healthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, checker)
+}
+
+// SetReady marks the service as accepting traffic or not
+func (h *healthRegistry) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// ReportFatal records an unrecoverable error so that liveness fails and the service is restarted
+func (h *healthRegistry) ReportFatal(err error) {
+	h.fatalErr.CompareAndSwap(nil, &err)
+}
+
+// CheckReadiness runs the readiness probes
+func (h *healthRegistry) CheckReadiness(ctx context.Context) (bool, map[string]healthCheckResult) {
+	h.mu.RLock()
+	checkers := append([]healthChecker{healthCheckFunc{"lifecycle", func(context.Context) error {
+		if !h.ready.Load() {
+			return errors.New("service is starting or shutting down")
+		}
+		return nil
+	}}}, h.readiness...)
+	h.mu.RUnlock()
+	return h.run(ctx, checkers)
+}
+
+// CheckLiveness runs the liveness probes
+func (h *healthRegistry) CheckLiveness(ctx context.Context) (bool, map[string]healthCheckResult) {
+	h.mu.RLock()
+	checkers := append([]healthChecker{healthCheckFunc{"fatal", func(context.Context) error {
+		if err := h.fatalErr.Load(); err != nil {
+			return *err
+		}
+		return nil
+	}}}, h.liveness...)
+	h.mu.RUnlock()
+	return h.run(ctx, checkers)
+}
+
+// run executes the probes concurrently, failing any that exceed the probe timeout
+func (h *healthRegistry) run(ctx context.Context, checkers []healthChecker) (bool, map[string]healthCheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	healthy := true
+	results := make(map[string]healthCheckResult, len(checkers))
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker healthChecker) {
+			defer wg.Done()
+
+			start := time.Now()
+			done := make(chan error, 1)
+			go func() {
+				done <- checker.Check(ctx)
+			}()
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = fmt.Errorf("check timed out after %s", h.timeout)
+			}
+
+			result := healthCheckResult{
+				Status:     "ok",
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[checker.Name()] = result
+			if err != nil {
+				healthy = false
+			}
+		}(checker)
+	}
+	wg.Wait()
+	return healthy, results
+}
+
+// healthCheckFunc adapts a function to a healthChecker
+type healthCheckFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// Name returns the probe name
+func (f healthCheckFunc) Name() string { return f.name }
+
+// Check runs the probe
+func (f healthCheckFunc) Check(ctx context.Context) error { return f.check(ctx) }
+
+// kafkaHealthChecker verifies that the brokers answer metadata requests and that the
+// signal topics exist. It keeps its own client, connected on first use.
+type kafkaHealthChecker struct {
+	topics []string
+	client *lazyKafkaClient
+}
+
+// newKafkaHealthChecker creates a Kafka probe for the configured brokers and topics
+func newKafkaHealthChecker(config *Config) *kafkaHealthChecker {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Net.DialTimeout = config.Health.CheckTimeout
+	saramaConfig.Net.ReadTimeout = config.Health.CheckTimeout
+	saramaConfig.Net.WriteTimeout = config.Health.CheckTimeout
+	saramaConfig.Metadata.Retry.Max = 0
+	saramaConfig.Metadata.Full = false
+
+	return &kafkaHealthChecker{
+		topics: signalTopics(config),
+		client: newLazyKafkaClient(config.Kafka.Brokers, saramaConfig),
+	}
+}
+
+// Name returns the probe name
+func (k *kafkaHealthChecker) Name() string { return "kafka" }
+
+// Check connects if needed, refreshes the topic metadata and verifies every topic has
+// partitions. sarama calls take no context, so the connect and the metadata requests run
+// in the background and Check returns as soon as ctx is done; their own network timeouts
+// bound how long they last.
+func (k *kafkaHealthChecker) Check(ctx context.Context) error {
+	result := make(chan error, 1)
+	go func() {
+		client, err := k.client.get(ctx)
+		if err != nil {
+			result <- err
+			return
+		}
+		result <- k.checkTopics(client)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("failed to fetch Kafka metadata: %w", ctx.Err())
+	}
+}
+
+// checkTopics refreshes the topic metadata and verifies every topic has partitions
+func (k *kafkaHealthChecker) checkTopics(client sarama.Client) error {
+	if err := client.RefreshMetadata(k.topics...); err != nil {
+		return fmt.Errorf("failed to fetch Kafka metadata: %w", err)
+	}
+	if len(client.Brokers()) == 0 {
+		return errors.New("no Kafka brokers available")
+	}
+	for _, topic := range k.topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return fmt.Errorf("topic %s is unavailable: %w", topic, err)
+		}
+		if len(partitions) == 0 {
+			return fmt.Errorf("topic %s has no partitions", topic)
+		}
+	}
+	return nil
+}
+
+// Close closes the probe's Kafka client
+func (k *kafkaHealthChecker) Close() error {
+	return k.client.close()
+}
+
+// lazyKafkaClient is a Kafka client connected on first use and shared by its callers.
+// Concurrent callers wait for a single connect, which runs without holding the lock, so
+// an unreachable broker neither serializes callers nor opens one client per caller.
+type lazyKafkaClient struct {
+	brokers []string
+	config  *sarama.Config
+
+	mu         sync.Mutex
+	client     sarama.Client
+	connecting chan struct{}
+	err        error
+	closed     bool
+}
+
+// newLazyKafkaClient returns a client for brokers that connects on first use
+func newLazyKafkaClient(brokers []string, config *sarama.Config) *lazyKafkaClient {
+	return &lazyKafkaClient{brokers: brokers, config: config}
+}
+
+// get returns the client, connecting it or waiting for the pending connect, and gives up
+// when ctx is done. A failed connect, or a client closed underneath, is redone by the next
+// call.
+func (c *lazyKafkaClient) get(ctx context.Context) (sarama.Client, error) {
+	c.mu.Lock()
+	if c.client != nil && !c.closed && c.client.Closed() {
+		c.client = nil
+	}
+	if c.client != nil || c.closed {
+		client := c.client
+		c.mu.Unlock()
+		if client == nil {
+			return nil, errors.New("Kafka client is closed")
+		}
+		return client, nil
+	}
+	connecting := c.connecting
+	if connecting == nil {
+		connecting = make(chan struct{})
+		c.connecting = connecting
+		go c.connect(connecting)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-connecting:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", ctx.Err())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil, c.err
+	}
+	return c.client, nil
+}
+
+// connect dials the brokers and publishes the client or the error, then closes done
+func (c *lazyKafkaClient) connect(done chan struct{}) {
+	client, err := sarama.NewClient(c.brokers, c.config)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(done)
+	c.connecting = nil
+	switch {
+	case err != nil:
+		c.err = fmt.Errorf("failed to connect to Kafka: %w", err)
+	case c.closed:
+		client.Close()
+		c.err = errors.New("Kafka client is closed")
+	default:
+		c.client = client
+	}
+}
+
+// close closes the client; a connect still pending closes its client when it finishes
+func (c *lazyKafkaClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close()
+}
+
+// spoolDiskHealthChecker fails when the spool directory's filesystem is nearly full, as
+// spooling would then fail during a Kafka outage
+type spoolDiskHealthChecker struct {
+	directory    string
+	minFreeBytes int64
+}
+
+// Name returns the probe name
+func (s spoolDiskHealthChecker) Name() string { return "spool_disk" }
+
+// Check verifies the free space available to the spool
+func (s spoolDiskHealthChecker) Check(_ context.Context) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(s.directory, &stat); err != nil {
+		return fmt.Errorf("failed to stat spool directory: %w", err)
+	}
+	free := int64(stat.Bavail) * int64(stat.Bsize)
+	if free < s.minFreeBytes {
+		return fmt.Errorf("only %d bytes free for the spool, below %d", free, s.minFreeBytes)
+	}
+	return nil
+}
+
+// queueHealthChecker fails when the producer's in-flight queue is nearly full, so that
+// load balancers shift traffic away before requests start timing out
+type queueHealthChecker struct {
+	kafkaProducer *KafkaProducer
+	threshold     float64
+}
+
+// Name returns the probe name
+func (q queueHealthChecker) Name() string { return "producer_queue" }
+
+// Check compares the queue usage with the saturation threshold
+func (q queueHealthChecker) Check(_ context.Context) error {
+	used, size := len(q.kafkaProducer.inflight), cap(q.kafkaProducer.inflight)
+	if float64(used) >= q.threshold*float64(size) {
+		return fmt.Errorf("producer queue is saturated: %d of %d slots in use", used, size)
+	}
+	return nil
+}
+
+// producerStallHealthChecker fails when messages are in flight but the producer has not
+// completed any for the stall timeout, which means the pipeline is deadlocked
+type producerStallHealthChecker struct {
+	kafkaProducer *KafkaProducer
+	timeout       time.Duration
+}
+
+// Name returns the probe name
+func (p producerStallHealthChecker) Name() string { return "producer_progress" }
+
+// Check compares the time since the producer last completed a message with the stall timeout
+func (p producerStallHealthChecker) Check(_ context.Context) error {
+	if len(p.kafkaProducer.inflight) == 0 {
+		return nil
+	}
+	idle := time.Since(time.Unix(0, p.kafkaProducer.lastProgress.Load()))
+	if idle > p.timeout {
+		return fmt.Errorf("producer has not completed a message in %s with %d in flight", idle.Round(time.Second), len(p.kafkaProducer.inflight))
+	}
+	return nil
+}
+
+// registerHealthChecks adds the dependency probes for the configured features
+func registerHealthChecks(health *healthRegistry, config *Config, kafkaProducer *KafkaProducer, kafkaChecker *kafkaHealthChecker) {
+	health.AddReadiness(kafkaChecker)
+	health.AddReadiness(queueHealthChecker{
+		kafkaProducer: kafkaProducer,
+		threshold:     config.Health.QueueSaturationThreshold,
+	})
+	if config.Kafka.Spool.Enabled {
+		health.AddReadiness(spoolDiskHealthChecker{
+			directory:    config.Kafka.Spool.Directory,
+			minFreeBytes: config.Health.SpoolMinFreeBytes,
+		})
+	}
+	health.AddLiveness(producerStallHealthChecker{
+		kafkaProducer: kafkaProducer,
+		timeout:       config.Health.ProducerStallTimeout,
+	})
+}
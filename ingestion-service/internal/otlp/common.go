@@ -0,0 +1,214 @@
+// Package otlp provides a typed model of OTLP traces, metrics and logs export requests.
+//
+// The model mirrors the OTLP/JSON encoding: struct tags use the lowerCamelCase field
+// names from the specification, trace and span IDs are hex strings and 64-bit integers
+// are encoded as decimal strings. Payloads received as protobuf are converted into the
+// same model so the rest of the service handles both encodings identically.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Signal identifies an OTLP telemetry signal
+type Signal string
+
+// Supported OTLP signals
+const (
+	SignalTraces  Signal = "traces"
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+)
+
+// Payload is a decoded OTLP export request for a single signal
+type Payload interface {
+	// Signal returns the signal carried by the payload
+	Signal() Signal
+	// ItemCount returns the number of spans, metric data points or log records
+	ItemCount() int64
+}
+
+// Uint64 is an unsigned 64-bit integer encoded as a decimal string in JSON,
+// as required by OTLP/JSON. Both string and number forms are accepted on input.
+type Uint64 uint64
+
+// MarshalJSON encodes the value as a decimal string
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatUint(uint64(u), 10))), nil
+}
+
+// UnmarshalJSON decodes the value from a JSON string or number
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	s, ok := unquoteNumber(data)
+	if !ok {
+		*u = 0
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uint64 value %s: %w", data, err)
+	}
+	*u = Uint64(v)
+	return nil
+}
+
+// Int64 is a signed 64-bit integer encoded as a decimal string in JSON,
+// as required by OTLP/JSON. Both string and number forms are accepted on input.
+type Int64 int64
+
+// MarshalJSON encodes the value as a decimal string
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(i), 10))), nil
+}
+
+// UnmarshalJSON decodes the value from a JSON string or number
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	s, ok := unquoteNumber(data)
+	if !ok {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 value %s: %w", data, err)
+	}
+	*i = Int64(v)
+	return nil
+}
+
+// unquoteNumber strips the quotes from a JSON number encoded as a string.
+// It returns false for null and empty strings.
+func unquoteNumber(data []byte) (string, bool) {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return "", false
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	if len(data) == 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// AnyValue is an OTLP attribute or log body value. Exactly one field is expected to be set.
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *Int64        `json:"intValue,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  []byte        `json:"bytesValue,omitempty"`
+}
+
+// ArrayValue is a list of values
+type ArrayValue struct {
+	Values []AnyValue `json:"values,omitempty"`
+}
+
+// KeyValueList is a nested list of attributes
+type KeyValueList struct {
+	Values []KeyValue `json:"values,omitempty"`
+}
+
+// KeyValue is a single attribute
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// StringAnyValue returns an AnyValue holding s
+func StringAnyValue(s string) AnyValue {
+	return AnyValue{StringValue: &s}
+}
+
+// AsString returns the value rendered as a string, JSON-encoding composite values
+func (v AnyValue) AsString() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	case v.ArrayValue == nil && v.KvlistValue == nil && v.BytesValue == nil:
+		return ""
+	default:
+		data, _ := json.Marshal(v.AsInterface())
+		return string(data)
+	}
+}
+
+// AsInterface returns the value as a plain Go value suitable for JSON encoding
+func (v AnyValue) AsInterface() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			values = append(values, item.AsInterface())
+		}
+		return values
+	case v.KvlistValue != nil:
+		return AttributeMap(v.KvlistValue.Values)
+	case v.BytesValue != nil:
+		return v.BytesValue
+	default:
+		return nil
+	}
+}
+
+// AttributeMap converts attributes into a map of plain Go values
+func AttributeMap(attrs []KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value.AsInterface()
+	}
+	return m
+}
+
+// Attribute returns the value of the first attribute with the given key
+func Attribute(attrs []KeyValue, key string) (AnyValue, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return AnyValue{}, false
+}
+
+// Resource describes the entity producing telemetry
+type Resource struct {
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+}
+
+// ServiceName returns the resource's service.name attribute, or an empty string
+func (r Resource) ServiceName() string {
+	if v, ok := Attribute(r.Attributes, "service.name"); ok {
+		return v.AsString()
+	}
+	return ""
+}
+
+// Scope describes the instrumentation scope that produced telemetry
+type Scope struct {
+	Name                   string     `json:"name,omitempty"`
+	Version                string     `json:"version,omitempty"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+}
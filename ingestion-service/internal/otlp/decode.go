@@ -0,0 +1,403 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoding identifies the wire encoding of an OTLP payload
+type Encoding int
+
+// Supported OTLP encodings
+const (
+	EncodingJSON Encoding = iota
+	EncodingProtobuf
+)
+
+// Unmarshal decodes an OTLP export request for signal from data in the given encoding
+func Unmarshal(signal Signal, encoding Encoding, data []byte) (Payload, error) {
+	if encoding == EncodingProtobuf {
+		var msg proto.Message
+		switch signal {
+		case SignalTraces:
+			msg = &coltracepb.ExportTraceServiceRequest{}
+		case SignalMetrics:
+			msg = &colmetricspb.ExportMetricsServiceRequest{}
+		case SignalLogs:
+			msg = &collogspb.ExportLogsServiceRequest{}
+		default:
+			return nil, fmt.Errorf("unsupported signal: %s", signal)
+		}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal protobuf: %w", err)
+		}
+		return FromProto(msg)
+	}
+
+	var payload Payload
+	switch signal {
+	case SignalTraces:
+		payload = &Traces{}
+	case SignalMetrics:
+		payload = &Metrics{}
+	case SignalLogs:
+		payload = &Logs{}
+	default:
+		return nil, fmt.Errorf("unsupported signal: %s", signal)
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return payload, nil
+}
+
+// FromProto converts an OTLP export request message into the model
+func FromProto(msg proto.Message) (Payload, error) {
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		return TracesFromProto(req.GetResourceSpans()), nil
+	case *colmetricspb.ExportMetricsServiceRequest:
+		return MetricsFromProto(req.GetResourceMetrics()), nil
+	case *collogspb.ExportLogsServiceRequest:
+		return LogsFromProto(req.GetResourceLogs()), nil
+	default:
+		return nil, fmt.Errorf("unsupported OTLP message type: %T", msg)
+	}
+}
+
+// TracesFromProto converts OTLP resource spans into the model
+func TracesFromProto(resourceSpans []*tracepb.ResourceSpans) *Traces {
+	traces := &Traces{ResourceSpans: make([]ResourceSpans, 0, len(resourceSpans))}
+	for _, rs := range resourceSpans {
+		out := ResourceSpans{
+			Resource:   resourceFromProto(rs.GetResource()),
+			SchemaURL:  rs.GetSchemaUrl(),
+			ScopeSpans: make([]ScopeSpans, 0, len(rs.GetScopeSpans())),
+		}
+		for _, ss := range rs.GetScopeSpans() {
+			scope := ScopeSpans{
+				Scope:     scopeFromProto(ss.GetScope()),
+				SchemaURL: ss.GetSchemaUrl(),
+				Spans:     make([]Span, 0, len(ss.GetSpans())),
+			}
+			for _, span := range ss.GetSpans() {
+				scope.Spans = append(scope.Spans, spanFromProto(span))
+			}
+			out.ScopeSpans = append(out.ScopeSpans, scope)
+		}
+		traces.ResourceSpans = append(traces.ResourceSpans, out)
+	}
+	return traces
+}
+
+func spanFromProto(span *tracepb.Span) Span {
+	out := Span{
+		TraceID:                hexID(span.GetTraceId()),
+		SpanID:                 hexID(span.GetSpanId()),
+		TraceState:             span.GetTraceState(),
+		ParentSpanID:           hexID(span.GetParentSpanId()),
+		Name:                   span.GetName(),
+		Kind:                   int32(span.GetKind()),
+		StartTimeUnixNano:      Uint64(span.GetStartTimeUnixNano()),
+		EndTimeUnixNano:        Uint64(span.GetEndTimeUnixNano()),
+		Attributes:             attributesFromProto(span.GetAttributes()),
+		DroppedAttributesCount: span.GetDroppedAttributesCount(),
+		DroppedEventsCount:     span.GetDroppedEventsCount(),
+		DroppedLinksCount:      span.GetDroppedLinksCount(),
+		Status: Status{
+			Message: span.GetStatus().GetMessage(),
+			Code:    int32(span.GetStatus().GetCode()),
+		},
+	}
+	for _, event := range span.GetEvents() {
+		out.Events = append(out.Events, SpanEvent{
+			TimeUnixNano:           Uint64(event.GetTimeUnixNano()),
+			Name:                   event.GetName(),
+			Attributes:             attributesFromProto(event.GetAttributes()),
+			DroppedAttributesCount: event.GetDroppedAttributesCount(),
+		})
+	}
+	for _, link := range span.GetLinks() {
+		out.Links = append(out.Links, SpanLink{
+			TraceID:                hexID(link.GetTraceId()),
+			SpanID:                 hexID(link.GetSpanId()),
+			TraceState:             link.GetTraceState(),
+			Attributes:             attributesFromProto(link.GetAttributes()),
+			DroppedAttributesCount: link.GetDroppedAttributesCount(),
+		})
+	}
+	return out
+}
+
+// MetricsFromProto converts OTLP resource metrics into the model
+func MetricsFromProto(resourceMetrics []*metricspb.ResourceMetrics) *Metrics {
+	metrics := &Metrics{ResourceMetrics: make([]ResourceMetrics, 0, len(resourceMetrics))}
+	for _, rm := range resourceMetrics {
+		out := ResourceMetrics{
+			Resource:     resourceFromProto(rm.GetResource()),
+			SchemaURL:    rm.GetSchemaUrl(),
+			ScopeMetrics: make([]ScopeMetrics, 0, len(rm.GetScopeMetrics())),
+		}
+		for _, sm := range rm.GetScopeMetrics() {
+			scope := ScopeMetrics{
+				Scope:     scopeFromProto(sm.GetScope()),
+				SchemaURL: sm.GetSchemaUrl(),
+				Metrics:   make([]Metric, 0, len(sm.GetMetrics())),
+			}
+			for _, metric := range sm.GetMetrics() {
+				scope.Metrics = append(scope.Metrics, metricFromProto(metric))
+			}
+			out.ScopeMetrics = append(out.ScopeMetrics, scope)
+		}
+		metrics.ResourceMetrics = append(metrics.ResourceMetrics, out)
+	}
+	return metrics
+}
+
+func metricFromProto(metric *metricspb.Metric) Metric {
+	out := Metric{
+		Name:        metric.GetName(),
+		Description: metric.GetDescription(),
+		Unit:        metric.GetUnit(),
+	}
+
+	switch data := metric.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		out.Gauge = &Gauge{DataPoints: numberDataPointsFromProto(data.Gauge.GetDataPoints())}
+	case *metricspb.Metric_Sum:
+		out.Sum = &Sum{
+			DataPoints:             numberDataPointsFromProto(data.Sum.GetDataPoints()),
+			AggregationTemporality: int32(data.Sum.GetAggregationTemporality()),
+			IsMonotonic:            data.Sum.GetIsMonotonic(),
+		}
+	case *metricspb.Metric_Histogram:
+		out.Histogram = &Histogram{AggregationTemporality: int32(data.Histogram.GetAggregationTemporality())}
+		for _, dp := range data.Histogram.GetDataPoints() {
+			out.Histogram.DataPoints = append(out.Histogram.DataPoints, HistogramDataPoint{
+				Attributes:        attributesFromProto(dp.GetAttributes()),
+				StartTimeUnixNano: Uint64(dp.GetStartTimeUnixNano()),
+				TimeUnixNano:      Uint64(dp.GetTimeUnixNano()),
+				Count:             Uint64(dp.GetCount()),
+				Sum:               dp.Sum,
+				BucketCounts:      uint64sFromProto(dp.GetBucketCounts()),
+				ExplicitBounds:    dp.GetExplicitBounds(),
+				Exemplars:         exemplarsFromProto(dp.GetExemplars()),
+				Flags:             dp.GetFlags(),
+				Min:               dp.Min,
+				Max:               dp.Max,
+			})
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		out.ExponentialHistogram = &ExponentialHistogram{AggregationTemporality: int32(data.ExponentialHistogram.GetAggregationTemporality())}
+		for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+			out.ExponentialHistogram.DataPoints = append(out.ExponentialHistogram.DataPoints, ExponentialHistogramDataPoint{
+				Attributes:        attributesFromProto(dp.GetAttributes()),
+				StartTimeUnixNano: Uint64(dp.GetStartTimeUnixNano()),
+				TimeUnixNano:      Uint64(dp.GetTimeUnixNano()),
+				Count:             Uint64(dp.GetCount()),
+				Sum:               dp.Sum,
+				Scale:             dp.GetScale(),
+				ZeroCount:         Uint64(dp.GetZeroCount()),
+				Positive: Buckets{
+					Offset:       dp.GetPositive().GetOffset(),
+					BucketCounts: uint64sFromProto(dp.GetPositive().GetBucketCounts()),
+				},
+				Negative: Buckets{
+					Offset:       dp.GetNegative().GetOffset(),
+					BucketCounts: uint64sFromProto(dp.GetNegative().GetBucketCounts()),
+				},
+				Flags:         dp.GetFlags(),
+				Exemplars:     exemplarsFromProto(dp.GetExemplars()),
+				Min:           dp.Min,
+				Max:           dp.Max,
+				ZeroThreshold: dp.GetZeroThreshold(),
+			})
+		}
+	case *metricspb.Metric_Summary:
+		out.Summary = &Summary{}
+		for _, dp := range data.Summary.GetDataPoints() {
+			point := SummaryDataPoint{
+				Attributes:        attributesFromProto(dp.GetAttributes()),
+				StartTimeUnixNano: Uint64(dp.GetStartTimeUnixNano()),
+				TimeUnixNano:      Uint64(dp.GetTimeUnixNano()),
+				Count:             Uint64(dp.GetCount()),
+				Sum:               dp.GetSum(),
+				Flags:             dp.GetFlags(),
+			}
+			for _, q := range dp.GetQuantileValues() {
+				point.QuantileValues = append(point.QuantileValues, ValueAtQuantile{
+					Quantile: q.GetQuantile(),
+					Value:    q.GetValue(),
+				})
+			}
+			out.Summary.DataPoints = append(out.Summary.DataPoints, point)
+		}
+	}
+	return out
+}
+
+func numberDataPointsFromProto(points []*metricspb.NumberDataPoint) []NumberDataPoint {
+	out := make([]NumberDataPoint, 0, len(points))
+	for _, dp := range points {
+		point := NumberDataPoint{
+			Attributes:        attributesFromProto(dp.GetAttributes()),
+			StartTimeUnixNano: Uint64(dp.GetStartTimeUnixNano()),
+			TimeUnixNano:      Uint64(dp.GetTimeUnixNano()),
+			Exemplars:         exemplarsFromProto(dp.GetExemplars()),
+			Flags:             dp.GetFlags(),
+		}
+		switch value := dp.GetValue().(type) {
+		case *metricspb.NumberDataPoint_AsDouble:
+			point.AsDouble = &value.AsDouble
+		case *metricspb.NumberDataPoint_AsInt:
+			asInt := Int64(value.AsInt)
+			point.AsInt = &asInt
+		}
+		out = append(out, point)
+	}
+	return out
+}
+
+func exemplarsFromProto(exemplars []*metricspb.Exemplar) []Exemplar {
+	var out []Exemplar
+	for _, ex := range exemplars {
+		exemplar := Exemplar{
+			FilteredAttributes: attributesFromProto(ex.GetFilteredAttributes()),
+			TimeUnixNano:       Uint64(ex.GetTimeUnixNano()),
+			SpanID:             hexID(ex.GetSpanId()),
+			TraceID:            hexID(ex.GetTraceId()),
+		}
+		switch value := ex.GetValue().(type) {
+		case *metricspb.Exemplar_AsDouble:
+			exemplar.AsDouble = &value.AsDouble
+		case *metricspb.Exemplar_AsInt:
+			asInt := Int64(value.AsInt)
+			exemplar.AsInt = &asInt
+		}
+		out = append(out, exemplar)
+	}
+	return out
+}
+
+// LogsFromProto converts OTLP resource logs into the model
+func LogsFromProto(resourceLogs []*logspb.ResourceLogs) *Logs {
+	logs := &Logs{ResourceLogs: make([]ResourceLogs, 0, len(resourceLogs))}
+	for _, rl := range resourceLogs {
+		out := ResourceLogs{
+			Resource:  resourceFromProto(rl.GetResource()),
+			SchemaURL: rl.GetSchemaUrl(),
+			ScopeLogs: make([]ScopeLogs, 0, len(rl.GetScopeLogs())),
+		}
+		for _, sl := range rl.GetScopeLogs() {
+			scope := ScopeLogs{
+				Scope:      scopeFromProto(sl.GetScope()),
+				SchemaURL:  sl.GetSchemaUrl(),
+				LogRecords: make([]LogRecord, 0, len(sl.GetLogRecords())),
+			}
+			for _, record := range sl.GetLogRecords() {
+				logRecord := LogRecord{
+					TimeUnixNano:           Uint64(record.GetTimeUnixNano()),
+					ObservedTimeUnixNano:   Uint64(record.GetObservedTimeUnixNano()),
+					SeverityNumber:         int32(record.GetSeverityNumber()),
+					SeverityText:           record.GetSeverityText(),
+					Attributes:             attributesFromProto(record.GetAttributes()),
+					DroppedAttributesCount: record.GetDroppedAttributesCount(),
+					Flags:                  record.GetFlags(),
+					TraceID:                hexID(record.GetTraceId()),
+					SpanID:                 hexID(record.GetSpanId()),
+				}
+				if record.GetBody() != nil {
+					body := anyValueFromProto(record.GetBody())
+					logRecord.Body = &body
+				}
+				scope.LogRecords = append(scope.LogRecords, logRecord)
+			}
+			out.ScopeLogs = append(out.ScopeLogs, scope)
+		}
+		logs.ResourceLogs = append(logs.ResourceLogs, out)
+	}
+	return logs
+}
+
+func resourceFromProto(resource *resourcepb.Resource) Resource {
+	return Resource{
+		Attributes:             attributesFromProto(resource.GetAttributes()),
+		DroppedAttributesCount: resource.GetDroppedAttributesCount(),
+	}
+}
+
+func scopeFromProto(scope *commonpb.InstrumentationScope) Scope {
+	return Scope{
+		Name:                   scope.GetName(),
+		Version:                scope.GetVersion(),
+		Attributes:             attributesFromProto(scope.GetAttributes()),
+		DroppedAttributesCount: scope.GetDroppedAttributesCount(),
+	}
+}
+
+func attributesFromProto(attrs []*commonpb.KeyValue) []KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, KeyValue{Key: kv.GetKey(), Value: anyValueFromProto(kv.GetValue())})
+	}
+	return out
+}
+
+func anyValueFromProto(value *commonpb.AnyValue) AnyValue {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return AnyValue{StringValue: &v.StringValue}
+	case *commonpb.AnyValue_BoolValue:
+		return AnyValue{BoolValue: &v.BoolValue}
+	case *commonpb.AnyValue_IntValue:
+		intValue := Int64(v.IntValue)
+		return AnyValue{IntValue: &intValue}
+	case *commonpb.AnyValue_DoubleValue:
+		return AnyValue{DoubleValue: &v.DoubleValue}
+	case *commonpb.AnyValue_ArrayValue:
+		array := &ArrayValue{}
+		for _, item := range v.ArrayValue.GetValues() {
+			array.Values = append(array.Values, anyValueFromProto(item))
+		}
+		return AnyValue{ArrayValue: array}
+	case *commonpb.AnyValue_KvlistValue:
+		return AnyValue{KvlistValue: &KeyValueList{Values: attributesFromProto(v.KvlistValue.GetValues())}}
+	case *commonpb.AnyValue_BytesValue:
+		return AnyValue{BytesValue: v.BytesValue}
+	default:
+		return AnyValue{}
+	}
+}
+
+func uint64sFromProto(values []uint64) []Uint64 {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]Uint64, len(values))
+	for i, v := range values {
+		out[i] = Uint64(v)
+	}
+	return out
+}
+
+// hexID encodes a binary trace or span ID as lowercase hex, leaving empty IDs empty
+func hexID(id []byte) string {
+	if len(id) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,50 @@
+package otlp
+
+// Logs is an OTLP logs export request
+type Logs struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+// ResourceLogs is a collection of log records from a single resource
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+	SchemaURL string      `json:"schemaUrl,omitempty"`
+}
+
+// ScopeLogs is a collection of log records from a single instrumentation scope
+type ScopeLogs struct {
+	Scope      Scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+	SchemaURL  string      `json:"schemaUrl,omitempty"`
+}
+
+// LogRecord is a single log entry
+type LogRecord struct {
+	TimeUnixNano           Uint64     `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano   Uint64     `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber         int32      `json:"severityNumber,omitempty"`
+	SeverityText           string     `json:"severityText,omitempty"`
+	Body                   *AnyValue  `json:"body,omitempty"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+	Flags                  uint32     `json:"flags,omitempty"`
+	TraceID                string     `json:"traceId,omitempty"`
+	SpanID                 string     `json:"spanId,omitempty"`
+}
+
+// Signal returns SignalLogs
+func (l *Logs) Signal() Signal {
+	return SignalLogs
+}
+
+// ItemCount returns the number of log records in the request
+func (l *Logs) ItemCount() int64 {
+	var count int64
+	for _, rl := range l.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			count += int64(len(sl.LogRecords))
+		}
+	}
+	return count
+}
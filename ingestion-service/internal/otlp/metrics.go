@@ -0,0 +1,213 @@
+package otlp
+
+// Metrics is an OTLP metrics export request
+type Metrics struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+// ResourceMetrics is a collection of metrics from a single resource
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+	SchemaURL    string         `json:"schemaUrl,omitempty"`
+}
+
+// ScopeMetrics is a collection of metrics from a single instrumentation scope
+type ScopeMetrics struct {
+	Scope     Scope    `json:"scope"`
+	Metrics   []Metric `json:"metrics"`
+	SchemaURL string   `json:"schemaUrl,omitempty"`
+}
+
+// Metric is a named metric with exactly one data type set
+type Metric struct {
+	Name                 string                `json:"name"`
+	Description          string                `json:"description,omitempty"`
+	Unit                 string                `json:"unit,omitempty"`
+	Gauge                *Gauge                `json:"gauge,omitempty"`
+	Sum                  *Sum                  `json:"sum,omitempty"`
+	Histogram            *Histogram            `json:"histogram,omitempty"`
+	ExponentialHistogram *ExponentialHistogram `json:"exponentialHistogram,omitempty"`
+	Summary              *Summary              `json:"summary,omitempty"`
+}
+
+// Gauge is a metric of instantaneous values
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+// Sum is a metric of aggregated values
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int32             `json:"aggregationTemporality,omitempty"`
+	IsMonotonic            bool              `json:"isMonotonic,omitempty"`
+}
+
+// Histogram is a metric of explicit-bucket distributions
+type Histogram struct {
+	DataPoints             []HistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int32                `json:"aggregationTemporality,omitempty"`
+}
+
+// ExponentialHistogram is a metric of base-2 exponential bucket distributions
+type ExponentialHistogram struct {
+	DataPoints             []ExponentialHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int32                           `json:"aggregationTemporality,omitempty"`
+}
+
+// Summary is a metric of precomputed quantiles
+type Summary struct {
+	DataPoints []SummaryDataPoint `json:"dataPoints"`
+}
+
+// Aggregation temporalities
+const (
+	AggregationTemporalityUnspecified int32 = 0
+	AggregationTemporalityDelta       int32 = 1
+	AggregationTemporalityCumulative  int32 = 2
+)
+
+// NumberDataPoint is a single gauge or sum value
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+	AsInt             *Int64     `json:"asInt,omitempty"`
+	Exemplars         []Exemplar `json:"exemplars,omitempty"`
+	Flags             uint32     `json:"flags,omitempty"`
+}
+
+// Value returns the data point value as a float64
+func (dp NumberDataPoint) Value() float64 {
+	if dp.AsInt != nil {
+		return float64(*dp.AsInt)
+	}
+	if dp.AsDouble != nil {
+		return *dp.AsDouble
+	}
+	return 0
+}
+
+// HistogramDataPoint is a single explicit-bucket distribution
+type HistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	Count             Uint64     `json:"count"`
+	Sum               *float64   `json:"sum,omitempty"`
+	BucketCounts      []Uint64   `json:"bucketCounts,omitempty"`
+	ExplicitBounds    []float64  `json:"explicitBounds,omitempty"`
+	Exemplars         []Exemplar `json:"exemplars,omitempty"`
+	Flags             uint32     `json:"flags,omitempty"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+}
+
+// ExponentialHistogramDataPoint is a single exponential-bucket distribution
+type ExponentialHistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	Count             Uint64     `json:"count"`
+	Sum               *float64   `json:"sum,omitempty"`
+	Scale             int32      `json:"scale"`
+	ZeroCount         Uint64     `json:"zeroCount,omitempty"`
+	Positive          Buckets    `json:"positive"`
+	Negative          Buckets    `json:"negative"`
+	Flags             uint32     `json:"flags,omitempty"`
+	Exemplars         []Exemplar `json:"exemplars,omitempty"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+	ZeroThreshold     float64    `json:"zeroThreshold,omitempty"`
+}
+
+// Buckets is a range of exponential histogram buckets
+type Buckets struct {
+	Offset       int32    `json:"offset,omitempty"`
+	BucketCounts []Uint64 `json:"bucketCounts,omitempty"`
+}
+
+// SummaryDataPoint is a single summary of quantiles
+type SummaryDataPoint struct {
+	Attributes        []KeyValue        `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64            `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64            `json:"timeUnixNano"`
+	Count             Uint64            `json:"count"`
+	Sum               float64           `json:"sum"`
+	QuantileValues    []ValueAtQuantile `json:"quantileValues,omitempty"`
+	Flags             uint32            `json:"flags,omitempty"`
+}
+
+// ValueAtQuantile is the value of a single quantile
+type ValueAtQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// Exemplar is a sample measurement linked to a trace
+type Exemplar struct {
+	FilteredAttributes []KeyValue `json:"filteredAttributes,omitempty"`
+	TimeUnixNano       Uint64     `json:"timeUnixNano"`
+	AsDouble           *float64   `json:"asDouble,omitempty"`
+	AsInt              *Int64     `json:"asInt,omitempty"`
+	SpanID             string     `json:"spanId,omitempty"`
+	TraceID            string     `json:"traceId,omitempty"`
+}
+
+// Type returns the name of the metric's data type, or an empty string if none is set
+func (m Metric) Type() string {
+	switch {
+	case m.Gauge != nil:
+		return "gauge"
+	case m.Sum != nil:
+		return "sum"
+	case m.Histogram != nil:
+		return "histogram"
+	case m.ExponentialHistogram != nil:
+		return "exponential_histogram"
+	case m.Summary != nil:
+		return "summary"
+	default:
+		return ""
+	}
+}
+
+// DataPointCount returns the number of data points in the metric
+func (m Metric) DataPointCount() int {
+	count := 0
+	if m.Gauge != nil {
+		count += len(m.Gauge.DataPoints)
+	}
+	if m.Sum != nil {
+		count += len(m.Sum.DataPoints)
+	}
+	if m.Histogram != nil {
+		count += len(m.Histogram.DataPoints)
+	}
+	if m.ExponentialHistogram != nil {
+		count += len(m.ExponentialHistogram.DataPoints)
+	}
+	if m.Summary != nil {
+		count += len(m.Summary.DataPoints)
+	}
+	return count
+}
+
+// Signal returns SignalMetrics
+func (m *Metrics) Signal() Signal {
+	return SignalMetrics
+}
+
+// ItemCount returns the number of data points in the request
+func (m *Metrics) ItemCount() int64 {
+	var count int64
+	for _, rm := range m.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, metric := range sm.Metrics {
+				count += int64(metric.DataPointCount())
+			}
+		}
+	}
+	return count
+}
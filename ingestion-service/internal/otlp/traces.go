@@ -0,0 +1,85 @@
+package otlp
+
+// Traces is an OTLP trace export request
+type Traces struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans is a collection of spans from a single resource
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+	SchemaURL  string       `json:"schemaUrl,omitempty"`
+}
+
+// ScopeSpans is a collection of spans from a single instrumentation scope
+type ScopeSpans struct {
+	Scope     Scope  `json:"scope"`
+	Spans     []Span `json:"spans"`
+	SchemaURL string `json:"schemaUrl,omitempty"`
+}
+
+// Span is a single operation within a trace
+type Span struct {
+	TraceID                string      `json:"traceId"`
+	SpanID                 string      `json:"spanId"`
+	TraceState             string      `json:"traceState,omitempty"`
+	ParentSpanID           string      `json:"parentSpanId,omitempty"`
+	Name                   string      `json:"name"`
+	Kind                   int32       `json:"kind,omitempty"`
+	StartTimeUnixNano      Uint64      `json:"startTimeUnixNano"`
+	EndTimeUnixNano        Uint64      `json:"endTimeUnixNano"`
+	Attributes             []KeyValue  `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32      `json:"droppedAttributesCount,omitempty"`
+	Events                 []SpanEvent `json:"events,omitempty"`
+	DroppedEventsCount     uint32      `json:"droppedEventsCount,omitempty"`
+	Links                  []SpanLink  `json:"links,omitempty"`
+	DroppedLinksCount      uint32      `json:"droppedLinksCount,omitempty"`
+	Status                 Status      `json:"status"`
+}
+
+// SpanEvent is a timestamped annotation on a span
+type SpanEvent struct {
+	TimeUnixNano           Uint64     `json:"timeUnixNano"`
+	Name                   string     `json:"name"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+}
+
+// SpanLink is a reference from a span to another span
+type SpanLink struct {
+	TraceID                string     `json:"traceId"`
+	SpanID                 string     `json:"spanId"`
+	TraceState             string     `json:"traceState,omitempty"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+}
+
+// Status is the result of a span's operation
+type Status struct {
+	Message string `json:"message,omitempty"`
+	Code    int32  `json:"code,omitempty"`
+}
+
+// Span status codes
+const (
+	StatusCodeUnset int32 = 0
+	StatusCodeOk    int32 = 1
+	StatusCodeError int32 = 2
+)
+
+// Signal returns SignalTraces
+func (t *Traces) Signal() Signal {
+	return SignalTraces
+}
+
+// ItemCount returns the number of spans in the request
+func (t *Traces) ItemCount() int64 {
+	var count int64
+	for _, rs := range t.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			count += int64(len(ss.Spans))
+		}
+	}
+	return count
+}
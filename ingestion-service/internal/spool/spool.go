@@ -0,0 +1,571 @@
+// Package spool implements a disk-backed write-ahead queue for Kafka messages that
+// could not be delivered.
+//
+// Entries are appended to numbered segment files. Each record is framed with its
+// length and a CRC-32C checksum so that torn writes and corruption are detected on
+// read. Segments are deleted once a reader commits past them, when they exceed the
+// configured age, or when the size limit forces eviction. The committed read
+// position is persisted so that a restart resumes where replay left off.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy decides what happens when an append would exceed the size limit
+type EvictionPolicy string
+
+// Supported eviction policies
+const (
+	// EvictDropOldest deletes the oldest segments to make room for new entries
+	EvictDropOldest EvictionPolicy = "drop_oldest"
+	// EvictRejectNew refuses new entries until replay frees space
+	EvictRejectNew EvictionPolicy = "reject_new"
+)
+
+const (
+	segmentSuffix = ".seg"
+	cursorFile    = "cursor"
+	frameHeader   = 8 // uint32 length + uint32 CRC-32C
+	maxRecordSize = 64 << 20
+)
+
+var (
+	// ErrFull is returned by Append when the spool is at its size limit
+	ErrFull = errors.New("spool is full")
+	// ErrClosed is returned when the spool is used after Close
+	ErrClosed = errors.New("spool is closed")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Options configures a Spool
+type Options struct {
+	// MaxBytes limits the total size of all segments; zero means unlimited
+	MaxBytes int64
+	// MaxAge discards entries older than this; zero means entries never expire
+	MaxAge time.Duration
+	// SegmentBytes is the size at which the active segment is sealed and a new one started
+	SegmentBytes int64
+	// Eviction is the policy applied when MaxBytes is reached
+	Eviction EvictionPolicy
+	// SyncInterval is how often appended entries are flushed to disk; zero syncs on every
+	// append. With a positive interval, entries appended within the last interval before a
+	// host crash or power loss are lost even though Append succeeded.
+	SyncInterval time.Duration
+}
+
+// Header is a Kafka record header
+type Header struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Entry is a spooled Kafka message
+type Entry struct {
+	Topic     string    `json:"topic"`
+	Key       []byte    `json:"key,omitempty"`
+	Value     []byte    `json:"value"`
+	Headers   []Header  `json:"headers,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Cursor is a read position returned by Read and passed to Commit
+type Cursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+	Index   int64  `json:"index"`
+
+	// entries skipped by the Read that produced the cursor, counted on Commit
+	evicted   int64
+	corrupted int64
+}
+
+// Stats describes the current contents of the spool
+type Stats struct {
+	Entries   int64
+	Bytes     int64
+	Segments  int
+	Evicted   int64
+	Corrupted int64
+}
+
+// segment is a single spool file
+type segment struct {
+	seq     uint64
+	path    string
+	size    int64
+	records int64
+	modTime time.Time
+}
+
+// Spool is a disk-backed FIFO of Kafka messages. It is safe for concurrent use.
+type Spool struct {
+	dir  string
+	opts Options
+
+	mu        sync.Mutex
+	segments  []*segment // oldest first; the last one is active when active is set
+	active    *os.File
+	head      Cursor // committed read position within segments[0]
+	nextSeq   uint64
+	evicted   int64
+	corrupted int64
+	dirty     bool // the active segment has writes that are not synced yet
+	closed    bool
+
+	stopSync chan struct{}
+	syncer   sync.WaitGroup
+}
+
+// Open opens or creates a spool in dir, recovering any segments left by a previous run
+func Open(dir string, opts Options) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{dir: dir, opts: opts, nextSeq: 1}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if opts.SyncInterval > 0 {
+		s.stopSync = make(chan struct{})
+		s.syncer.Add(1)
+		go s.syncLoop()
+	}
+	return s, nil
+}
+
+// syncLoop flushes the active segment to disk every SyncInterval until the spool is closed
+func (s *Spool) syncLoop() {
+	defer s.syncer.Done()
+
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && s.active != nil {
+				// A failed sync is retried on the next tick and reported by the next sealing
+				if s.active.Sync() == nil {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		case <-s.stopSync:
+			return
+		}
+	}
+}
+
+// load scans existing segments and restores the committed read position
+func (s *Spool) load() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentSuffix))
+	if err != nil {
+		return fmt.Errorf("failed to list spool segments: %w", err)
+	}
+
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("failed to stat spool segment: %w", err)
+		}
+		records, err := countRecords(name)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, &segment{
+			seq:     seq,
+			path:    name,
+			size:    info.Size(),
+			records: records,
+			modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	if n := len(s.segments); n > 0 {
+		s.nextSeq = s.segments[n-1].seq + 1
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, cursorFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read spool cursor: %w", err)
+	}
+	if err == nil {
+		var cursor Cursor
+		if err := json.Unmarshal(data, &cursor); err != nil {
+			return fmt.Errorf("failed to parse spool cursor: %w", err)
+		}
+		s.commitLocked(cursor)
+		// Never reuse the sequence number of a segment the cursor has moved past
+		if cursor.Segment >= s.nextSeq {
+			s.nextSeq = cursor.Segment + 1
+		}
+	}
+	return nil
+}
+
+// countRecords returns the number of intact records at the start of a segment file
+func countRecords(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	var records int64
+	r := bufio.NewReader(f)
+	for {
+		if _, _, err := readFrame(r); err != nil {
+			return records, nil
+		}
+		records++
+	}
+}
+
+// Append adds an entry to the end of the spool. The entry is on disk when Append returns
+// unless Options.SyncInterval defers the sync.
+func (s *Spool) Append(entry Entry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+	frame := make([]byte, frameHeader+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeader:], payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.expireLocked(time.Now())
+	if err := s.makeRoomLocked(int64(len(frame))); err != nil {
+		return err
+	}
+
+	active, err := s.activeSegmentLocked(int64(len(frame)))
+	if err != nil {
+		return err
+	}
+	if _, err := s.active.Write(frame); err != nil {
+		// Start a fresh segment for the next append rather than writing after a partial frame
+		s.sealLocked()
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	active.size += int64(len(frame))
+	active.records++
+	active.modTime = time.Now()
+
+	if s.opts.SyncInterval > 0 {
+		s.dirty = true
+		return nil
+	}
+	if err := s.active.Sync(); err != nil {
+		// The entry may still be replayed after a restart, but it is not known to be durable
+		s.sealLocked()
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	return nil
+}
+
+// makeRoomLocked applies the eviction policy so that n more bytes fit within MaxBytes
+func (s *Spool) makeRoomLocked(n int64) error {
+	if s.opts.MaxBytes <= 0 {
+		return nil
+	}
+	if n > s.opts.MaxBytes {
+		return ErrFull
+	}
+	for s.bytesLocked()+n > s.opts.MaxBytes {
+		if s.opts.Eviction != EvictDropOldest || len(s.segments) == 0 {
+			return ErrFull
+		}
+		if len(s.segments) == 1 && s.active != nil {
+			if err := s.sealLocked(); err != nil {
+				return err
+			}
+		}
+		s.evicted += s.segments[0].records - s.head.Index
+		s.removeHeadLocked()
+	}
+	return nil
+}
+
+// activeSegmentLocked returns the segment to append n bytes to, starting a new one if needed
+func (s *Spool) activeSegmentLocked(n int64) (*segment, error) {
+	if s.active != nil {
+		active := s.segments[len(s.segments)-1]
+		if s.opts.SegmentBytes <= 0 || active.size+n <= s.opts.SegmentBytes || active.size == 0 {
+			return active, nil
+		}
+		if err := s.sealLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	seg := &segment{
+		seq:     s.nextSeq,
+		path:    filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.nextSeq, segmentSuffix)),
+		modTime: time.Now(),
+	}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool segment: %w", err)
+	}
+	s.nextSeq++
+	s.active = f
+	s.segments = append(s.segments, seg)
+	return seg, nil
+}
+
+// sealLocked flushes and closes the active segment so no more entries are added to it
+func (s *Spool) sealLocked() error {
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Sync()
+	if closeErr := s.active.Close(); err == nil {
+		err = closeErr
+	}
+	s.active = nil
+	s.dirty = false
+	if err != nil {
+		return fmt.Errorf("failed to seal spool segment: %w", err)
+	}
+	return nil
+}
+
+// expireLocked deletes sealed segments whose newest entry is older than MaxAge
+func (s *Spool) expireLocked(now time.Time) {
+	if s.opts.MaxAge <= 0 {
+		return
+	}
+	for len(s.segments) > 0 {
+		head := s.segments[0]
+		if (s.active != nil && len(s.segments) == 1) || now.Sub(head.modTime) <= s.opts.MaxAge {
+			return
+		}
+		s.evicted += head.records - s.head.Index
+		s.removeHeadLocked()
+	}
+}
+
+// removeHeadLocked deletes the oldest segment and resets the read position to the next one
+func (s *Spool) removeHeadLocked() {
+	head := s.segments[0]
+	os.Remove(head.path)
+	s.segments = s.segments[1:]
+	s.head = Cursor{}
+	if len(s.segments) > 0 {
+		s.head.Segment = s.segments[0].seq
+	}
+}
+
+// Read returns up to max entries from the committed read position without consuming
+// them, along with the cursor to Commit once they have been delivered. Entries older
+// than MaxAge are skipped, and a corrupt record ends its segment. The cursor should be
+// committed even when no entries are returned, so skipped entries are not read again.
+func (s *Spool) Read(max int) ([]Entry, Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, Cursor{}, ErrClosed
+	}
+	now := time.Now()
+	s.expireLocked(now)
+
+	var entries []Entry
+	cursor := s.head
+	for i, seg := range s.segments {
+		if len(entries) >= max {
+			break
+		}
+		if i > 0 {
+			cursor = Cursor{Segment: seg.seq, evicted: cursor.evicted, corrupted: cursor.corrupted}
+		}
+		cursor.Segment = seg.seq
+		if cursor.Index >= seg.records {
+			continue
+		}
+
+		var err error
+		entries, cursor, err = s.readSegmentLocked(seg, cursor, entries, max, now)
+		if err != nil {
+			return nil, Cursor{}, err
+		}
+	}
+	return entries, cursor, nil
+}
+
+// readSegmentLocked appends entries from seg starting at cursor until max entries are collected
+func (s *Spool) readSegmentLocked(seg *segment, cursor Cursor, entries []Entry, max int, now time.Time) ([]Entry, Cursor, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, cursor, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(cursor.Offset, io.SeekStart); err != nil {
+		return nil, cursor, fmt.Errorf("failed to seek spool segment: %w", err)
+	}
+	r := bufio.NewReader(io.LimitReader(f, seg.size-cursor.Offset))
+	for cursor.Index < seg.records && len(entries) < max {
+		payload, n, err := readFrame(r)
+		if err != nil {
+			// Skip the rest of a damaged segment
+			cursor.corrupted += seg.records - cursor.Index
+			cursor.Offset = seg.size
+			cursor.Index = seg.records
+			break
+		}
+		cursor.Offset += n
+		cursor.Index++
+
+		var entry Entry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			cursor.corrupted++
+			continue
+		}
+		if s.opts.MaxAge > 0 && now.Sub(entry.Timestamp) > s.opts.MaxAge {
+			cursor.evicted++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, cursor, nil
+}
+
+// readFrame reads one length-prefixed, checksummed record, returning its payload and framed size
+func readFrame(r io.Reader) ([]byte, int64, error) {
+	var header [frameHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxRecordSize {
+		return nil, 0, errors.New("spool record length out of range")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errors.New("spool record checksum mismatch")
+	}
+	return payload, int64(frameHeader) + int64(length), nil
+}
+
+// Commit marks everything before cursor as delivered, deleting fully consumed
+// segments and persisting the read position
+func (s *Spool) Commit(cursor Cursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.evicted += cursor.evicted
+	s.corrupted += cursor.corrupted
+	s.commitLocked(cursor)
+
+	data, err := json.Marshal(s.head)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool cursor: %w", err)
+	}
+	tmp := filepath.Join(s.dir, cursorFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, cursorFile)); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	return nil
+}
+
+// commitLocked advances the read position to cursor. A fully consumed active segment is
+// sealed and deleted too, so drained entries stop counting toward MaxBytes; the next
+// append starts a new segment.
+func (s *Spool) commitLocked(cursor Cursor) {
+	for len(s.segments) > 0 {
+		head := s.segments[0]
+		consumed := head.seq < cursor.Segment || (head.seq == cursor.Segment && cursor.Index >= head.records)
+		if !consumed {
+			break
+		}
+		if s.active != nil && len(s.segments) == 1 {
+			// The segment is deleted either way, so a failure to flush it does not matter
+			s.sealLocked()
+		}
+		s.removeHeadLocked()
+	}
+	if len(s.segments) > 0 && s.segments[0].seq == cursor.Segment {
+		s.head = Cursor{Segment: cursor.Segment, Offset: cursor.Offset, Index: cursor.Index}
+	}
+}
+
+// Stats returns the current size of the spool and eviction counters
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{
+		Bytes:     s.bytesLocked(),
+		Segments:  len(s.segments),
+		Evicted:   s.evicted,
+		Corrupted: s.corrupted,
+	}
+	for _, seg := range s.segments {
+		stats.Entries += seg.records
+	}
+	stats.Entries -= s.head.Index
+	return stats
+}
+
+// bytesLocked returns the total size of all segments
+func (s *Spool) bytesLocked() int64 {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// Close flushes the active segment and releases the spool
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.sealLocked()
+	s.mu.Unlock()
+
+	if s.stopSync != nil {
+		close(s.stopSync)
+		s.syncer.Wait()
+	}
+	return err
+}
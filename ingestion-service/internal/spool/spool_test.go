@@ -0,0 +1,323 @@
+package spool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// frame encodes payload the way Append writes it
+func frame(payload []byte) []byte {
+	buf := make([]byte, frameHeader+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	copy(buf[frameHeader:], payload)
+	return buf
+}
+
+// entry returns a spool entry whose value is value. Whole-second timestamps keep every
+// frame of a one-byte value the same size.
+func entry(value string) Entry {
+	return Entry{Topic: "otel.traces", Value: []byte(value), Timestamp: time.Now().UTC().Truncate(time.Second)}
+}
+
+// appendAll appends an entry for each value
+func appendAll(t *testing.T, s *Spool, values ...string) {
+	t.Helper()
+	for _, v := range values {
+		if err := s.Append(entry(v)); err != nil {
+			t.Fatalf("Append(%q) error = %v", v, err)
+		}
+	}
+}
+
+// readAll reads and commits every entry, returning their values
+func readAll(t *testing.T, s *Spool) []string {
+	t.Helper()
+	entries, cursor, err := s.Read(1000)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if err := s.Commit(cursor); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	values := make([]string, 0, len(entries))
+	for _, e := range entries {
+		values = append(values, string(e.Value))
+	}
+	return values
+}
+
+// equalValues reports whether two value lists are identical
+func equalValues(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestReadFrame(t *testing.T) {
+	valid := frame([]byte(`{"topic":"t"}`))
+	badCRC := append([]byte(nil), valid...)
+	badCRC[len(badCRC)-1] ^= 0xff
+	oversized := make([]byte, frameHeader)
+	binary.BigEndian.PutUint32(oversized[0:4], maxRecordSize+1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", data: valid, want: `{"topic":"t"}`},
+		{name: "empty payload", data: frame(nil), want: ""},
+		{name: "checksum mismatch", data: badCRC, wantErr: true},
+		{name: "truncated header", data: valid[:frameHeader-1], wantErr: true},
+		{name: "truncated payload", data: valid[:len(valid)-1], wantErr: true},
+		{name: "length out of range", data: oversized, wantErr: true},
+		{name: "no data", data: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, n, err := readFrame(bytes.NewReader(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readFrame() = %q, want error", payload)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFrame() error = %v", err)
+			}
+			if string(payload) != tt.want || n != int64(len(tt.data)) {
+				t.Errorf("readFrame() = %q, %d; want %q, %d", payload, n, tt.want, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		// damage is applied to the newest segment file after the spool is closed
+		damage        func(t *testing.T, path string)
+		consumeFirst  bool
+		want          []string
+		wantCorrupted int64
+	}{
+		{
+			name: "clean restart",
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:         "resumes at committed cursor",
+			consumeFirst: true,
+			want:         []string{"c"},
+		},
+		{
+			name: "torn trailing write",
+			damage: func(t *testing.T, path string) {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.Write(frame([]byte(`{"value":"ZA=="}`))[:frameHeader+3]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "corrupt record ends the segment",
+			damage: func(t *testing.T, path string) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data[len(data)-2] ^= 0xff
+				if err := os.WriteFile(path, data, 0o640); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := Open(dir, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendAll(t, s, "a", "b")
+			if tt.consumeFirst {
+				if got := readAll(t, s); !equalValues(got, []string{"a", "b"}) {
+					t.Fatalf("readAll() = %v", got)
+				}
+			}
+			appendAll(t, s, "c")
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.damage != nil {
+				segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+				tt.damage(t, segments[len(segments)-1])
+			}
+
+			s, err = Open(dir, Options{})
+			if err != nil {
+				t.Fatalf("Open() after restart error = %v", err)
+			}
+			defer s.Close()
+			if got := readAll(t, s); !equalValues(got, tt.want) {
+				t.Errorf("entries after restart = %v, want %v", got, tt.want)
+			}
+			if got := s.Stats().Corrupted; got != tt.wantCorrupted {
+				t.Errorf("Stats().Corrupted = %d, want %d", got, tt.wantCorrupted)
+			}
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	payload, err := json.Marshal(entry("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frameSize := int64(frameHeader + len(payload))
+
+	tests := []struct {
+		name        string
+		opts        Options
+		values      []string
+		drainBefore int // entries replayed before the last append
+		want        []string
+		wantErr     error
+		wantEvicted int64
+	}{
+		{
+			name:        "drop_oldest evicts the oldest segment",
+			opts:        Options{MaxBytes: 2 * frameSize, SegmentBytes: frameSize, Eviction: EvictDropOldest},
+			values:      []string{"a", "b", "c"},
+			want:        []string{"b", "c"},
+			wantEvicted: 1,
+		},
+		{
+			name:    "reject_new refuses appends when full",
+			opts:    Options{MaxBytes: 2 * frameSize, SegmentBytes: frameSize, Eviction: EvictRejectNew},
+			values:  []string{"a", "b", "c"},
+			want:    []string{"a", "b"},
+			wantErr: ErrFull,
+		},
+		{
+			name:        "reject_new accepts again once the active segment is drained",
+			opts:        Options{MaxBytes: 2 * frameSize, SegmentBytes: 4 * frameSize, Eviction: EvictRejectNew},
+			values:      []string{"a", "b", "c"},
+			drainBefore: 2,
+			want:        []string{"c"},
+		},
+		{
+			name:    "entry larger than the limit",
+			opts:    Options{MaxBytes: frameSize - 1, Eviction: EvictDropOldest},
+			values:  []string{"a"},
+			wantErr: ErrFull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(t.TempDir(), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			var appendErr error
+			for i, v := range tt.values {
+				if tt.drainBefore > 0 && i == tt.drainBefore {
+					readAll(t, s)
+				}
+				if err := s.Append(entry(v)); err != nil {
+					appendErr = err
+				}
+			}
+			if !errors.Is(appendErr, tt.wantErr) {
+				t.Fatalf("Append() error = %v, want %v", appendErr, tt.wantErr)
+			}
+			if got := readAll(t, s); !equalValues(got, tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+			if got := s.Stats().Evicted; got != tt.wantEvicted {
+				t.Errorf("Stats().Evicted = %d, want %d", got, tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestCommitDeletesDrainedActiveSegment(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	appendAll(t, s, "a", "b")
+	readAll(t, s)
+	if stats := s.Stats(); stats.Bytes != 0 || stats.Segments != 0 || stats.Entries != 0 {
+		t.Fatalf("Stats() after draining = %+v, want an empty spool", stats)
+	}
+	appendAll(t, s, "c")
+	if got := readAll(t, s); !equalValues(got, []string{"c"}) {
+		t.Errorf("entries after draining = %v, want [c]", got)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	old := entry("old")
+	old.Timestamp = time.Now().Add(-2 * time.Hour)
+	if err := s.Append(old); err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, s, "new")
+	if got := readAll(t, s); !equalValues(got, []string{"new"}) {
+		t.Errorf("entries = %v, want [new]", got)
+	}
+	if got := s.Stats().Evicted; got != 1 {
+		t.Errorf("Stats().Evicted = %d, want 1", got)
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{SyncInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, s, "a")
+	time.Sleep(50 * time.Millisecond)
+	s.mu.Lock()
+	dirty := s.dirty
+	s.mu.Unlock()
+	if dirty {
+		t.Error("active segment still dirty after the sync interval")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if err := s.Append(entry("b")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Append() after Close error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Errors returned when a bearer token fails validation
+var (
+	errTokenMalformed = errors.New("malformed token")
+	errTokenSignature = errors.New("invalid token signature")
+	errTokenExpired   = errors.New("token is expired or not yet valid")
+	errTokenClaims    = errors.New("token issuer or audience does not match")
+	errTokenNoExpiry  = errors.New("token has no exp claim")
+)
+
+// jwksKeySet holds the public keys of a JWKS document by key ID
+type jwksKeySet map[string]crypto.PublicKey
+
+// jwk is a single key of a JWKS document; only RSA and EC signing keys are used
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKSFile reads the signing keys of a JWKS file
+func loadJWKSFile(path string) (jwksKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	keys := make(jwksKeySet, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS file has no signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material of an RSA or EC key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		if len(n) == 0 {
+			return nil, errors.New("missing modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		// rsa.VerifyPKCS1v15 refuses exponents outside this range
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 2 || exponent.Int64() > math.MaxInt32 {
+			return nil, errors.New("exponent is out of range")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// jwtValidator checks bearer tokens against a key set and the configured claims
+type jwtValidator struct {
+	issuer    string
+	audience  string
+	clockSkew time.Duration
+}
+
+// validate verifies a compact JWS token and returns its claims
+func (v jwtValidator) validate(token string, keys jwksKeySet, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, errTokenMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+	key, ok := keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key ID %q", errTokenSignature, header.Kid)
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, errTokenMalformed
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errTokenNoExpiry
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.clockSkew)) {
+		return nil, errTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errTokenExpired
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return nil, errTokenClaims
+	}
+	if v.audience != "" && !jwtAudienceContains(claims["aud"], v.audience) {
+		return nil, errTokenClaims
+	}
+	return claims, nil
+}
+
+// decodeJWTSegment decodes a base64url JSON segment of a token
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifyJWTSignature checks a token signature for the RS* and ES* algorithms. Each ES*
+// algorithm is only accepted with the curve it is defined for.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	var curveBits int
+	switch alg {
+	case "RS256":
+		hash = crypto.SHA256
+	case "RS384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	case "ES256":
+		hash, curveBits = crypto.SHA256, 256
+	case "ES384":
+		hash, curveBits = crypto.SHA384, 384
+	case "ES512":
+		hash, curveBits = crypto.SHA512, 521
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", errTokenSignature, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("%w: algorithm %s does not match an RSA key", errTokenSignature, alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return errTokenSignature
+		}
+	case *ecdsa.PublicKey:
+		bits := key.Curve.Params().BitSize
+		size := (bits + 7) / 8
+		if bits != curveBits || len(signature) != 2*size {
+			return fmt.Errorf("%w: algorithm %s does not match an EC key on %s", errTokenSignature, alg, key.Curve.Params().Name)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errTokenSignature
+		}
+	default:
+		return errTokenSignature
+	}
+	return nil
+}
+
+// jwtAudienceContains reports whether an aud claim, a string or a list, names audience
+func jwtAudienceContains(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testJWTKeys holds the signing keys shared by the JWT tests
+type testJWTKeys struct {
+	rsa  *rsa.PrivateKey
+	p256 *ecdsa.PrivateKey
+	p384 *ecdsa.PrivateKey
+}
+
+// newTestJWTKeys generates an RSA, a P-256 and a P-384 signing key
+func newTestJWTKeys(t *testing.T) testJWTKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testJWTKeys{rsa: rsaKey, p256: p256, p384: p384}
+}
+
+// keySet returns the public keys by the key IDs the tests sign with
+func (k testJWTKeys) keySet() jwksKeySet {
+	return jwksKeySet{"rsa": &k.rsa.PublicKey, "p256": &k.p256.PublicKey, "p384": &k.p384.PublicKey}
+}
+
+// signTestJWT returns a compact JWS of claims signed with key under alg
+func signTestJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	default:
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTValidate(t *testing.T) {
+	keys := newTestJWTKeys(t)
+	now := time.Unix(1_700_000_000, 0)
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    "https://issuer.example",
+			"aud":    "ingestion",
+			"exp":    now.Add(time.Hour).Unix(),
+			"tenant": "team-a",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	validator := jwtValidator{issuer: "https://issuer.example", audience: "ingestion", clockSkew: time.Minute}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "RS256", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(nil))},
+		{name: "RS512", token: signTestJWT(t, "RS512", "rsa", keys.rsa, claims(nil))},
+		{name: "ES256 on P-256", token: signTestJWT(t, "ES256", "p256", keys.p256, claims(nil))},
+		{name: "ES384 on P-384", token: signTestJWT(t, "ES384", "p384", keys.p384, claims(nil))},
+		{name: "audience list", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"aud": []string{"other", "ingestion"}}))},
+		{name: "expired within clock skew", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}))},
+		{name: "expired", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), wantErr: errTokenExpired},
+		{name: "not yet valid", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), wantErr: errTokenExpired},
+		{name: "missing exp", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"exp": nil})), wantErr: errTokenNoExpiry},
+		{name: "non-numeric exp", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"exp": "tomorrow"})), wantErr: errTokenNoExpiry},
+		{name: "wrong issuer", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"iss": "https://evil.example"})), wantErr: errTokenClaims},
+		{name: "wrong audience", token: signTestJWT(t, "RS256", "rsa", keys.rsa, claims(map[string]interface{}{"aud": "other"})), wantErr: errTokenClaims},
+		{name: "unknown key ID", token: signTestJWT(t, "RS256", "missing", keys.rsa, claims(nil)), wantErr: errTokenSignature},
+		{name: "unsupported algorithm", token: signTestJWT(t, "PS256", "rsa", keys.rsa, claims(nil)), wantErr: errTokenSignature},
+		{name: "ES algorithm on an RSA key", token: signTestJWT(t, "ES256", "rsa", keys.p256, claims(nil)), wantErr: errTokenSignature},
+		{name: "RS algorithm on an EC key", token: signTestJWT(t, "RS256", "p256", keys.rsa, claims(nil)), wantErr: errTokenSignature},
+		{name: "ES384 on P-256", token: signTestJWT(t, "ES384", "p256", keys.p256, claims(nil)), wantErr: errTokenSignature},
+		{name: "ES256 on P-384", token: signTestJWT(t, "ES256", "p384", keys.p384, claims(nil)), wantErr: errTokenSignature},
+		{name: "signed by another key", token: signTestJWT(t, "ES256", "p256", mustECKey(t), claims(nil)), wantErr: errTokenSignature},
+		{name: "two segments", token: "a.b", wantErr: errTokenMalformed},
+		{name: "bad header encoding", token: "!!.e30.c2ln", wantErr: errTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.validate(tt.token, keys.keySet(), now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("validate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			if got["tenant"] != "team-a" {
+				t.Errorf("validate() tenant claim = %v, want team-a", got["tenant"])
+			}
+		})
+	}
+}
+
+// mustECKey generates a P-256 key that is not in the test key set
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	keys := newTestJWTKeys(t)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	rsaKey := func(e []byte) map[string]string {
+		return map[string]string{"kid": "rsa", "kty": "RSA", "n": b64(keys.rsa.N.Bytes()), "e": b64(e)}
+	}
+	ecKey := map[string]string{
+		"kid": "p256", "kty": "EC", "crv": "P-256",
+		"x": b64(keys.p256.X.Bytes()), "y": b64(keys.p256.Y.Bytes()),
+	}
+
+	tests := []struct {
+		name     string
+		keys     []map[string]string
+		wantKids []string
+		wantErr  bool
+	}{
+		{name: "RSA and EC keys", keys: []map[string]string{rsaKey(big.NewInt(65537).Bytes()), ecKey}, wantKids: []string{"rsa", "p256"}},
+		{name: "encryption keys are skipped", keys: []map[string]string{ecKey, {"kid": "enc", "kty": "RSA", "use": "enc"}}, wantKids: []string{"p256"}},
+		{name: "zero exponent", keys: []map[string]string{rsaKey([]byte{0})}, wantErr: true},
+		{name: "exponent of one", keys: []map[string]string{rsaKey([]byte{1})}, wantErr: true},
+		{name: "exponent wider than 32 bits", keys: []map[string]string{rsaKey([]byte{1, 0, 0, 0, 1})}, wantErr: true},
+		{name: "exponent wider than 64 bits", keys: []map[string]string{rsaKey(append([]byte{1}, make([]byte, 15)...))}, wantErr: true},
+		{name: "missing modulus", keys: []map[string]string{{"kid": "rsa", "kty": "RSA", "e": "AQAB"}}, wantErr: true},
+		{name: "unsupported curve", keys: []map[string]string{{"kid": "k", "kty": "EC", "crv": "P-224"}}, wantErr: true},
+		{name: "unsupported key type", keys: []map[string]string{{"kid": "k", "kty": "oct"}}, wantErr: true},
+		{name: "no signing keys", keys: []map[string]string{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(map[string]interface{}{"keys": tt.keys})
+			path := filepath.Join(t.TempDir(), "jwks.json")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadJWKSFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("loadJWKSFile() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadJWKSFile() error = %v", err)
+			}
+			if len(got) != len(tt.wantKids) {
+				t.Fatalf("loadJWKSFile() loaded %d keys, want %v", len(got), tt.wantKids)
+			}
+			for _, kid := range tt.wantKids {
+				if _, ok := got[kid]; !ok {
+					t.Errorf("loadJWKSFile() is missing key %q", kid)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// kafkaLogExporter batches the service's own log entries and writes them to the logs topic
+// in the same format as ingested logs. Entries logged before the producer is attached are
+// queued, so startup logs are exported once Kafka is reachable; entries that do not fit in
+// the queue are dropped rather than blocking the caller.
+type kafkaLogExporter struct {
+	signal        otlpSignal
+	resource      *resourcepb.Resource
+	scope         *commonpb.InstrumentationScope
+	level         zapcore.Level
+	batchSize     int
+	flushInterval time.Duration
+	logger        *zap.Logger
+
+	records  chan *logspb.LogRecord
+	flushes  chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	start    sync.Once
+	stopOnce sync.Once
+	started  atomic.Bool
+	dropped  atomic.Int64
+	producer atomic.Pointer[KafkaProducer]
+}
+
+// newKafkaLogExporter creates a log exporter for the configured logs topic. Export failures
+// are reported to logger, which must not write back into the exporter.
+func newKafkaLogExporter(config *Config, res *resource.Resource, logger *zap.Logger) (*kafkaLogExporter, error) {
+	level, err := zapcore.ParseLevel(config.OpenTelemetry.Logs.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log export level: %w", err)
+	}
+	return &kafkaLogExporter{
+		signal:        newOTLPSignals(config).logs,
+		resource:      resourceToProto(res),
+		scope:         &commonpb.InstrumentationScope{Name: config.OpenTelemetry.ServiceName, Version: config.OpenTelemetry.ServiceVersion},
+		level:         level,
+		batchSize:     config.OpenTelemetry.Logs.BatchSize,
+		flushInterval: config.OpenTelemetry.Logs.FlushInterval,
+		logger:        logger,
+		records:       make(chan *logspb.LogRecord, config.OpenTelemetry.Logs.QueueSize),
+		flushes:       make(chan chan struct{}),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// core returns a zapcore.Core that hands entries to the exporter
+func (e *kafkaLogExporter) core() zapcore.Core {
+	return &kafkaLogCore{exporter: e}
+}
+
+// attach starts sending queued and new entries through kafkaProducer
+func (e *kafkaLogExporter) attach(kafkaProducer *KafkaProducer) {
+	e.producer.Store(kafkaProducer)
+	e.start.Do(func() {
+		e.started.Store(true)
+		go e.run()
+	})
+}
+
+// enqueue queues a record without blocking, counting it as dropped if the queue is full
+func (e *kafkaLogExporter) enqueue(record *logspb.LogRecord) {
+	select {
+	case e.records <- record:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// ForceFlush sends every queued entry, waiting until ctx is done
+func (e *kafkaLogExporter) ForceFlush(ctx context.Context) error {
+	if !e.started.Load() {
+		return nil
+	}
+	flushed := make(chan struct{})
+	select {
+	case e.flushes <- flushed:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush logs: %w", ctx.Err())
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush logs: %w", ctx.Err())
+	}
+}
+
+// Shutdown stops the batching loop and discards entries still queued. It runs after the
+// producer is closed, so remaining entries should be flushed with ForceFlush before then.
+func (e *kafkaLogExporter) Shutdown(ctx context.Context) error {
+	e.producer.Store(nil)
+	if !e.started.Load() {
+		return nil
+	}
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop log exporter: %w", ctx.Err())
+	}
+}
+
+// run batches queued entries, sending a batch when it is full or the flush interval elapses
+func (e *kafkaLogExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*logspb.LogRecord, 0, e.batchSize)
+	send := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = make([]*logspb.LogRecord, 0, e.batchSize)
+		}
+	}
+	for {
+		select {
+		case record := <-e.records:
+			batch = append(batch, record)
+			if len(batch) >= e.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-e.flushes:
+			for drained := false; !drained; {
+				select {
+				case record := <-e.records:
+					batch = append(batch, record)
+					if len(batch) >= e.batchSize {
+						send()
+					}
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(flushed)
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// send writes a batch to Kafka without producing telemetry about the send itself
+func (e *kafkaLogExporter) send(batch []*logspb.LogRecord) {
+	if dropped := e.dropped.Swap(0); dropped > 0 {
+		e.logger.Warn("Dropped service logs; the log export queue was full", zap.Int64("dropped", dropped))
+	}
+	kafkaProducer := e.producer.Load()
+	if kafkaProducer == nil {
+		return
+	}
+	payload := otlp.LogsFromProto([]*logspb.ResourceLogs{{
+		Resource:  e.resource,
+		ScopeLogs: []*logspb.ScopeLogs{{Scope: e.scope, LogRecords: batch}},
+	}})
+	if err := kafkaProducer.sendPayload(withoutTelemetry(context.Background()), e.signal, payload); err != nil {
+		e.logger.Warn("Failed to export service logs to Kafka", zap.Int("log_records", len(batch)), zap.Error(err))
+	}
+}
+
+// kafkaLogCore is the zapcore.Core teed into the service logger to feed kafkaLogExporter
+type kafkaLogCore struct {
+	exporter *kafkaLogExporter
+	fields   []zapcore.Field
+}
+
+// Enabled reports whether entries at level are exported
+func (c *kafkaLogCore) Enabled(level zapcore.Level) bool {
+	return c.exporter.level.Enabled(level)
+}
+
+// With returns a core that adds fields to every entry
+func (c *kafkaLogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &kafkaLogCore{
+		exporter: c.exporter,
+		fields:   append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+// Check adds the core to the checked entry if its level is exported
+func (c *kafkaLogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write converts an entry to an OTLP log record and queues it for export
+func (c *kafkaLogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	c.exporter.enqueue(logEntryToProto(entry, append(c.fields[:len(c.fields):len(c.fields)], fields...)))
+	return nil
+}
+
+// Sync does nothing; entries are flushed by the exporter's batching loop
+func (c *kafkaLogCore) Sync() error {
+	return nil
+}
+
+// logEntryToProto converts a zap entry to an OTLP log record. The trace_id and span_id
+// fields added by LogWithTraceContext become the record's trace context.
+func logEntryToProto(entry zapcore.Entry, fields []zapcore.Field) *logspb.LogRecord {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(entry.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severityToProto(entry.Level),
+		SeverityText:         entry.Level.CapitalString(),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: entry.Message}},
+	}
+	if traceID, ok := encoder.Fields["trace_id"].(string); ok {
+		if id, err := hex.DecodeString(traceID); err == nil && len(id) == 16 {
+			record.TraceId = id
+			delete(encoder.Fields, "trace_id")
+		}
+	}
+	if spanID, ok := encoder.Fields["span_id"].(string); ok {
+		if id, err := hex.DecodeString(spanID); err == nil && len(id) == 8 {
+			record.SpanId = id
+			delete(encoder.Fields, "span_id")
+		}
+	}
+
+	for key, value := range encoder.Fields {
+		record.Attributes = append(record.Attributes, &commonpb.KeyValue{Key: key, Value: logValueToProto(value)})
+	}
+	if entry.LoggerName != "" {
+		record.Attributes = append(record.Attributes, stringKeyValue("logger.name", entry.LoggerName))
+	}
+	if entry.Caller.Defined {
+		record.Attributes = append(record.Attributes,
+			stringKeyValue("code.filepath", entry.Caller.File),
+			&commonpb.KeyValue{Key: "code.lineno", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(entry.Caller.Line)}}},
+		)
+		if entry.Caller.Function != "" {
+			record.Attributes = append(record.Attributes, stringKeyValue("code.function", entry.Caller.Function))
+		}
+	}
+	if entry.Stack != "" {
+		record.Attributes = append(record.Attributes, stringKeyValue("exception.stacktrace", entry.Stack))
+	}
+	return record
+}
+
+// severityToProto maps a zap level to an OTLP severity number
+func severityToProto(level zapcore.Level) logspb.SeverityNumber {
+	switch level {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2
+	case zapcore.PanicLevel, zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+// logValueToProto converts a value produced by zap's map encoder to an OTLP AnyValue
+func logValueToProto(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int8:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int16:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case uint8:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case uint16:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case uint32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v}}
+	case time.Time:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Format(time.RFC3339Nano)}}
+	case []interface{}:
+		values := make([]*commonpb.AnyValue, 0, len(v))
+		for _, item := range v {
+			values = append(values, logValueToProto(item))
+		}
+		return arrayValueToProto(values)
+	case map[string]interface{}:
+		values := make([]*commonpb.KeyValue, 0, len(v))
+		for key, item := range v {
+			values = append(values, &commonpb.KeyValue{Key: key, Value: logValueToProto(item)})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: values}}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+}
+
+// stringKeyValue builds an OTLP string attribute
+func stringKeyValue(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
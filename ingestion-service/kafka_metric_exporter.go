@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Aggregation temporalities selectable for exported self-metrics
+const (
+	temporalityCumulative = "cumulative"
+	temporalityDelta      = "delta"
+)
+
+// kafkaMetricExporter writes the service's own metrics to the metrics topic in the same
+// format as ingested metrics. Like kafkaSpanExporter, the producer is attached once it
+// exists; collections exported before then are dropped.
+type kafkaMetricExporter struct {
+	signal      otlpSignal
+	temporality string
+	producer    atomic.Pointer[KafkaProducer]
+}
+
+// newKafkaMetricExporter creates a metric exporter for the configured metrics topic
+func newKafkaMetricExporter(config *Config) *kafkaMetricExporter {
+	return &kafkaMetricExporter{
+		signal:      newOTLPSignals(config).metrics,
+		temporality: config.OpenTelemetry.Metrics.Temporality,
+	}
+}
+
+// attach starts sending exported metrics through kafkaProducer
+func (e *kafkaMetricExporter) attach(kafkaProducer *KafkaProducer) {
+	e.producer.Store(kafkaProducer)
+}
+
+// Temporality returns the configured temporality for an instrument
+func (e *kafkaMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricsTemporalitySelector(e.temporality)(kind)
+}
+
+// Aggregation returns the default aggregation for an instrument
+func (e *kafkaMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export sends a collection to Kafka without recording telemetry about the send itself
+func (e *kafkaMetricExporter) Export(ctx context.Context, metrics *metricdata.ResourceMetrics) error {
+	kafkaProducer := e.producer.Load()
+	if kafkaProducer == nil || metrics == nil || len(metrics.ScopeMetrics) == 0 {
+		return nil
+	}
+	payload := otlp.MetricsFromProto([]*metricspb.ResourceMetrics{resourceMetricsToProto(metrics)})
+	if payload.ItemCount() == 0 {
+		return nil
+	}
+	return kafkaProducer.sendPayload(withoutTelemetry(ctx), e.signal, payload)
+}
+
+// ForceFlush does nothing; every collection is sent as it is exported
+func (e *kafkaMetricExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown detaches the producer; the producer itself is closed by its owner
+func (e *kafkaMetricExporter) Shutdown(ctx context.Context) error {
+	e.producer.Store(nil)
+	return nil
+}
+
+// metricsTemporalitySelector returns the selector for a configured temporality. Up-down
+// counters stay cumulative under delta temporality, as their deltas are meaningless on their own.
+func metricsTemporalitySelector(temporality string) sdkmetric.TemporalitySelector {
+	if temporality != temporalityDelta {
+		return sdkmetric.DefaultTemporalitySelector
+	}
+	return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+		switch kind {
+		case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+			return metricdata.CumulativeTemporality
+		default:
+			return metricdata.DeltaTemporality
+		}
+	}
+}
+
+// resourceMetricsToProto converts an SDK metrics collection to its OTLP form
+func resourceMetricsToProto(metrics *metricdata.ResourceMetrics) *metricspb.ResourceMetrics {
+	out := &metricspb.ResourceMetrics{Resource: resourceToProto(metrics.Resource)}
+	if metrics.Resource != nil {
+		out.SchemaUrl = metrics.Resource.SchemaURL()
+	}
+	for _, sm := range metrics.ScopeMetrics {
+		scope := &metricspb.ScopeMetrics{Scope: scopeToProto(sm.Scope), SchemaUrl: sm.Scope.SchemaURL}
+		for _, m := range sm.Metrics {
+			if metric := metricToProto(m); metric != nil {
+				scope.Metrics = append(scope.Metrics, metric)
+			}
+		}
+		out.ScopeMetrics = append(out.ScopeMetrics, scope)
+	}
+	return out
+}
+
+// metricToProto converts one SDK metric, returning nil for aggregations OTLP cannot carry
+func metricToProto(m metricdata.Metrics) *metricspb.Metric {
+	out := &metricspb.Metric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+	switch data := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		out.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: numberDataPointsToProto(data.DataPoints)}}
+	case metricdata.Gauge[float64]:
+		out.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: numberDataPointsToProto(data.DataPoints)}}
+	case metricdata.Sum[int64]:
+		out.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             numberDataPointsToProto(data.DataPoints),
+			AggregationTemporality: temporalityToProto(data.Temporality),
+			IsMonotonic:            data.IsMonotonic,
+		}}
+	case metricdata.Sum[float64]:
+		out.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             numberDataPointsToProto(data.DataPoints),
+			AggregationTemporality: temporalityToProto(data.Temporality),
+			IsMonotonic:            data.IsMonotonic,
+		}}
+	case metricdata.Histogram[int64]:
+		out.Data = &metricspb.Metric_Histogram{Histogram: histogramToProto(data)}
+	case metricdata.Histogram[float64]:
+		out.Data = &metricspb.Metric_Histogram{Histogram: histogramToProto(data)}
+	case metricdata.ExponentialHistogram[int64]:
+		out.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: exponentialHistogramToProto(data)}
+	case metricdata.ExponentialHistogram[float64]:
+		out.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: exponentialHistogramToProto(data)}
+	default:
+		return nil
+	}
+	return out
+}
+
+// numberDataPointsToProto converts gauge and sum data points
+func numberDataPointsToProto[N int64 | float64](points []metricdata.DataPoint[N]) []*metricspb.NumberDataPoint {
+	out := make([]*metricspb.NumberDataPoint, 0, len(points))
+	for _, dp := range points {
+		point := &metricspb.NumberDataPoint{
+			Attributes:        attributesToProto(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToUnixNano(dp.StartTime),
+			TimeUnixNano:      timeToUnixNano(dp.Time),
+			Exemplars:         exemplarsToProto(dp.Exemplars),
+		}
+		switch v := any(dp.Value).(type) {
+		case int64:
+			point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: v}
+		case float64:
+			point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: v}
+		}
+		out = append(out, point)
+	}
+	return out
+}
+
+// histogramToProto converts an explicit-bucket histogram
+func histogramToProto[N int64 | float64](h metricdata.Histogram[N]) *metricspb.Histogram {
+	out := &metricspb.Histogram{AggregationTemporality: temporalityToProto(h.Temporality)}
+	for _, dp := range h.DataPoints {
+		sum := float64(dp.Sum)
+		point := &metricspb.HistogramDataPoint{
+			Attributes:        attributesToProto(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToUnixNano(dp.StartTime),
+			TimeUnixNano:      timeToUnixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			BucketCounts:      dp.BucketCounts,
+			ExplicitBounds:    dp.Bounds,
+			Exemplars:         exemplarsToProto(dp.Exemplars),
+			Min:               extremaToProto(dp.Min),
+			Max:               extremaToProto(dp.Max),
+		}
+		out.DataPoints = append(out.DataPoints, point)
+	}
+	return out
+}
+
+// exponentialHistogramToProto converts a base-2 exponential histogram
+func exponentialHistogramToProto[N int64 | float64](h metricdata.ExponentialHistogram[N]) *metricspb.ExponentialHistogram {
+	out := &metricspb.ExponentialHistogram{AggregationTemporality: temporalityToProto(h.Temporality)}
+	for _, dp := range h.DataPoints {
+		sum := float64(dp.Sum)
+		point := &metricspb.ExponentialHistogramDataPoint{
+			Attributes:        attributesToProto(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToUnixNano(dp.StartTime),
+			TimeUnixNano:      timeToUnixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			Scale:             dp.Scale,
+			ZeroCount:         dp.ZeroCount,
+			Positive: &metricspb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       dp.PositiveBucket.Offset,
+				BucketCounts: dp.PositiveBucket.Counts,
+			},
+			Negative: &metricspb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       dp.NegativeBucket.Offset,
+				BucketCounts: dp.NegativeBucket.Counts,
+			},
+			Exemplars:     exemplarsToProto(dp.Exemplars),
+			Min:           extremaToProto(dp.Min),
+			Max:           extremaToProto(dp.Max),
+			ZeroThreshold: dp.ZeroThreshold,
+		}
+		out.DataPoints = append(out.DataPoints, point)
+	}
+	return out
+}
+
+// exemplarsToProto converts the exemplars of a data point
+func exemplarsToProto[N int64 | float64](exemplars []metricdata.Exemplar[N]) []*metricspb.Exemplar {
+	if len(exemplars) == 0 {
+		return nil
+	}
+	out := make([]*metricspb.Exemplar, 0, len(exemplars))
+	for _, e := range exemplars {
+		exemplar := &metricspb.Exemplar{
+			FilteredAttributes: attributesToProto(e.FilteredAttributes),
+			TimeUnixNano:       timeToUnixNano(e.Time),
+			SpanId:             e.SpanID,
+			TraceId:            e.TraceID,
+		}
+		switch v := any(e.Value).(type) {
+		case int64:
+			exemplar.Value = &metricspb.Exemplar_AsInt{AsInt: v}
+		case float64:
+			exemplar.Value = &metricspb.Exemplar_AsDouble{AsDouble: v}
+		}
+		out = append(out, exemplar)
+	}
+	return out
+}
+
+// extremaToProto returns a histogram minimum or maximum, or nil if it was not recorded
+func extremaToProto[N int64 | float64](e metricdata.Extrema[N]) *float64 {
+	v, ok := e.Value()
+	if !ok {
+		return nil
+	}
+	f := float64(v)
+	return &f
+}
+
+// temporalityToProto converts an SDK temporality to its OTLP form
+func temporalityToProto(temporality metricdata.Temporality) metricspb.AggregationTemporality {
+	switch temporality {
+	case metricdata.DeltaTemporality:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case metricdata.CumulativeTemporality:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	default:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+	}
+}
+
+// timeToUnixNano converts a timestamp to OTLP nanoseconds, mapping the zero time to 0
+func timeToUnixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
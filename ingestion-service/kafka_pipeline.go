@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// Producer durability modes, controlling when an export request is acknowledged
+const (
+	// durabilityFireAndForget acknowledges once records are handed to the producer,
+	// dropping them if the in-flight queue is full
+	durabilityFireAndForget = "fire_and_forget"
+	// durabilityEnqueue acknowledges once records are queued, waiting for queue space
+	durabilityEnqueue = "enqueue"
+	// durabilityBrokerAck acknowledges once Kafka has acknowledged every record
+	durabilityBrokerAck = "broker_ack"
+)
+
+var (
+	errProducerQueueFull = errors.New("kafka producer queue is full")
+	errProducerClosed    = errors.New("kafka producer is closed")
+)
+
+// produceBatch tracks the broker acknowledgements of the messages sent for one request
+type produceBatch struct {
+	mu      sync.Mutex
+	pending int
+	errs    sarama.ProducerErrors
+	done    chan struct{}
+}
+
+// newProduceBatch creates a batch waiting for n acknowledgements
+func newProduceBatch(n int) *produceBatch {
+	b := &produceBatch{pending: n, done: make(chan struct{})}
+	if n == 0 {
+		close(b.done)
+	}
+	return b
+}
+
+// complete records the outcome of one message; err is nil on success
+func (b *produceBatch) complete(err *sarama.ProducerError) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil {
+		b.errs = append(b.errs, err)
+	}
+	b.pending--
+	if b.pending == 0 {
+		close(b.done)
+	}
+}
+
+// wait blocks until every message is acknowledged, returning the failed messages as
+// sarama.ProducerErrors
+func (b *produceBatch) wait(ctx context.Context) error {
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	return nil
+}
+
+// enqueue hands a message to the async producer once an in-flight slot is free.
+// When wait is false a full queue fails immediately instead of waiting for space.
+func (kp *KafkaProducer) enqueue(ctx context.Context, message *sarama.ProducerMessage, wait bool) error {
+	if wait {
+		timer := time.NewTimer(kp.config.Kafka.Producer.EnqueueTimeout)
+		defer timer.Stop()
+
+		select {
+		case kp.inflight <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return errProducerQueueFull
+		}
+	} else {
+		select {
+		case kp.inflight <- struct{}{}:
+		default:
+			return errProducerQueueFull
+		}
+	}
+
+	// Start the stall clock when the queue goes from idle to busy
+	if len(kp.inflight) == 1 {
+		kp.lastProgress.Store(time.Now().UnixNano())
+	}
+
+	// Close takes the write lock only after closing kp.stop, so a send blocked on a full
+	// producer input gives up instead of holding the read lock forever
+	kp.mu.RLock()
+	defer kp.mu.RUnlock()
+	if kp.closed {
+		<-kp.inflight
+		return errProducerClosed
+	}
+	select {
+	case kp.producer.Input() <- message:
+		return nil
+	case <-kp.stop:
+		<-kp.inflight
+		return errProducerClosed
+	case <-ctx.Done():
+		<-kp.inflight
+		return ctx.Err()
+	}
+}
+
+// drainSuccesses releases in-flight slots and completes batches for delivered messages
+func (kp *KafkaProducer) drainSuccesses() {
+	defer kp.drainers.Done()
+
+	for message := range kp.producer.Successes() {
+		<-kp.inflight
+		kp.lastProgress.Store(time.Now().UnixNano())
+		if batch, ok := message.Metadata.(*produceBatch); ok {
+			batch.complete(nil)
+		}
+	}
+}
+
+// deadLetterQueueSize bounds the failed messages waiting for the dead-letter publisher
+const deadLetterQueueSize = 1024
+
+// deadLetterJob is a failed message waiting to be sent to its dead-letter topic
+type deadLetterJob struct {
+	message *sarama.ProducerMessage
+	reason  string
+	cause   error
+}
+
+// drainErrors releases in-flight slots and completes batches for failed messages.
+// Failures are always logged since requests not waiting for broker acks never see them.
+// Those messages are spooled when the error is retryable and the spool is enabled,
+// and sent to the dead-letter topic otherwise.
+func (kp *KafkaProducer) drainErrors() {
+	defer kp.drainers.Done()
+
+	// A single publisher sends dead letters so a full producer input never blocks draining;
+	// when its queue is full further dead letters are dropped
+	deadLetters := make(chan deadLetterJob, deadLetterQueueSize)
+	var publisher sync.WaitGroup
+	publisher.Add(1)
+	go func() {
+		defer publisher.Done()
+		for job := range deadLetters {
+			kp.deadLetter(context.Background(), job.message, job.reason, job.cause)
+		}
+	}()
+	defer func() {
+		close(deadLetters)
+		publisher.Wait()
+	}()
+
+	for producerErr := range kp.producer.Errors() {
+		<-kp.inflight
+		kp.lastProgress.Store(time.Now().UnixNano())
+		kp.logger.Error("Failed to deliver message to Kafka",
+			zap.Error(producerErr.Err),
+			zap.String("topic", producerErr.Msg.Topic),
+		)
+		kp.telemetryManager.metrics.recordProduceErrors(context.Background(), producerErr.Msg.Topic, "delivery", 1)
+
+		switch metadata := producerErr.Msg.Metadata.(type) {
+		case *produceBatch:
+			metadata.complete(producerErr)
+		case deadLetterMarker:
+			// Dead letters that cannot be delivered are only logged
+		default:
+			reason := deadLetterReason(producerErr.Err)
+			if !isPermanentKafkaError(producerErr.Err) {
+				if kp.spool != nil && kp.spoolMessages([]*sarama.ProducerMessage{producerErr.Msg}) == nil {
+					continue
+				}
+				reason = deadLetterReasonUndeliverable
+			}
+			select {
+			case deadLetters <- deadLetterJob{message: producerErr.Msg, reason: reason, cause: producerErr.Err}:
+			default:
+				kp.logger.Error("Dead-letter queue is full, dropping failed message",
+					zap.String("topic", producerErr.Msg.Topic),
+					zap.String("reason", reason),
+				)
+			}
+		}
+	}
+}
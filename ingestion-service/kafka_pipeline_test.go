@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap"
+)
+
+// newTestProducer returns a Kafka producer writing to a mock async producer, with queueSize
+// in-flight slots
+func newTestProducer(t *testing.T, durability string, queueSize int) (*KafkaProducer, *mocks.AsyncProducer) {
+	t.Helper()
+	config := &Config{}
+	setDefaults(config)
+	config.Kafka.Producer.Durability = durability
+	config.Kafka.Producer.QueueSize = queueSize
+	config.Kafka.Producer.EnqueueTimeout = 10 * time.Millisecond
+
+	saramaConfig := mocks.NewTestConfig()
+	saramaConfig.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, saramaConfig)
+	kp := &KafkaProducer{
+		producer:         producer,
+		logger:           zap.NewNop(),
+		telemetryManager: newTestTelemetryManager(t, config),
+		config:           config,
+		inflight:         make(chan struct{}, queueSize),
+		stop:             make(chan struct{}),
+	}
+	kp.drainers.Add(2)
+	go kp.drainSuccesses()
+	go kp.drainErrors()
+	return kp, producer
+}
+
+func TestSendRecordsDurability(t *testing.T) {
+	tests := []struct {
+		name       string
+		durability string
+		occupied   int     // in-flight slots taken before sending, out of two
+		delivery   []error // outcome of each message handed to the producer, nil on success
+		wantErr    error
+		wantFailed int // messages returned as sarama.ProducerErrors
+	}{
+		{
+			name:       "broker_ack delivered",
+			durability: durabilityBrokerAck,
+			delivery:   []error{nil, nil},
+		},
+		{
+			name:       "broker_ack returns failed deliveries",
+			durability: durabilityBrokerAck,
+			delivery:   []error{nil, sarama.ErrMessageSizeTooLarge},
+			wantFailed: 1,
+		},
+		{
+			name:       "broker_ack returns messages not enqueued",
+			durability: durabilityBrokerAck,
+			occupied:   2,
+			wantFailed: 2,
+		},
+		{
+			name:       "enqueue ignores failed deliveries",
+			durability: durabilityEnqueue,
+			delivery:   []error{nil, sarama.ErrNotLeaderForPartition},
+		},
+		{
+			name:       "enqueue fails when the queue stays full",
+			durability: durabilityEnqueue,
+			occupied:   2,
+			wantErr:    errProducerQueueFull,
+		},
+		{
+			name:       "fire_and_forget drops messages when the queue is full",
+			durability: durabilityFireAndForget,
+			occupied:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp, producer := newTestProducer(t, tt.durability, 2)
+			for i := 0; i < tt.occupied; i++ {
+				kp.inflight <- struct{}{}
+			}
+			for _, err := range tt.delivery {
+				if err == nil {
+					producer.ExpectInputAndSucceed()
+				} else {
+					producer.ExpectInputAndFail(err)
+				}
+			}
+
+			err := kp.SendRecordsWithTracing(context.Background(), "otel.traces",
+				[]kafkaRecord{{key: "a", value: 1}, {key: "b", value: 2}}, nil)
+			var producerErrs sarama.ProducerErrors
+			errors.As(err, &producerErrs)
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && len(producerErrs) != tt.wantFailed:
+				t.Errorf("error = %v, want %d failed messages", err, tt.wantFailed)
+			}
+
+			// Closing waits for the drains, which must release every slot they were given
+			if err := kp.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(kp.inflight); got != tt.occupied {
+				t.Errorf("%d in-flight slots taken after close, want %d", got, tt.occupied)
+			}
+		})
+	}
+}
+
+func TestSendAfterClose(t *testing.T) {
+	tests := []struct {
+		durability string
+		wantErr    bool
+	}{
+		{durability: durabilityFireAndForget},
+		{durability: durabilityEnqueue, wantErr: true},
+		{durability: durabilityBrokerAck, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.durability, func(t *testing.T) {
+			kp, _ := newTestProducer(t, tt.durability, 1)
+			if err := kp.Close(); err != nil {
+				t.Fatal(err)
+			}
+			err := kp.SendMessageWithTracing(context.Background(), "otel.traces", "a", 1, nil)
+			var producerErrs sarama.ProducerErrors
+			closed := errors.Is(err, errProducerClosed) ||
+				errors.As(err, &producerErrs) && errors.Is(producerErrs[0].Err, errProducerClosed)
+			if closed != tt.wantErr || (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want closed error %v", err, tt.wantErr)
+			}
+			if got := len(kp.inflight); got != 0 {
+				t.Errorf("%d in-flight slots taken, want 0", got)
+			}
+		})
+	}
+}
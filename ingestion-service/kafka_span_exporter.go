@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// kafkaSpanExporter writes the service's own spans to the traces topic in the same format
+// as ingested traces. The tracer provider is created before the Kafka producer, so the
+// producer is attached later; spans exported before then are dropped.
+type kafkaSpanExporter struct {
+	signal   otlpSignal
+	producer atomic.Pointer[KafkaProducer]
+}
+
+// newKafkaSpanExporter creates a span exporter for the configured traces topic
+func newKafkaSpanExporter(config *Config) *kafkaSpanExporter {
+	return &kafkaSpanExporter{signal: newOTLPSignals(config).traces}
+}
+
+// attach starts sending exported spans through kafkaProducer
+func (e *kafkaSpanExporter) attach(kafkaProducer *KafkaProducer) {
+	e.producer.Store(kafkaProducer)
+}
+
+// ExportSpans sends spans to Kafka without tracing the send itself
+func (e *kafkaSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	kafkaProducer := e.producer.Load()
+	if kafkaProducer == nil || len(spans) == 0 {
+		return nil
+	}
+	payload := otlp.TracesFromProto(spansToProto(spans))
+	return kafkaProducer.sendPayload(withoutTelemetry(ctx), e.signal, payload)
+}
+
+// Shutdown detaches the producer; the producer itself is closed by its owner
+func (e *kafkaSpanExporter) Shutdown(ctx context.Context) error {
+	e.producer.Store(nil)
+	return nil
+}
+
+// spansToProto converts SDK spans to OTLP resource spans, grouped by resource and scope
+func spansToProto(spans []sdktrace.ReadOnlySpan) []*tracepb.ResourceSpans {
+	var resourceSpans []*tracepb.ResourceSpans
+	byResource := make(map[attribute.Distinct]*tracepb.ResourceSpans)
+	byScope := make(map[*tracepb.ResourceSpans]map[string]*tracepb.ScopeSpans)
+
+	for _, span := range spans {
+		resourceKey := span.Resource().Equivalent()
+		rs, ok := byResource[resourceKey]
+		if !ok {
+			rs = &tracepb.ResourceSpans{
+				Resource:  resourceToProto(span.Resource()),
+				SchemaUrl: span.Resource().SchemaURL(),
+			}
+			byResource[resourceKey] = rs
+			byScope[rs] = make(map[string]*tracepb.ScopeSpans)
+			resourceSpans = append(resourceSpans, rs)
+		}
+
+		scope := span.InstrumentationScope()
+		scopeKey := scope.Name + "\x00" + scope.Version
+		ss, ok := byScope[rs][scopeKey]
+		if !ok {
+			ss = &tracepb.ScopeSpans{Scope: scopeToProto(scope), SchemaUrl: scope.SchemaURL}
+			byScope[rs][scopeKey] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, spanToProto(span))
+	}
+	return resourceSpans
+}
+
+// spanToProto converts one SDK span to its OTLP form
+func spanToProto(span sdktrace.ReadOnlySpan) *tracepb.Span {
+	traceID, spanID := spanContextIDs(span.SpanContext())
+	out := &tracepb.Span{
+		TraceId:                traceID,
+		SpanId:                 spanID,
+		TraceState:             span.SpanContext().TraceState().String(),
+		Name:                   span.Name(),
+		Kind:                   tracepb.Span_SpanKind(span.SpanKind()),
+		StartTimeUnixNano:      uint64(span.StartTime().UnixNano()),
+		EndTimeUnixNano:        uint64(span.EndTime().UnixNano()),
+		Attributes:             attributesToProto(span.Attributes()),
+		DroppedAttributesCount: uint32(span.DroppedAttributes()),
+		DroppedEventsCount:     uint32(span.DroppedEvents()),
+		DroppedLinksCount:      uint32(span.DroppedLinks()),
+		Status:                 statusToProto(span.Status()),
+	}
+	if span.Parent().IsValid() {
+		_, out.ParentSpanId = spanContextIDs(span.Parent())
+	}
+	for _, event := range span.Events() {
+		out.Events = append(out.Events, &tracepb.Span_Event{
+			TimeUnixNano:           uint64(event.Time.UnixNano()),
+			Name:                   event.Name,
+			Attributes:             attributesToProto(event.Attributes),
+			DroppedAttributesCount: uint32(event.DroppedAttributeCount),
+		})
+	}
+	for _, link := range span.Links() {
+		linkTraceID, linkSpanID := spanContextIDs(link.SpanContext)
+		out.Links = append(out.Links, &tracepb.Span_Link{
+			TraceId:                linkTraceID,
+			SpanId:                 linkSpanID,
+			TraceState:             link.SpanContext.TraceState().String(),
+			Attributes:             attributesToProto(link.Attributes),
+			DroppedAttributesCount: uint32(link.DroppedAttributeCount),
+		})
+	}
+	return out
+}
+
+// statusToProto converts a span status; the SDK and OTLP number the codes differently
+func statusToProto(status sdktrace.Status) *tracepb.Status {
+	out := &tracepb.Status{Message: status.Description}
+	switch status.Code {
+	case codes.Ok:
+		out.Code = tracepb.Status_STATUS_CODE_OK
+	case codes.Error:
+		out.Code = tracepb.Status_STATUS_CODE_ERROR
+	default:
+		out.Code = tracepb.Status_STATUS_CODE_UNSET
+	}
+	return out
+}
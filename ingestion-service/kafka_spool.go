@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"telemorph-prime/ingestion-service/internal/spool"
+)
+
+// openSpool opens the configured disk spool and registers its gauges
+func (kp *KafkaProducer) openSpool() error {
+	cfg := kp.config.Kafka.Spool
+	s, err := spool.Open(cfg.Directory, spool.Options{
+		MaxBytes:     cfg.MaxBytes,
+		MaxAge:       cfg.MaxAge,
+		SegmentBytes: cfg.SegmentBytes,
+		Eviction:     spool.EvictionPolicy(cfg.EvictionPolicy),
+		SyncInterval: cfg.SyncInterval,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %w", err)
+	}
+	kp.spool = s
+
+	meter := kp.telemetryManager.GetMeter()
+	entries, err := meter.Int64ObservableGauge("ingestion.spool.entries",
+		metric.WithDescription("Messages waiting in the disk spool for replay to Kafka"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool entries gauge: %w", err)
+	}
+	size, err := meter.Int64ObservableGauge("ingestion.spool.size",
+		metric.WithDescription("Size of the disk spool segments"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool size gauge: %w", err)
+	}
+	segments, err := meter.Int64ObservableGauge("ingestion.spool.segments",
+		metric.WithDescription("Number of disk spool segment files"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool segments gauge: %w", err)
+	}
+	evicted, err := meter.Int64ObservableCounter("ingestion.spool.evicted",
+		metric.WithDescription("Spooled messages discarded by the size or age limit"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool evicted counter: %w", err)
+	}
+	corrupted, err := meter.Int64ObservableCounter("ingestion.spool.corrupted",
+		metric.WithDescription("Spooled messages discarded because they failed their checksum"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool corrupted counter: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := s.Stats()
+		o.ObserveInt64(entries, stats.Entries)
+		o.ObserveInt64(size, stats.Bytes)
+		o.ObserveInt64(segments, int64(stats.Segments))
+		o.ObserveInt64(evicted, stats.Evicted)
+		o.ObserveInt64(corrupted, stats.Corrupted)
+		return nil
+	}, entries, size, segments, evicted, corrupted)
+	if err != nil {
+		return fmt.Errorf("failed to register spool metrics: %w", err)
+	}
+	return nil
+}
+
+// spoolMessages writes messages to the disk spool for later replay
+func (kp *KafkaProducer) spoolMessages(messages []*sarama.ProducerMessage) error {
+	for i, message := range messages {
+		entry, err := spoolEntry(message)
+		if err == nil {
+			err = kp.spool.Append(entry)
+		}
+		if err != nil {
+			kp.logger.Error("Failed to spool messages",
+				zap.Error(err),
+				zap.String("topic", message.Topic),
+				zap.Int("lost", len(messages)-i),
+			)
+			return err
+		}
+	}
+
+	kp.logger.Warn("Kafka unavailable, spooled messages to disk",
+		zap.String("topic", messages[0].Topic),
+		zap.Int("messages", len(messages)),
+	)
+	return nil
+}
+
+// spoolRetryableErrors spools the messages of a failed batch that Kafka may still accept.
+// It returns the original error if they could not be spooled, and otherwise only the
+// permanently rejected messages.
+func (kp *KafkaProducer) spoolRetryableErrors(err error) error {
+	var producerErrs sarama.ProducerErrors
+	if kp.spool == nil || !errors.As(err, &producerErrs) {
+		return err
+	}
+
+	var rejected sarama.ProducerErrors
+	var retryable []*sarama.ProducerMessage
+	for _, producerErr := range producerErrs {
+		if isPermanentKafkaError(producerErr.Err) {
+			rejected = append(rejected, producerErr)
+		} else {
+			retryable = append(retryable, producerErr.Msg)
+		}
+	}
+	if len(retryable) > 0 && kp.spoolMessages(retryable) != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		return rejected
+	}
+	return nil
+}
+
+// replaySpool periodically drains the spool to Kafka until the producer is closed
+func (kp *KafkaProducer) replaySpool() {
+	defer kp.replayer.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-kp.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(kp.config.Kafka.Spool.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for kp.replayBatch(ctx) {
+		}
+	}
+}
+
+// replayBatch sends the oldest spooled messages to Kafka in order and removes them from
+// the spool once Kafka has acknowledged them. It reports whether more may be waiting.
+func (kp *KafkaProducer) replayBatch(ctx context.Context) bool {
+	entries, cursor, err := kp.spool.Read(kp.config.Kafka.Spool.ReplayBatchSize)
+	if err != nil {
+		kp.logger.Error("Failed to read spool", zap.Error(err))
+		return false
+	}
+	if len(entries) == 0 {
+		// Commit past any expired or corrupt entries that were skipped
+		if err := kp.spool.Commit(cursor); err != nil {
+			kp.logger.Error("Failed to commit spool position", zap.Error(err))
+		}
+		return false
+	}
+
+	ctx, span := kp.telemetryManager.CreateSpan(ctx, "kafka.spool.replay",
+		trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(entries))),
+	)
+	defer span.End()
+
+	batch := newProduceBatch(len(entries))
+	messages := make([]*sarama.ProducerMessage, 0, len(entries))
+	for _, entry := range entries {
+		message := producerMessage(entry)
+		message.Metadata = batch
+		messages = append(messages, message)
+	}
+	for i, message := range messages {
+		if err := kp.enqueue(ctx, message, true); err != nil {
+			for _, unsent := range messages[i:] {
+				batch.complete(&sarama.ProducerError{Msg: unsent, Err: err})
+			}
+			break
+		}
+	}
+
+	if err := batch.wait(ctx); err != nil {
+		span.RecordError(err)
+		if _, permanent := rejectedKafkaItems(err, int64(len(messages))); !permanent {
+			span.SetStatus(codes.Error, "Kafka still unavailable")
+			kp.logger.Debug("Kafka still unavailable, retrying spool replay later", zap.Error(err))
+			return false
+		}
+		var producerErrs sarama.ProducerErrors
+		if errors.As(err, &producerErrs) {
+			for _, producerErr := range producerErrs {
+				kp.deadLetter(ctx, producerErr.Msg, deadLetterReason(producerErr.Err), producerErr.Err)
+			}
+		}
+	}
+
+	if err := kp.spool.Commit(cursor); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to commit spool position")
+		kp.logger.Error("Failed to commit spool position", zap.Error(err))
+		return false
+	}
+
+	span.SetStatus(codes.Ok, "Replayed spooled messages")
+	kp.logger.Info("Replayed spooled messages to Kafka", zap.Int("messages", len(messages)))
+	return true
+}
+
+// spoolEntry converts a producer message into a spool entry
+func spoolEntry(message *sarama.ProducerMessage) (spool.Entry, error) {
+	entry := spool.Entry{
+		Topic:     message.Topic,
+		Timestamp: time.Now(),
+	}
+	if message.Key != nil {
+		key, err := message.Key.Encode()
+		if err != nil {
+			return spool.Entry{}, fmt.Errorf("failed to encode message key: %w", err)
+		}
+		entry.Key = key
+	}
+	if message.Value != nil {
+		value, err := message.Value.Encode()
+		if err != nil {
+			return spool.Entry{}, fmt.Errorf("failed to encode message value: %w", err)
+		}
+		entry.Value = value
+	}
+	for _, h := range message.Headers {
+		entry.Headers = append(entry.Headers, spool.Header{Key: h.Key, Value: h.Value})
+	}
+	return entry, nil
+}
+
+// producerMessage converts a spool entry back into a producer message
+func producerMessage(entry spool.Entry) *sarama.ProducerMessage {
+	message := &sarama.ProducerMessage{
+		Topic: entry.Topic,
+		Value: sarama.ByteEncoder(entry.Value),
+	}
+	if len(entry.Key) > 0 {
+		message.Key = sarama.ByteEncoder(entry.Key)
+	}
+	for _, h := range entry.Headers {
+		message.Headers = append(message.Headers, sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return message
+}
@@ -0,0 +1,120 @@
+// Package kafkatrace propagates W3C trace context through Kafka record headers.
+//
+// The ingestion service injects the context of its kafka.produce span into every
+// record it publishes. Consumers use Extract or Link to continue or link to that
+// trace from their own processing spans.
+package kafkatrace
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProducerHeaderCarrier adapts the headers of a sarama.ProducerMessage to a propagation.TextMapCarrier
+type ProducerHeaderCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+var _ propagation.TextMapCarrier = ProducerHeaderCarrier{}
+
+// NewProducerHeaderCarrier returns a carrier over the message's headers
+func NewProducerHeaderCarrier(msg *sarama.ProducerMessage) ProducerHeaderCarrier {
+	return ProducerHeaderCarrier{msg: msg}
+}
+
+// Get returns the value of the header with the given key
+func (c ProducerHeaderCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the header with the given key, or appends it if absent
+func (c ProducerHeaderCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of all headers
+func (c ProducerHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		keys = append(keys, string(h.Key))
+	}
+	return keys
+}
+
+// ConsumerHeaderCarrier adapts the headers of a sarama.ConsumerMessage to a propagation.TextMapCarrier
+type ConsumerHeaderCarrier struct {
+	msg *sarama.ConsumerMessage
+}
+
+var _ propagation.TextMapCarrier = ConsumerHeaderCarrier{}
+
+// NewConsumerHeaderCarrier returns a carrier over the message's headers
+func NewConsumerHeaderCarrier(msg *sarama.ConsumerMessage) ConsumerHeaderCarrier {
+	return ConsumerHeaderCarrier{msg: msg}
+}
+
+// Get returns the value of the header with the given key
+func (c ConsumerHeaderCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the header with the given key, or appends it if absent
+func (c ConsumerHeaderCarrier) Set(key, value string) {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			h.Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of all headers
+func (c ConsumerHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
+
+// Inject writes the trace context of ctx into the message headers using the global propagator
+func Inject(ctx context.Context, msg *sarama.ProducerMessage) {
+	otel.GetTextMapPropagator().Inject(ctx, NewProducerHeaderCarrier(msg))
+}
+
+// Extract returns ctx with the trace context and baggage carried by the message headers,
+// so that spans started from it become children of the producing span
+func Extract(ctx context.Context, msg *sarama.ConsumerMessage) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, NewConsumerHeaderCarrier(msg))
+}
+
+// Link returns a span link to the span that produced the message. It is meant for
+// consumer spans that process records from many requests and so cannot have a single parent.
+// The returned link has an invalid span context if the message carries no trace context.
+func Link(msg *sarama.ConsumerMessage) trace.Link {
+	ctx := Extract(context.Background(), msg)
+	return trace.Link{SpanContext: trace.SpanContextFromContext(ctx)}
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,6 +20,10 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+
+	"telemorph-prime/ingestion-service/internal/spool"
+	"telemorph-prime/ingestion-service/kafkatrace"
 )
 
 func main() {
@@ -48,14 +55,10 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to initialize telemetry manager", zap.Error(err))
 	}
-	defer telemetryManager.Shutdown(context.Background())
-
-	// Start telemetry server
-	telemetryManager.StartTelemetryServer()
+	logger = telemetryManager.Logger()
 
 	// Create root span for service startup
 	ctx, span := telemetryManager.CreateSpan(context.Background(), "service.startup")
-	defer span.End()
 
 	telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Starting Telemorph-Prime Ingestion Service",
 		zap.String("service_name", config.OpenTelemetry.ServiceName),
@@ -70,13 +73,83 @@ func main() {
 		span.SetStatus(codes.Error, "Failed to initialize Kafka producer")
 		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
 	}
-	defer kafkaProducer.Close()
+	telemetryManager.AttachKafkaProducer(kafkaProducer)
+
+	// Start health check server with tracing; readiness reports not ready until the
+	// receivers are up and again once shutdown begins
+	health := newHealthRegistry(config.Health.CheckTimeout)
+	kafkaChecker := newKafkaHealthChecker(config)
+	registerHealthChecks(health, config, kafkaProducer, kafkaChecker)
+	healthTLS, err := newTLSReloader("health", config.Server.TLS.Health, []string{"h2", "http/1.1"}, logger)
+	if err != nil {
+		logger.Fatal("Failed to configure health server TLS", zap.Error(err))
+	}
+	healthServer := startHealthServerWithTracing(config, health, healthTLS, logger, telemetryManager)
+
+	// Limit concurrent OTLP requests across both receivers
+	admission, err := newAdmissionController(config, telemetryManager)
+	if err != nil {
+		logger.Fatal("Failed to initialize admission control", zap.Error(err))
+	}
+
+	// Authenticate OTLP requests and resolve their tenant
+	auth, err := newAuthenticator(config, telemetryManager, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize authentication", zap.Error(err))
+	}
+
+	// Start the admin server, if enabled, behind the same authentication as the receivers
+	var adminServer *http.Server
+	var dlqInspector *deadLetterInspector
+	if config.Admin.Enabled {
+		adminTLS, err := newTLSReloader("admin", config.Server.TLS.Admin, []string{"h2", "http/1.1"}, logger)
+		if err != nil {
+			logger.Fatal("Failed to configure admin server TLS", zap.Error(err))
+		}
+		dlqInspector = newDeadLetterInspector(config, telemetryManager)
+		adminServer = startAdminServer(config, auth, dlqInspector, adminTLS, logger)
+	}
+
+	// Attribute OTLP requests to tenants and enforce per-tenant quotas
+	tenants, err := newTenantRegistry(config, telemetryManager)
+	if err != nil {
+		logger.Fatal("Failed to initialize tenancy", zap.Error(err))
+	}
+
+	// Limit ingestion rates globally and per tenant and service
+	limiter, err := newRateLimiter(config, telemetryManager)
+	if err != nil {
+		logger.Fatal("Failed to initialize rate limits", zap.Error(err))
+	}
+
+	// Check received payloads against the OTLP schema
+	validator, err := newPayloadValidator(config, telemetryManager)
+	if err != nil {
+		logger.Fatal("Failed to initialize validation", zap.Error(err))
+	}
+
+	// Scrub sensitive attribute values before they reach Kafka
+	redactor, err := newPayloadRedactor(config, telemetryManager)
+	if err != nil {
+		logger.Fatal("Failed to initialize redaction", zap.Error(err))
+	}
+
+	// Load the receivers' TLS certificates; they are reloaded when the files change
+	grpcTLS, err := newTLSReloader("grpc", config.Server.TLS.GRPC, []string{"h2"}, logger)
+	if err != nil {
+		logger.Fatal("Failed to configure gRPC OTLP server TLS", zap.Error(err))
+	}
+	httpTLS, err := newTLSReloader("http", config.Server.TLS.HTTP, []string{"h2", "http/1.1"}, logger)
+	if err != nil {
+		logger.Fatal("Failed to configure HTTP OTLP server TLS", zap.Error(err))
+	}
 
-	// Start health check server with tracing
-	go startHealthServerWithTracing(config, logger, telemetryManager)
+	// Start gRPC OTLP server with tracing
+	grpcServer := startGRPCOTLPServerWithTracing(config, kafkaProducer, auth, tenants, limiter, validator, redactor, admission, health, grpcTLS, logger, telemetryManager)
 
 	// Start HTTP OTLP server with tracing
-	go startHTTPOTLPServerWithTracing(config, kafkaProducer, logger, telemetryManager)
+	httpServer := startHTTPOTLPServerWithTracing(config, kafkaProducer, auth, tenants, limiter, validator, redactor, admission, health, httpTLS, logger, telemetryManager)
+	health.SetReady(true)
 
 	telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Ingestion service started successfully",
 		zap.String("grpc_endpoint", config.Server.GRPCEndpoint),
@@ -89,8 +162,79 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
-	telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Shutting down ingestion service...")
-	telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Ingestion service stopped")
+	telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Shutting down ingestion service...",
+		zap.Duration("timeout", config.Performance.GracefulShutdownTimeout),
+	)
+	// End the startup span before the providers flush so that it is exported
+	span.End()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Performance.GracefulShutdownTimeout)
+	defer cancel()
+
+	// Report not ready first so load balancers stop routing new requests here
+	health.SetReady(false)
+	shutdownReceivers(shutdownCtx, logger, httpServer, grpcServer)
+	auth.Close()
+
+	// No new requests can reach the producer now, so flush self-telemetry and drain what
+	// is still queued. A quarter of the budget is kept for the remaining listeners and the
+	// telemetry shutdown, so that a slow drain cannot use all of it.
+	telemetryManager.ForceFlush(shutdownCtx)
+	deadline, _ := shutdownCtx.Deadline()
+	producerCtx, cancelProducer := context.WithDeadline(shutdownCtx, deadline.Add(-config.Performance.GracefulShutdownTimeout/4))
+	defer cancelProducer()
+	if err := kafkaProducer.Shutdown(producerCtx); err != nil {
+		logger.Error("Failed to drain Kafka producer", zap.Error(err))
+	}
+
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down health server", zap.Error(err))
+	}
+	kafkaChecker.Close()
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down admin server", zap.Error(err))
+		}
+		dlqInspector.Close()
+	}
+
+	logger.Info("Ingestion service stopped")
+	telemetryManager.Shutdown(shutdownCtx)
+}
+
+// shutdownReceivers stops the OTLP servers from accepting connections and waits for
+// in-flight requests to finish, cutting them off if ctx expires first
+func shutdownReceivers(ctx context.Context, logger *zap.Logger, httpServer *http.Server, grpcServer *grpc.Server) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			logger.Error("Failed to shut down HTTP OTLP server", zap.Error(err))
+			httpServer.Close()
+		}
+	}()
+
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				logger.Error("Failed to shut down gRPC OTLP server", zap.Error(ctx.Err()))
+				grpcServer.Stop()
+			}
+		}()
+	}
+
+	wg.Wait()
 }
 
 // createLogger creates a logger based on configuration
@@ -127,7 +271,7 @@ func createLogger(config LoggingConfig) (*zap.Logger, error) {
 }
 
 // startHealthServerWithTracing starts the health check HTTP server with tracing
-func startHealthServerWithTracing(config *Config, logger *zap.Logger, tm *TelemetryManager) {
+func startHealthServerWithTracing(config *Config, health *healthRegistry, serverTLS *tlsReloader, logger *zap.Logger, tm *TelemetryManager) *http.Server {
 	mux := http.NewServeMux()
 
 	// Health check endpoint with tracing
@@ -170,25 +314,68 @@ func startHealthServerWithTracing(config *Config, logger *zap.Logger, tm *Teleme
 		)
 		defer span.End()
 
+		ok, checks := health.CheckReadiness(ctx)
+		statusCode, readiness := http.StatusOK, "ready"
+		if !ok {
+			statusCode, readiness = http.StatusServiceUnavailable, "not_ready"
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+		w.WriteHeader(statusCode)
 
 		response := map[string]interface{}{
-			"status":    "ready",
+			"status":    readiness,
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
 			"service":   config.OpenTelemetry.ServiceName,
+			"checks":    checks,
 		}
 
 		json.NewEncoder(w).Encode(response)
 
 		span.SetAttributes(
-			attribute.Int("http.status_code", http.StatusOK),
-			attribute.String("health.status", "ready"),
+			attribute.Int("http.status_code", statusCode),
+			attribute.String("health.status", readiness),
 		)
 
 		tm.LogWithTraceContext(ctx, zap.InfoLevel, "Readiness check completed")
 	})
 
+	// Liveness check endpoint with tracing
+	mux.HandleFunc(config.Health.LivenessEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tm.CreateSpan(r.Context(), "health.liveness",
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.url", r.URL.String()),
+			),
+		)
+		defer span.End()
+
+		ok, checks := health.CheckLiveness(ctx)
+		statusCode, liveness := http.StatusOK, "alive"
+		if !ok {
+			statusCode, liveness = http.StatusServiceUnavailable, "dead"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+
+		response := map[string]interface{}{
+			"status":    liveness,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"service":   config.OpenTelemetry.ServiceName,
+			"checks":    checks,
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+		span.SetAttributes(
+			attribute.Int("http.status_code", statusCode),
+			attribute.String("health.status", liveness),
+		)
+
+		tm.LogWithTraceContext(ctx, zap.InfoLevel, "Liveness check completed")
+	})
+
 	// Metrics endpoint with tracing
 	mux.HandleFunc(config.Health.MetricsEndpoint, func(w http.ResponseWriter, r *http.Request) {
 		ctx, span := tm.CreateSpan(r.Context(), "health.metrics",
@@ -199,25 +386,14 @@ func startHealthServerWithTracing(config *Config, logger *zap.Logger, tm *Teleme
 		)
 		defer span.End()
 
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
-
-		metrics := fmt.Sprintf(`# Telemorph-Prime Ingestion Service Metrics
-# Service: %s
-# Version: %s
-# Environment: %s
-# Timestamp: %s
-`, config.OpenTelemetry.ServiceName, config.OpenTelemetry.ServiceVersion,
-			config.OpenTelemetry.Environment, time.Now().UTC().Format(time.RFC3339))
-
-		fmt.Fprint(w, metrics)
+		tm.MetricsHandler().ServeHTTP(w, r.WithContext(ctx))
 
 		span.SetAttributes(
 			attribute.Int("http.status_code", http.StatusOK),
 			attribute.String("metrics.type", "prometheus"),
 		)
 
-		tm.LogWithTraceContext(ctx, zap.InfoLevel, "Metrics endpoint accessed")
+		tm.LogWithTraceContext(ctx, zap.DebugLevel, "Metrics endpoint accessed")
 	})
 
 	// Wrap mux with OpenTelemetry HTTP instrumentation
@@ -234,189 +410,57 @@ func startHealthServerWithTracing(config *Config, logger *zap.Logger, tm *Teleme
 		WriteTimeout: config.Server.WriteTimeout,
 	}
 
-	logger.Info("Health server starting", zap.String("endpoint", config.Server.HealthEndpoint))
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Error("Health server failed", zap.Error(err))
-	}
+	logger.Info("Health server starting", zap.String("endpoint", config.Server.HealthEndpoint), zap.Bool("tls", serverTLS != nil))
+	go func() {
+		if err := serveHTTP(server, serverTLS); err != nil && err != http.ErrServerClosed {
+			logger.Error("Health server failed", zap.Error(err))
+		}
+	}()
+	return server
 }
 
-// startHTTPOTLPServerWithTracing starts a simple HTTP server for OTLP data with tracing
-func startHTTPOTLPServerWithTracing(config *Config, kafkaProducer *KafkaProducer, logger *zap.Logger, tm *TelemetryManager) {
+// startAdminServer starts the admin HTTP server. Its endpoints authenticate requests
+// like the OTLP receivers and only expose the caller's tenant.
+func startAdminServer(config *Config, auth *authenticator, dlqInspector *deadLetterInspector, serverTLS *tlsReloader, logger *zap.Logger) *http.Server {
 	mux := http.NewServeMux()
+	if config.Kafka.Topics.DeadLetter.Enabled {
+		mux.Handle(config.Admin.DeadLetterEndpoint, dlqInspector)
+	}
 
-	// OTLP traces endpoint with tracing
-	mux.HandleFunc("/v1/traces", func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := tm.CreateSpan(r.Context(), "otlp.traces.receive",
-			trace.WithAttributes(
-				attribute.String("http.method", r.Method),
-				attribute.String("http.url", r.URL.String()),
-				attribute.Int("http.request.content_length", int(r.ContentLength)),
-				attribute.String("otlp.signal", "traces"),
-			),
-		)
-		defer span.End()
-
-		if r.Method != http.MethodPost {
-			span.SetStatus(codes.Error, "Method not allowed")
-			span.SetAttributes(attribute.Int("http.status_code", http.StatusMethodNotAllowed))
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var tracesData map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&tracesData); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "Invalid JSON")
-			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-
-		// Process traces data with tracing
-		ctx, processSpan := tm.CreateSpan(ctx, "otlp.traces.process")
-		tm.LogWithTraceContext(ctx, zap.InfoLevel, "Received traces data",
-			zap.Any("data", tracesData),
-			zap.String("signal_type", "traces"),
-		)
-
-		// Send traces data to Kafka
-		if err := kafkaProducer.SendMessageWithTracing(ctx, config.Kafka.Topics.Traces, "traces", tracesData, map[string]string{
-			"signal_type":  "traces",
-			"content_type": "application/json",
-		}); err != nil {
-			processSpan.RecordError(err)
-			processSpan.SetStatus(codes.Error, "Failed to send traces to Kafka")
-			tm.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to send traces to Kafka", zap.Error(err))
-		} else {
-			processSpan.SetStatus(codes.Ok, "Traces sent to Kafka successfully")
-		}
-		processSpan.End()
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
-
-		span.SetAttributes(
-			attribute.Int("http.status_code", http.StatusOK),
-			attribute.String("otlp.signal", "traces"),
-		)
-	})
-
-	// OTLP metrics endpoint with tracing
-	mux.HandleFunc("/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := tm.CreateSpan(r.Context(), "otlp.metrics.receive",
-			trace.WithAttributes(
-				attribute.String("http.method", r.Method),
-				attribute.String("http.url", r.URL.String()),
-				attribute.Int("http.request.content_length", int(r.ContentLength)),
-				attribute.String("otlp.signal", "metrics"),
-			),
-		)
-		defer span.End()
-
-		if r.Method != http.MethodPost {
-			span.SetStatus(codes.Error, "Method not allowed")
-			span.SetAttributes(attribute.Int("http.status_code", http.StatusMethodNotAllowed))
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var metricsData map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&metricsData); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "Invalid JSON")
-			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-
-		// Process metrics data with tracing
-		ctx, processSpan := tm.CreateSpan(ctx, "otlp.metrics.process")
-		tm.LogWithTraceContext(ctx, zap.InfoLevel, "Received metrics data",
-			zap.Any("data", metricsData),
-			zap.String("signal_type", "metrics"),
-		)
-
-		// Send metrics data to Kafka
-		if err := kafkaProducer.SendMessageWithTracing(ctx, config.Kafka.Topics.Metrics, "metrics", metricsData, map[string]string{
-			"signal_type":  "metrics",
-			"content_type": "application/json",
-		}); err != nil {
-			processSpan.RecordError(err)
-			processSpan.SetStatus(codes.Error, "Failed to send metrics to Kafka")
-			tm.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to send metrics to Kafka", zap.Error(err))
-		} else {
-			processSpan.SetStatus(codes.Ok, "Metrics sent to Kafka successfully")
-		}
-		processSpan.End()
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
-
-		span.SetAttributes(
-			attribute.Int("http.status_code", http.StatusOK),
-			attribute.String("otlp.signal", "metrics"),
-		)
-	})
-
-	// OTLP logs endpoint with tracing
-	mux.HandleFunc("/v1/logs", func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := tm.CreateSpan(r.Context(), "otlp.logs.receive",
-			trace.WithAttributes(
-				attribute.String("http.method", r.Method),
-				attribute.String("http.url", r.URL.String()),
-				attribute.Int("http.request.content_length", int(r.ContentLength)),
-				attribute.String("otlp.signal", "logs"),
-			),
-		)
-		defer span.End()
-
-		if r.Method != http.MethodPost {
-			span.SetStatus(codes.Error, "Method not allowed")
-			span.SetAttributes(attribute.Int("http.status_code", http.StatusMethodNotAllowed))
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var logsData map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&logsData); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "Invalid JSON")
-			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
+	handler := otelhttp.NewHandler(auth.middleware(mux), "admin-server",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		}),
+	)
 
-		// Process logs data with tracing
-		ctx, processSpan := tm.CreateSpan(ctx, "otlp.logs.process")
-		tm.LogWithTraceContext(ctx, zap.InfoLevel, "Received logs data",
-			zap.Any("data", logsData),
-			zap.String("signal_type", "logs"),
-		)
+	server := &http.Server{
+		Addr:         config.Server.AdminEndpoint,
+		Handler:      handler,
+		ReadTimeout:  config.Server.ReadTimeout,
+		WriteTimeout: config.Server.WriteTimeout,
+	}
 
-		// Send logs data to Kafka
-		if err := kafkaProducer.SendMessageWithTracing(ctx, config.Kafka.Topics.Logs, "logs", logsData, map[string]string{
-			"signal_type":  "logs",
-			"content_type": "application/json",
-		}); err != nil {
-			processSpan.RecordError(err)
-			processSpan.SetStatus(codes.Error, "Failed to send logs to Kafka")
-			tm.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to send logs to Kafka", zap.Error(err))
-		} else {
-			processSpan.SetStatus(codes.Ok, "Logs sent to Kafka successfully")
+	logger.Info("Admin server starting", zap.String("endpoint", config.Server.AdminEndpoint), zap.Bool("tls", serverTLS != nil))
+	go func() {
+		if err := serveHTTP(server, serverTLS); err != nil && err != http.ErrServerClosed {
+			logger.Error("Admin server failed", zap.Error(err))
 		}
-		processSpan.End()
+	}()
+	return server
+}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+// startHTTPOTLPServerWithTracing starts a simple HTTP server for OTLP data with tracing
+func startHTTPOTLPServerWithTracing(config *Config, kafkaProducer *KafkaProducer, auth *authenticator, tenants *tenantRegistry, limiter *rateLimiter, validator *payloadValidator, redactor *payloadRedactor, admission *admissionController, health *healthRegistry, serverTLS *tlsReloader, logger *zap.Logger, tm *TelemetryManager) *http.Server {
+	mux := http.NewServeMux()
 
-		span.SetAttributes(
-			attribute.Int("http.status_code", http.StatusOK),
-			attribute.String("otlp.signal", "logs"),
-		)
-	})
+	// OTLP traces, metrics and logs endpoints with tracing
+	for _, signal := range newOTLPSignals(config).all() {
+		mux.HandleFunc(signal.path, handleOTLPHTTP(config, signal, kafkaProducer, tenants, limiter, validator, redactor, tm))
+	}
 
-	// Wrap mux with OpenTelemetry HTTP instrumentation
-	handler := otelhttp.NewHandler(mux, "otlp-server",
+	// Wrap mux with authentication, tenant resolution, admission control and OpenTelemetry
+	// HTTP instrumentation
+	handler := otelhttp.NewHandler(auth.middleware(tenants.middleware(admission.middleware(mux))), "otlp-server",
 		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 			return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
 		}),
@@ -429,18 +473,43 @@ func startHTTPOTLPServerWithTracing(config *Config, kafkaProducer *KafkaProducer
 		WriteTimeout: config.Server.WriteTimeout,
 	}
 
-	logger.Info("HTTP OTLP server starting", zap.String("endpoint", config.Server.HTTPEndpoint))
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Error("HTTP OTLP server failed", zap.Error(err))
+	logger.Info("HTTP OTLP server starting", zap.String("endpoint", config.Server.HTTPEndpoint), zap.Bool("tls", serverTLS != nil))
+	go func() {
+		if err := serveHTTP(server, serverTLS); err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTP OTLP server failed", zap.Error(err))
+			health.ReportFatal(fmt.Errorf("HTTP OTLP server failed: %w", err))
+		}
+	}()
+	return server
+}
+
+// serveHTTP serves server over TLS when serverTLS is set, and stops watching its
+// certificates once the server closes
+func serveHTTP(server *http.Server, serverTLS *tlsReloader) error {
+	if serverTLS == nil {
+		return server.ListenAndServe()
 	}
+	defer serverTLS.Close()
+	server.TLSConfig = serverTLS.TLSConfig()
+	return server.ListenAndServeTLS("", "")
 }
 
 // KafkaProducer handles Kafka message production with tracing
 type KafkaProducer struct {
-	producer         sarama.SyncProducer
+	producer         sarama.AsyncProducer
 	logger           *zap.Logger
 	telemetryManager *TelemetryManager
 	config           *Config
+	inflight         chan struct{}
+	drainers         sync.WaitGroup
+	spool            *spool.Spool
+	stop             chan struct{}
+	replayer         sync.WaitGroup
+	mu               sync.RWMutex
+	closed           bool
+	closeOnce        sync.Once
+	closeErr         error
+	lastProgress     atomic.Int64
 }
 
 // NewKafkaProducerWithTracing creates a new Kafka producer with tracing
@@ -486,7 +555,7 @@ func NewKafkaProducerWithTracing(config *Config, logger *zap.Logger, tm *Telemet
 	saramaConfig.Producer.Return.Successes = true
 	saramaConfig.Producer.Return.Errors = true
 
-	producer, err := sarama.NewSyncProducer(config.Kafka.Brokers, saramaConfig)
+	producer, err := sarama.NewAsyncProducer(config.Kafka.Brokers, saramaConfig)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "Failed to create Kafka producer")
@@ -501,113 +570,249 @@ func NewKafkaProducerWithTracing(config *Config, logger *zap.Logger, tm *Telemet
 		logger:           logger,
 		telemetryManager: tm,
 		config:           config,
+		inflight:         make(chan struct{}, config.Kafka.Producer.QueueSize),
+		stop:             make(chan struct{}),
+	}
+
+	if config.Kafka.Spool.Enabled {
+		if err := kp.openSpool(); err != nil {
+			producer.Close()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to open Kafka spool")
+			return nil, err
+		}
+		kp.replayer.Add(1)
+		go kp.replaySpool()
 	}
 
+	kp.drainers.Add(2)
+	go kp.drainSuccesses()
+	go kp.drainErrors()
+
 	span.SetAttributes(
 		attribute.String("kafka.brokers", fmt.Sprintf("%v", config.Kafka.Brokers)),
 		attribute.String("kafka.compression", config.Kafka.Producer.Compression),
 		attribute.Int("kafka.retry_max", config.Kafka.Producer.RetryMax),
+		attribute.String("kafka.durability", config.Kafka.Producer.Durability),
+		attribute.Int("kafka.queue_size", config.Kafka.Producer.QueueSize),
 	)
 
 	tm.LogWithTraceContext(ctx, zap.InfoLevel, "Kafka producer initialized successfully",
 		zap.Strings("brokers", config.Kafka.Brokers),
 		zap.String("compression", config.Kafka.Producer.Compression),
+		zap.String("durability", config.Kafka.Producer.Durability),
 	)
 
 	return kp, nil
 }
 
-// Close closes the Kafka producer
+// Close flushes in-flight messages and closes the Kafka producer. Only the first call
+// closes it; later calls return the same result.
 func (kp *KafkaProducer) Close() error {
+	kp.closeOnce.Do(func() {
+		kp.closeErr = kp.close()
+	})
+	return kp.closeErr
+}
+
+// close stops the spool replayer and pending enqueues, then flushes and closes the producer
+func (kp *KafkaProducer) close() error {
 	ctx, span := kp.telemetryManager.CreateSpan(context.Background(), "kafka.producer.close")
 	defer span.End()
 
-	err := kp.producer.Close()
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "Failed to close Kafka producer")
-	} else {
-		span.SetStatus(codes.Ok, "Kafka producer closed successfully")
+	close(kp.stop)
+	kp.replayer.Wait()
+
+	kp.mu.Lock()
+	kp.closed = true
+	kp.mu.Unlock()
+
+	// AsyncClose flushes buffered messages and then closes the success and error
+	// channels, which ends the drain goroutines
+	kp.producer.AsyncClose()
+	kp.drainers.Wait()
+
+	// Close the spool last so that messages failing during the flush are still spooled
+	if kp.spool != nil {
+		if err := kp.spool.Close(); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to close Kafka spool")
+			return err
+		}
 	}
+	span.SetStatus(codes.Ok, "Kafka producer closed successfully")
 
 	kp.telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Kafka producer closed")
-	return err
+	return nil
 }
 
-// SendMessageWithTracing sends a message to Kafka with tracing and context propagation
-func (kp *KafkaProducer) SendMessageWithTracing(ctx context.Context, topic, key string, value interface{}, headers map[string]string) error {
+// Shutdown closes the producer, giving queued and in-flight messages until ctx is done to
+// reach Kafka or the spool. It never waits past ctx: when the deadline is missed, Close
+// keeps flushing in the background and the messages it has not delivered or spooled by
+// the time the process exits are lost, which is reported as an error.
+func (kp *KafkaProducer) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- kp.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		pending := len(kp.inflight)
+		kp.logger.Warn("Kafka producer did not drain before the shutdown deadline",
+			zap.Int("in_flight", pending),
+		)
+		return fmt.Errorf("failed to drain %d in-flight messages: %w", pending, ctx.Err())
+	}
+}
+
+// SendRecordsWithTracing sends a batch of records to a Kafka topic through the async producer,
+// returning according to the configured durability mode. Records Kafka will never accept are
+// sent to the dead-letter topic and returned as sarama.ProducerErrors, as are delivery failures
+// with broker_ack.
+func (kp *KafkaProducer) SendRecordsWithTracing(ctx context.Context, topic string, records []kafkaRecord, headers map[string]string) error {
 	spanName := fmt.Sprintf("kafka.produce %s", topic)
 	ctx, span := kp.telemetryManager.CreateSpan(ctx, spanName,
 		trace.WithAttributes(
 			attribute.String("messaging.system", "kafka"),
 			attribute.String("messaging.destination.name", topic),
 			attribute.String("messaging.operation", "publish"),
+			attribute.Int("messaging.batch.message_count", len(records)),
 			attribute.String("kafka.topic", topic),
-			attribute.String("kafka.key", key),
+			attribute.String("kafka.durability", kp.config.Kafka.Producer.Durability),
 		),
 	)
 	defer span.End()
+	defer kp.telemetryManager.metrics.recordProduce(ctx, topic, kp.config.Kafka.Producer.Durability, time.Now())
+
+	messages := make([]*sarama.ProducerMessage, 0, len(records))
+	var unmarshalable sarama.ProducerErrors
+	size := 0
+	for _, record := range records {
+		message := &sarama.ProducerMessage{Topic: topic}
+		// Records without a key are spread across partitions by the partitioner
+		if record.key != "" {
+			message.Key = sarama.StringEncoder(record.key)
+		}
+		for _, h := range []map[string]string{headers, record.headers} {
+			for k, v := range h {
+				message.Headers = append(message.Headers, sarama.RecordHeader{
+					Key:   []byte(k),
+					Value: []byte(v),
+				})
+			}
+		}
+		// Propagate the kafka.produce span context so consumers can link back to this request
+		kafkatrace.Inject(ctx, message)
 
-	// Serialize value to JSON
-	valueBytes, err := json.Marshal(value)
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "Failed to marshal value to JSON")
-		kp.telemetryManager.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to marshal value to JSON",
-			zap.Error(err),
-			zap.String("topic", topic),
-			zap.String("key", key),
-		)
-		return err
+		valueBytes, err := json.Marshal(record.value)
+		if err != nil {
+			span.RecordError(err)
+			kp.telemetryManager.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to marshal value to JSON",
+				zap.Error(err),
+				zap.String("topic", topic),
+				zap.String("key", record.key),
+			)
+			// Keep a readable rendering of the value for the dead-letter topic
+			message.Value = sarama.StringEncoder(fmt.Sprintf("%+v", record.value))
+			unmarshalable = append(unmarshalable, &sarama.ProducerError{Msg: message, Err: err})
+			continue
+		}
+		size += len(valueBytes)
+		message.Value = sarama.ByteEncoder(valueBytes)
+		messages = append(messages, message)
 	}
 
-	// Create Kafka message
-	message := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(key),
-		Value: sarama.ByteEncoder(valueBytes),
-	}
+	kp.telemetryManager.metrics.recordProduceErrors(ctx, topic, "marshal", len(unmarshalable))
 
-	// Add headers
-	for k, v := range headers {
-		message.Headers = append(message.Headers, sarama.RecordHeader{
-			Key:   []byte(k),
-			Value: []byte(v),
-		})
+	var batch *produceBatch
+	if kp.config.Kafka.Producer.Durability == durabilityBrokerAck {
+		batch = newProduceBatch(len(messages))
+		for _, message := range messages {
+			message.Metadata = batch
+		}
 	}
 
-	// Inject trace context into Kafka headers (simplified for now)
-	// TODO: Add proper Kafka context propagation
-
-	// Send message
-	partition, offset, err := kp.producer.SendMessage(message)
+	err := kp.produce(ctx, messages, batch)
+	var producerErrs sarama.ProducerErrors
+	if err == nil || errors.As(err, &producerErrs) {
+		producerErrs = append(unmarshalable, producerErrs...)
+		for _, producerErr := range producerErrs {
+			if isPermanentKafkaError(producerErr.Err) {
+				kp.deadLetter(ctx, producerErr.Msg, deadLetterReason(producerErr.Err), producerErr.Err)
+			}
+		}
+		if len(producerErrs) > 0 {
+			err = producerErrs
+		}
+	}
 	if err != nil {
 		span.RecordError(err)
-		span.SetStatus(codes.Error, "Failed to send message to Kafka")
-		kp.telemetryManager.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to send message to Kafka",
+		span.SetStatus(codes.Error, "Failed to send messages to Kafka")
+		kp.telemetryManager.LogWithTraceContext(ctx, zap.ErrorLevel, "Failed to send messages to Kafka",
 			zap.Error(err),
 			zap.String("topic", topic),
-			zap.String("key", key),
+			zap.Int("messages", len(records)),
 		)
 		return err
 	}
 
-	// Add success attributes
 	span.SetAttributes(
-		attribute.Int("kafka.partition", int(partition)),
-		attribute.Int64("kafka.offset", offset),
 		attribute.String("kafka.status", "success"),
-		attribute.Int("message.size", len(valueBytes)),
+		attribute.Int("message.size", size),
 	)
+	span.SetStatus(codes.Ok, "Messages sent successfully")
 
-	span.SetStatus(codes.Ok, "Message sent successfully")
-
-	kp.telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Message sent to Kafka successfully",
+	kp.telemetryManager.LogWithTraceContext(ctx, zap.InfoLevel, "Messages sent to Kafka successfully",
 		zap.String("topic", topic),
-		zap.String("key", key),
-		zap.Int32("partition", partition),
-		zap.Int64("offset", offset),
+		zap.Int("messages", len(messages)),
 	)
 
 	return nil
 }
+
+// produce enqueues messages and waits for them as required by the durability mode.
+// When the spool is enabled, messages that cannot be queued or delivered are written
+// to disk instead of failing the request.
+func (kp *KafkaProducer) produce(ctx context.Context, messages []*sarama.ProducerMessage, batch *produceBatch) error {
+	durability := kp.config.Kafka.Producer.Durability
+	for i, message := range messages {
+		err := kp.enqueue(ctx, message, durability != durabilityFireAndForget)
+		if err == nil {
+			continue
+		}
+
+		unsent := messages[i:]
+		kp.telemetryManager.metrics.recordProduceErrors(ctx, message.Topic, "enqueue", len(unsent))
+		if batch != nil {
+			// Fail the messages that were never enqueued and wait for the rest
+			for _, message := range unsent {
+				batch.complete(&sarama.ProducerError{Msg: message, Err: err})
+			}
+			return kp.spoolRetryableErrors(batch.wait(ctx))
+		}
+		if kp.spool != nil && kp.spoolMessages(unsent) == nil {
+			return nil
+		}
+		if durability == durabilityFireAndForget {
+			kp.logger.Warn("Dropping messages, Kafka producer queue is full",
+				zap.String("topic", message.Topic),
+				zap.Int("dropped", len(unsent)),
+			)
+			return nil
+		}
+		return err
+	}
+
+	if batch != nil {
+		return kp.spoolRetryableErrors(batch.wait(ctx))
+	}
+	return nil
+}
+
+// SendMessageWithTracing sends a message to Kafka with tracing and context propagation
+func (kp *KafkaProducer) SendMessageWithTracing(ctx context.Context, topic, key string, value interface{}, headers map[string]string) error {
+	return kp.SendRecordsWithTracing(ctx, topic, []kafkaRecord{{key: key, value: value}}, headers)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Reasons reported on the ingestion.items.rejected metric
+const (
+	rejectReasonKafkaRejected    = "kafka_rejected"
+	rejectReasonKafkaUnavailable = "kafka_unavailable"
+	rejectReasonRateLimited      = "rate_limited"
+	rejectReasonInvalid          = "invalid"
+)
+
+// ingestionMetrics holds the instruments describing the data flowing through the service
+type ingestionMetrics struct {
+	itemsReceived   metric.Int64Counter
+	itemsRejected   metric.Int64Counter
+	requestBytes    metric.Int64Counter
+	requestDuration metric.Float64Histogram
+	produceDuration metric.Float64Histogram
+	produceErrors   metric.Int64Counter
+}
+
+// newIngestionMetrics creates the ingestion instruments on meter
+func newIngestionMetrics(meter metric.Meter) (*ingestionMetrics, error) {
+	m := &ingestionMetrics{}
+	var err error
+
+	if m.itemsReceived, err = meter.Int64Counter("ingestion.items.received",
+		metric.WithDescription("Spans, metric data points and log records received, by signal")); err != nil {
+		return nil, fmt.Errorf("failed to create items received counter: %w", err)
+	}
+	if m.itemsRejected, err = meter.Int64Counter("ingestion.items.rejected",
+		metric.WithDescription("Received items that were not written to Kafka, by signal and reason")); err != nil {
+		return nil, fmt.Errorf("failed to create items rejected counter: %w", err)
+	}
+	if m.requestBytes, err = meter.Int64Counter("ingestion.request.bytes",
+		metric.WithDescription("Uncompressed size of OTLP export requests"),
+		metric.WithUnit("By")); err != nil {
+		return nil, fmt.Errorf("failed to create request bytes counter: %w", err)
+	}
+	if m.requestDuration, err = meter.Float64Histogram("ingestion.request.duration",
+		metric.WithDescription("Time to handle an OTLP export request"),
+		metric.WithUnit("s")); err != nil {
+		return nil, fmt.Errorf("failed to create request duration histogram: %w", err)
+	}
+	if m.produceDuration, err = meter.Float64Histogram("ingestion.kafka.produce.duration",
+		metric.WithDescription("Time to hand a request's messages to Kafka under the configured durability mode"),
+		metric.WithUnit("s")); err != nil {
+		return nil, fmt.Errorf("failed to create produce duration histogram: %w", err)
+	}
+	if m.produceErrors, err = meter.Int64Counter("ingestion.kafka.produce.errors",
+		metric.WithDescription("Kafka messages that failed to be produced, by topic and error type")); err != nil {
+		return nil, fmt.Errorf("failed to create produce errors counter: %w", err)
+	}
+	return m, nil
+}
+
+// recordRequest records the size and duration of an export request
+func (m *ingestionMetrics) recordRequest(ctx context.Context, signal, protocol string, size int, start time.Time, status string) {
+	attrs := metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("protocol", protocol),
+	)
+	m.requestBytes.Add(ctx, int64(size), attrs)
+	m.requestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("protocol", protocol),
+		attribute.String("status", status),
+	))
+}
+
+// recordReceived records the items of a decoded export request
+func (m *ingestionMetrics) recordReceived(ctx context.Context, signal, protocol string, items int64) {
+	m.itemsReceived.Add(ctx, items, metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("protocol", protocol),
+	))
+}
+
+// recordRejected records items that were not written to Kafka
+func (m *ingestionMetrics) recordRejected(ctx context.Context, signal, reason string, items int64) {
+	if items == 0 {
+		return
+	}
+	m.itemsRejected.Add(ctx, items, metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("reason", reason),
+	))
+}
+
+// recordProduce records the duration of a Kafka send. Sends of self-telemetry are not
+// recorded, so that exporting metrics does not create more metrics to export.
+func (m *ingestionMetrics) recordProduce(ctx context.Context, topic, durability string, start time.Time) {
+	if telemetrySuppressed(ctx) {
+		return
+	}
+	m.produceDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("topic", topic),
+		attribute.String("durability", durability),
+	))
+}
+
+// recordProduceErrors records Kafka messages that failed to be produced
+func (m *ingestionMetrics) recordProduceErrors(ctx context.Context, topic, errorType string, count int) {
+	if count == 0 || telemetrySuppressed(ctx) {
+		return
+	}
+	m.produceErrors.Add(ctx, int64(count), metric.WithAttributes(
+		attribute.String("topic", topic),
+		attribute.String("error.type", errorType),
+	))
+}
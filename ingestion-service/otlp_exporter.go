@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"google.golang.org/grpc/credentials"
+)
+
+// Compression settings for OTLP exporters
+const (
+	otlpCompressionNone = "none"
+	otlpCompressionGzip = "gzip"
+)
+
+// otlpEndpoint returns the host:port the OTLP clients expect, accepting endpoints written
+// as URLs such as http://collector:4317
+func otlpEndpoint(endpoint string) string {
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		endpoint = endpoint[i+3:]
+	}
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	return endpoint
+}
+
+// newOTLPTLSConfig builds the client TLS configuration for an OTLP exporter
+func newOTLPTLSConfig(config OTLPTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("failed to parse CA file: no PEM certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// otlpRetry has the layout of the exporters' RetryConfig types, so it converts to each of them
+type otlpRetry struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// newOTLPRetry returns the exporter retry policy for config; retrying is on unless disabled
+func newOTLPRetry(config OTLPRetryConfig) otlpRetry {
+	return otlpRetry{
+		Enabled:         config.Enabled == nil || *config.Enabled,
+		InitialInterval: config.InitialInterval,
+		MaxInterval:     config.MaxInterval,
+		MaxElapsedTime:  config.MaxElapsedTime,
+	}
+}
+
+// otlpTraceGRPCOptions returns the gRPC trace client options for config
+func otlpTraceGRPCOptions(config OTLPConfig) ([]otlptracegrpc.Option, error) {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(otlpEndpoint(config.Endpoint)),
+		otlptracegrpc.WithTimeout(config.Timeout),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(newOTLPRetry(config.Retry))),
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		tlsConfig, err := newOTLPTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(config.Headers))
+	}
+	if config.Compression == otlpCompressionGzip {
+		opts = append(opts, otlptracegrpc.WithCompressor(otlpCompressionGzip))
+	}
+	return opts, nil
+}
+
+// otlpTraceHTTPOptions returns the HTTP trace client options for config
+func otlpTraceHTTPOptions(config OTLPConfig) ([]otlptracehttp.Option, error) {
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(otlpEndpoint(config.Endpoint)),
+		otlptracehttp.WithTimeout(config.Timeout),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig(newOTLPRetry(config.Retry))),
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else {
+		tlsConfig, err := newOTLPTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+	}
+	if config.Compression == otlpCompressionGzip {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	return opts, nil
+}
+
+// otlpMetricGRPCOptions returns the gRPC metric exporter options for config
+func otlpMetricGRPCOptions(config OTLPConfig) ([]otlpmetricgrpc.Option, error) {
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(otlpEndpoint(config.Endpoint)),
+		otlpmetricgrpc.WithTimeout(config.Timeout),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(newOTLPRetry(config.Retry))),
+	}
+	if config.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else {
+		tlsConfig, err := newOTLPTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(config.Headers))
+	}
+	if config.Compression == otlpCompressionGzip {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(otlpCompressionGzip))
+	}
+	return opts, nil
+}
+
+// otlpMetricHTTPOptions returns the HTTP metric exporter options for config
+func otlpMetricHTTPOptions(config OTLPConfig) ([]otlpmetrichttp.Option, error) {
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(otlpEndpoint(config.Endpoint)),
+		otlpmetrichttp.WithTimeout(config.Timeout),
+		otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(newOTLPRetry(config.Retry))),
+	}
+	if config.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else {
+		tlsConfig, err := newOTLPTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(config.Headers))
+	}
+	if config.Compression == otlpCompressionGzip {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	return opts, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// OTLP/HTTP content types
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// zstdMaxWindowSize bounds the memory a single zstd stream may allocate for its window
+const zstdMaxWindowSize = 8 << 20
+
+// errRequestTooLarge is returned when a request body exceeds the configured size limits
+var errRequestTooLarge = errors.New("request body too large")
+
+// negotiateOTLPContentType returns the OTLP encoding for a request Content-Type header.
+// A missing header is treated as JSON for compatibility with existing clients.
+func negotiateOTLPContentType(header string) (string, bool) {
+	if header == "" {
+		return contentTypeJSON, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case contentTypeJSON, contentTypeProtobuf:
+		return mediaType, true
+	default:
+		return "", false
+	}
+}
+
+// newDecompressingReader wraps body with a decoder for the request's Content-Encoding
+func newDecompressingReader(contentEncoding string, body io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		return reader, nil
+	case "deflate":
+		reader, err := zlib.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deflate body: %w", err)
+		}
+		return reader, nil
+	case "zstd":
+		decoder, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(zstdMaxWindowSize),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd body: %w", err)
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+	}
+}
+
+// readOTLPHTTPBody reads and decompresses a request body, enforcing the wire and decompressed size limits
+func readOTLPHTTPBody(w http.ResponseWriter, r *http.Request, config ServerConfig) ([]byte, error) {
+	body := http.MaxBytesReader(w, r.Body, config.MaxRequestBodySize)
+
+	reader, err := newDecompressingReader(r.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("%w: exceeds %d bytes on the wire", errRequestTooLarge, config.MaxRequestBodySize)
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Read one byte past the limit so oversized payloads are detected rather than truncated
+	data, err := io.ReadAll(io.LimitReader(reader, config.MaxDecompressedBodySize+1))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("%w: exceeds %d bytes on the wire", errRequestTooLarge, config.MaxRequestBodySize)
+		}
+		if errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, fmt.Errorf("%w: zstd window exceeds %d bytes", errRequestTooLarge, zstdMaxWindowSize)
+		}
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(data)) > config.MaxDecompressedBodySize {
+		return nil, fmt.Errorf("%w: exceeds %d bytes decompressed", errRequestTooLarge, config.MaxDecompressedBodySize)
+	}
+	return data, nil
+}
+
+// decodeOTLPHTTPBody decodes an OTLP/HTTP request body into the OTLP model
+func decodeOTLPHTTPBody(contentType string, body []byte, signal otlpSignal) (otlp.Payload, error) {
+	encoding := otlp.EncodingJSON
+	if contentType == contentTypeProtobuf {
+		encoding = otlp.EncodingProtobuf
+	}
+	return otlp.Unmarshal(otlp.Signal(signal.name), encoding, body)
+}
+
+// writeOTLPHTTPResponse writes an OTLP message using the request's encoding
+func writeOTLPHTTPResponse(w http.ResponseWriter, contentType string, statusCode int, msg proto.Message) {
+	var body []byte
+	var err error
+	if contentType == contentTypeProtobuf {
+		body, err = proto.Marshal(msg)
+	} else {
+		body, err = protojson.Marshal(msg)
+	}
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// writeOTLPHTTPError writes a google.rpc.Status error body using the request's encoding
+func writeOTLPHTTPError(w http.ResponseWriter, contentType string, statusCode int, code grpccodes.Code, message string) {
+	writeOTLPHTTPResponse(w, contentType, statusCode, status.New(code, message).Proto())
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounding up
+func setRetryAfter(w http.ResponseWriter, delay time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+}
+
+// handleOTLPHTTP returns the OTLP/HTTP handler for a signal
+func handleOTLPHTTP(config *Config, signal otlpSignal, kafkaProducer *KafkaProducer, tenants *tenantRegistry, limiter *rateLimiter, validator *payloadValidator, redactor *payloadRedactor, tm *TelemetryManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tm.CreateSpan(r.Context(), fmt.Sprintf("otlp.%s.receive", signal.name),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.url", r.URL.String()),
+				attribute.Int("http.request.content_length", int(r.ContentLength)),
+				attribute.String("otlp.signal", signal.name),
+			),
+		)
+		defer span.End()
+
+		start := time.Now()
+		statusCode, size := http.StatusOK, 0
+		defer func() {
+			tm.metrics.recordRequest(ctx, signal.name, "http", size, start, strconv.Itoa(statusCode))
+		}()
+
+		if r.Method != http.MethodPost {
+			statusCode = http.StatusMethodNotAllowed
+			span.SetStatus(codes.Error, "Method not allowed")
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusMethodNotAllowed))
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		contentType, ok := negotiateOTLPContentType(r.Header.Get("Content-Type"))
+		if !ok {
+			statusCode = http.StatusUnsupportedMediaType
+			span.SetStatus(codes.Error, "Unsupported content type")
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusUnsupportedMediaType))
+			http.Error(w, fmt.Sprintf("Unsupported content type, expected %s or %s", contentTypeJSON, contentTypeProtobuf), http.StatusUnsupportedMediaType)
+			return
+		}
+		span.SetAttributes(attribute.String("otlp.encoding", contentType))
+
+		body, err := readOTLPHTTPBody(w, r, config.Server)
+		if err != nil {
+			statusCode = http.StatusBadRequest
+			if errors.Is(err, errRequestTooLarge) {
+				statusCode = http.StatusRequestEntityTooLarge
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to read request body")
+			span.SetAttributes(attribute.Int("http.status_code", statusCode))
+			writeOTLPHTTPError(w, contentType, statusCode, grpccodes.InvalidArgument, err.Error())
+			return
+		}
+		span.SetAttributes(
+			attribute.String("http.request.content_encoding", r.Header.Get("Content-Encoding")),
+			attribute.Int("otlp.request.decompressed_size", len(body)),
+		)
+		size = len(body)
+
+		payload, err := decodeOTLPHTTPBody(contentType, body, signal)
+		if err != nil {
+			kafkaProducer.deadLetterUndecodable(ctx, signal, body, contentType, err)
+			statusCode = http.StatusBadRequest
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Invalid OTLP payload")
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+			writeOTLPHTTPError(w, contentType, http.StatusBadRequest, grpccodes.InvalidArgument, err.Error())
+			return
+		}
+
+		result, err := processOTLPData(ctx, tm, kafkaProducer, tenants, limiter, validator, redactor, signal, "http", payload, len(body))
+		var invalid *validationError
+		if errors.As(err, &invalid) {
+			kafkaProducer.deadLetterInvalid(ctx, signal, payload, err)
+			statusCode = http.StatusBadRequest
+			span.SetStatus(codes.Error, "Invalid OTLP payload")
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+			writeOTLPHTTPError(w, contentType, http.StatusBadRequest, grpccodes.InvalidArgument, err.Error())
+			return
+		}
+		var limitErr *rateLimitError
+		if errors.As(err, &limitErr) {
+			statusCode = http.StatusTooManyRequests
+			span.SetStatus(codes.Error, "Rate limit exceeded")
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusTooManyRequests))
+			setRetryAfter(w, limitErr.retryAfter)
+			writeOTLPHTTPError(w, contentType, http.StatusTooManyRequests, grpccodes.ResourceExhausted, limitErr.Error())
+			return
+		}
+		if err != nil {
+			// 503 with Retry-After tells OTLP exporters to retry the whole batch later
+			statusCode = http.StatusServiceUnavailable
+			span.SetStatus(codes.Error, fmt.Sprintf("Failed to send %s to Kafka", signal.name))
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusServiceUnavailable))
+			setRetryAfter(w, config.Server.RetryAfter)
+			writeOTLPHTTPError(w, contentType, http.StatusServiceUnavailable, grpccodes.Unavailable,
+				fmt.Sprintf("failed to send %s to Kafka: %v", signal.name, err))
+			return
+		}
+
+		writeOTLPHTTPResponse(w, contentType, http.StatusOK, signal.newResponse(result))
+
+		span.SetAttributes(
+			attribute.Int("http.status_code", http.StatusOK),
+			attribute.Int64(fmt.Sprintf("otlp.rejected_%s", signal.itemsField), result.rejected),
+		)
+	}
+}
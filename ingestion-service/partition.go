@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Partition key strategies
+const (
+	partitionKeyTraceID       = "trace_id"
+	partitionKeyServiceName   = "service_name"
+	partitionKeyServiceMetric = "service_metric"
+	partitionKeyResourceHash  = "resource_hash"
+	partitionKeyNone          = "none"
+)
+
+// validPartitionKeys lists the key strategies supported by each signal
+var validPartitionKeys = map[otlp.Signal][]string{
+	otlp.SignalTraces:  {partitionKeyTraceID, partitionKeyServiceName, partitionKeyResourceHash, partitionKeyNone},
+	otlp.SignalMetrics: {partitionKeyServiceMetric, partitionKeyServiceName, partitionKeyResourceHash, partitionKeyNone},
+	otlp.SignalLogs:    {partitionKeyResourceHash, partitionKeyServiceName, partitionKeyTraceID, partitionKeyNone},
+}
+
+// validatePartitionKey checks that a key strategy is supported for a signal
+func validatePartitionKey(signal otlp.Signal, strategy string) error {
+	for _, valid := range validPartitionKeys[signal] {
+		if strategy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown kafka.partition_keys.%s %q (valid: %v)", signal, strategy, validPartitionKeys[signal])
+}
+
+// resourceKey holds the resource-level inputs to a partition key
+type resourceKey struct {
+	serviceName string
+	hash        string
+}
+
+// newResourceKey computes the service name and attribute hash of a resource
+func newResourceKey(resource otlp.Resource) resourceKey {
+	return resourceKey{
+		serviceName: resource.ServiceName(),
+		hash:        resourceHash(resource),
+	}
+}
+
+// resourceHash returns a stable hash of the resource attributes, independent of their order
+func resourceHash(resource otlp.Resource) string {
+	pairs := make([]string, 0, len(resource.Attributes))
+	for _, kv := range resource.Attributes {
+		pairs = append(pairs, kv.Key+"="+kv.Value.AsString())
+	}
+	sort.Strings(pairs)
+
+	h := fnv.New64a()
+	for _, pair := range pairs {
+		h.Write([]byte(pair))
+		h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// spanPartitionKey returns the partition key of a span
+func spanPartitionKey(strategy string, rk resourceKey, span otlp.Span) string {
+	switch strategy {
+	case partitionKeyTraceID:
+		return span.TraceID
+	case partitionKeyServiceName:
+		return rk.serviceName
+	case partitionKeyResourceHash:
+		return rk.hash
+	default:
+		return ""
+	}
+}
+
+// metricPartitionKey returns the partition key of a metric
+func metricPartitionKey(strategy string, rk resourceKey, metric otlp.Metric) string {
+	switch strategy {
+	case partitionKeyServiceMetric:
+		return rk.serviceName + "/" + metric.Name
+	case partitionKeyServiceName:
+		return rk.serviceName
+	case partitionKeyResourceHash:
+		return rk.hash
+	default:
+		return ""
+	}
+}
+
+// logPartitionKey returns the partition key of a log record
+func logPartitionKey(strategy string, rk resourceKey, record otlp.LogRecord) string {
+	switch strategy {
+	case partitionKeyTraceID:
+		return record.TraceID
+	case partitionKeyServiceName:
+		return rk.serviceName
+	case partitionKeyResourceHash:
+		return rk.hash
+	default:
+		return ""
+	}
+}
+
+// splitPayload splits an OTLP payload into one envelope per partition key, preserving
+// the resource and scope grouping of the items in each envelope
+func splitPayload(payload otlp.Payload, strategy string) []kafkaRecord {
+	if strategy == partitionKeyNone {
+		return []kafkaRecord{envelopeRecord("", payload)}
+	}
+
+	switch p := payload.(type) {
+	case *otlp.Traces:
+		return splitTraces(p, strategy)
+	case *otlp.Metrics:
+		return splitMetrics(p, strategy)
+	case *otlp.Logs:
+		return splitLogs(p, strategy)
+	default:
+		return nil
+	}
+}
+
+// envelopeRecord wraps an OTLP payload in a Kafka record carrying its item count
+func envelopeRecord(key string, payload otlp.Payload) kafkaRecord {
+	return kafkaRecord{
+		key:   key,
+		value: payload,
+		headers: map[string]string{
+			"item_count": strconv.FormatInt(payload.ItemCount(), 10),
+		},
+	}
+}
+
+// splitTraces groups spans into one Traces envelope per partition key
+func splitTraces(traces *otlp.Traces, strategy string) []kafkaRecord {
+	var keys []string
+	envelopes := make(map[string]*otlp.Traces)
+	for _, rs := range traces.ResourceSpans {
+		rk := newResourceKey(rs.Resource)
+		// Entries for this resource and scope within each key's envelope
+		resources := make(map[string]*otlp.ResourceSpans)
+		for _, ss := range rs.ScopeSpans {
+			scopes := make(map[string]*otlp.ScopeSpans)
+			for _, span := range ss.Spans {
+				key := spanPartitionKey(strategy, rk, span)
+				scope, ok := scopes[key]
+				if !ok {
+					resource, ok := resources[key]
+					if !ok {
+						envelope, ok := envelopes[key]
+						if !ok {
+							envelope = &otlp.Traces{}
+							envelopes[key] = envelope
+							keys = append(keys, key)
+						}
+						envelope.ResourceSpans = append(envelope.ResourceSpans, otlp.ResourceSpans{Resource: rs.Resource, SchemaURL: rs.SchemaURL})
+						resource = &envelope.ResourceSpans[len(envelope.ResourceSpans)-1]
+						resources[key] = resource
+					}
+					resource.ScopeSpans = append(resource.ScopeSpans, otlp.ScopeSpans{Scope: ss.Scope, SchemaURL: ss.SchemaURL})
+					scope = &resource.ScopeSpans[len(resource.ScopeSpans)-1]
+					scopes[key] = scope
+				}
+				scope.Spans = append(scope.Spans, span)
+			}
+		}
+	}
+
+	records := make([]kafkaRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, envelopeRecord(key, envelopes[key]))
+	}
+	return records
+}
+
+// splitMetrics groups metrics into one Metrics envelope per partition key
+func splitMetrics(metrics *otlp.Metrics, strategy string) []kafkaRecord {
+	var keys []string
+	envelopes := make(map[string]*otlp.Metrics)
+	for _, rm := range metrics.ResourceMetrics {
+		rk := newResourceKey(rm.Resource)
+		// Entries for this resource and scope within each key's envelope
+		resources := make(map[string]*otlp.ResourceMetrics)
+		for _, sm := range rm.ScopeMetrics {
+			scopes := make(map[string]*otlp.ScopeMetrics)
+			for _, metric := range sm.Metrics {
+				key := metricPartitionKey(strategy, rk, metric)
+				scope, ok := scopes[key]
+				if !ok {
+					resource, ok := resources[key]
+					if !ok {
+						envelope, ok := envelopes[key]
+						if !ok {
+							envelope = &otlp.Metrics{}
+							envelopes[key] = envelope
+							keys = append(keys, key)
+						}
+						envelope.ResourceMetrics = append(envelope.ResourceMetrics, otlp.ResourceMetrics{Resource: rm.Resource, SchemaURL: rm.SchemaURL})
+						resource = &envelope.ResourceMetrics[len(envelope.ResourceMetrics)-1]
+						resources[key] = resource
+					}
+					resource.ScopeMetrics = append(resource.ScopeMetrics, otlp.ScopeMetrics{Scope: sm.Scope, SchemaURL: sm.SchemaURL})
+					scope = &resource.ScopeMetrics[len(resource.ScopeMetrics)-1]
+					scopes[key] = scope
+				}
+				scope.Metrics = append(scope.Metrics, metric)
+			}
+		}
+	}
+
+	records := make([]kafkaRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, envelopeRecord(key, envelopes[key]))
+	}
+	return records
+}
+
+// splitLogs groups log records into one Logs envelope per partition key
+func splitLogs(logs *otlp.Logs, strategy string) []kafkaRecord {
+	var keys []string
+	envelopes := make(map[string]*otlp.Logs)
+	for _, rl := range logs.ResourceLogs {
+		rk := newResourceKey(rl.Resource)
+		// Entries for this resource and scope within each key's envelope
+		resources := make(map[string]*otlp.ResourceLogs)
+		for _, sl := range rl.ScopeLogs {
+			scopes := make(map[string]*otlp.ScopeLogs)
+			for _, record := range sl.LogRecords {
+				key := logPartitionKey(strategy, rk, record)
+				scope, ok := scopes[key]
+				if !ok {
+					resource, ok := resources[key]
+					if !ok {
+						envelope, ok := envelopes[key]
+						if !ok {
+							envelope = &otlp.Logs{}
+							envelopes[key] = envelope
+							keys = append(keys, key)
+						}
+						envelope.ResourceLogs = append(envelope.ResourceLogs, otlp.ResourceLogs{Resource: rl.Resource, SchemaURL: rl.SchemaURL})
+						resource = &envelope.ResourceLogs[len(envelope.ResourceLogs)-1]
+						resources[key] = resource
+					}
+					resource.ScopeLogs = append(resource.ScopeLogs, otlp.ScopeLogs{Scope: sl.Scope, SchemaURL: sl.SchemaURL})
+					scope = &resource.ScopeLogs[len(resource.ScopeLogs)-1]
+					scopes[key] = scope
+				}
+				scope.LogRecords = append(scope.LogRecords, record)
+			}
+		}
+	}
+
+	records := make([]kafkaRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, envelopeRecord(key, envelopes[key]))
+	}
+	return records
+}
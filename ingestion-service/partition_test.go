@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Test payloads from the cart and pay services, where cart sends items of several traces or metrics
+const (
+	testPartitionTraces = `{"resourceSpans":[
+		{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"cart"}}]},"scopeSpans":[{"spans":[
+			{"traceId":"0a","spanId":"01"},{"traceId":"0b","spanId":"02"},{"traceId":"0a","spanId":"03"}]}]},
+		{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"pay"}}]},"scopeSpans":[{"spans":[
+			{"traceId":"0b","spanId":"04"}]}]}]}`
+	testPartitionMetrics = `{"resourceMetrics":[
+		{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"cart"}}]},"scopeMetrics":[{"metrics":[
+			{"name":"requests","gauge":{"dataPoints":[{"asInt":"1"},{"asInt":"2"}]}},{"name":"errors","gauge":{"dataPoints":[{"asInt":"1"}]}}]}]},
+		{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"pay"}}]},"scopeMetrics":[{"metrics":[
+			{"name":"requests","gauge":{"dataPoints":[{"asInt":"1"}]}}]}]}]}`
+	testPartitionLogs = `{"resourceLogs":[
+		{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"cart"}}]},"scopeLogs":[{"logRecords":[
+			{"traceId":"0a"},{},{"traceId":"0a"}]}]},
+		{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"pay"}}]},"scopeLogs":[{"logRecords":[
+			{"traceId":"0b"}]}]}]}`
+)
+
+func TestSplitPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		signal   otlp.Signal
+		body     string
+		strategy string
+		want     []string // key and item count of each envelope, in order
+	}{
+		{name: "traces by trace ID", signal: otlp.SignalTraces, body: testPartitionTraces, strategy: partitionKeyTraceID, want: []string{"0a:2", "0b:2"}},
+		{name: "traces by service", signal: otlp.SignalTraces, body: testPartitionTraces, strategy: partitionKeyServiceName, want: []string{"cart:3", "pay:1"}},
+		{name: "traces unkeyed", signal: otlp.SignalTraces, body: testPartitionTraces, strategy: partitionKeyNone, want: []string{":4"}},
+		{name: "metrics by service and metric", signal: otlp.SignalMetrics, body: testPartitionMetrics, strategy: partitionKeyServiceMetric, want: []string{"cart/requests:2", "cart/errors:1", "pay/requests:1"}},
+		{name: "metrics by service", signal: otlp.SignalMetrics, body: testPartitionMetrics, strategy: partitionKeyServiceName, want: []string{"cart:3", "pay:1"}},
+		{name: "logs by trace ID", signal: otlp.SignalLogs, body: testPartitionLogs, strategy: partitionKeyTraceID, want: []string{"0a:2", ":1", "0b:1"}},
+		{name: "logs by service", signal: otlp.SignalLogs, body: testPartitionLogs, strategy: partitionKeyServiceName, want: []string{"cart:3", "pay:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := decodeTestPayload(t, tt.signal, tt.body)
+			var got []string
+			for _, record := range splitPayload(payload, tt.strategy) {
+				envelope := record.value.(otlp.Payload)
+				got = append(got, fmt.Sprintf("%s:%d", record.key, envelope.ItemCount()))
+				if want := fmt.Sprint(envelope.ItemCount()); record.headers["item_count"] != want {
+					t.Errorf("envelope %q item_count header = %q, want %q", record.key, record.headers["item_count"], want)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitPayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenPartitionKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		signal   otlp.Signal
+		body     string
+		strategy string
+		want     []string
+	}{
+		{name: "spans by trace ID", signal: otlp.SignalTraces, body: testPartitionTraces, strategy: partitionKeyTraceID, want: []string{"0a", "0b", "0a", "0b"}},
+		{name: "spans by service", signal: otlp.SignalTraces, body: testPartitionTraces, strategy: partitionKeyServiceName, want: []string{"cart", "cart", "cart", "pay"}},
+		{name: "data points by service and metric", signal: otlp.SignalMetrics, body: testPartitionMetrics, strategy: partitionKeyServiceMetric, want: []string{"cart/requests", "cart/requests", "cart/errors", "pay/requests"}},
+		{name: "log records by trace ID", signal: otlp.SignalLogs, body: testPartitionLogs, strategy: partitionKeyTraceID, want: []string{"0a", "", "0a", "0b"}},
+		{name: "unkeyed", signal: otlp.SignalLogs, body: testPartitionLogs, strategy: partitionKeyNone, want: []string{"", "", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := decodeTestPayload(t, tt.signal, tt.body)
+			var got []string
+			for _, record := range flattenPayload(payload, tt.strategy, time.Now()) {
+				got = append(got, record.key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flattenPayload() keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceHash(t *testing.T) {
+	resource := func(attrs ...string) otlp.Resource {
+		var r otlp.Resource
+		for i := 0; i < len(attrs); i += 2 {
+			r.Attributes = append(r.Attributes, otlp.KeyValue{Key: attrs[i], Value: otlp.StringAnyValue(attrs[i+1])})
+		}
+		return r
+	}
+	tests := []struct {
+		name     string
+		a, b     otlp.Resource
+		wantSame bool
+	}{
+		{name: "same attributes in another order", a: resource("service.name", "cart", "host", "a"), b: resource("host", "a", "service.name", "cart"), wantSame: true},
+		{name: "different value", a: resource("service.name", "cart", "host", "a"), b: resource("service.name", "cart", "host", "b")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := resourceHash(tt.a) == resourceHash(tt.b); same != tt.wantSame {
+				t.Errorf("hashes equal = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestValidatePartitionKey(t *testing.T) {
+	tests := []struct {
+		signal   otlp.Signal
+		strategy string
+		wantErr  bool
+	}{
+		{signal: otlp.SignalTraces, strategy: partitionKeyTraceID},
+		{signal: otlp.SignalTraces, strategy: partitionKeyServiceMetric, wantErr: true},
+		{signal: otlp.SignalMetrics, strategy: partitionKeyServiceMetric},
+		{signal: otlp.SignalMetrics, strategy: partitionKeyTraceID, wantErr: true},
+		{signal: otlp.SignalLogs, strategy: partitionKeyResourceHash},
+		{signal: otlp.SignalLogs, strategy: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.signal, tt.strategy), func(t *testing.T) {
+			if err := validatePartitionKey(tt.signal, tt.strategy); (err != nil) != tt.wantErr {
+				t.Errorf("validatePartitionKey() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
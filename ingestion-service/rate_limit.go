@@ -0,0 +1,423 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Limits enforced by the rate limiter
+const (
+	rateLimitRequests = "requests"
+	rateLimitBytes    = "bytes"
+	rateLimitItems    = "items"
+)
+
+// Scopes the rate limits apply to
+const (
+	rateLimitScopeGlobal  = "global"
+	rateLimitScopeTenant  = "tenant"
+	rateLimitScopeService = "service"
+)
+
+// rateLimitMaxBuckets bounds the number of buckets kept for tenants and services that are
+// not listed in the config
+const rateLimitMaxBuckets = 10000
+
+// tokenBucket is a token bucket refilled continuously at rate tokens per second up to burst.
+// A nil bucket is unlimited.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket, or nil if rate is zero. The burst defaults to one
+// second of the rate.
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	b := &tokenBucket{rate: rate, burst: float64(burst), last: now}
+	if b.burst <= 0 {
+		b.burst = math.Max(1, math.Ceil(rate))
+	}
+	b.tokens = b.burst
+	return b
+}
+
+// wait refills the bucket and returns how long until n tokens are available, or zero if
+// they are. A cost above the burst only needs a full bucket, which then goes into debt,
+// so oversized requests are slowed down rather than rejected forever.
+func (b *tokenBucket) wait(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	need := math.Min(n, b.burst)
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take removes n tokens; wait must have been called first
+func (b *tokenBucket) take(n float64) {
+	if b != nil {
+		b.tokens -= n
+	}
+}
+
+// refund returns n tokens taken for work that was not done
+func (b *tokenBucket) refund(n float64) {
+	if b != nil {
+		b.tokens = math.Min(b.burst, b.tokens+n)
+	}
+}
+
+// full reports whether the bucket has refilled completely
+func (b *tokenBucket) full(now time.Time) bool {
+	return b == nil || b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// rateLimitBuckets holds the request, byte and item buckets of one scope
+type rateLimitBuckets struct {
+	requests *tokenBucket
+	bytes    *tokenBucket
+	items    *tokenBucket
+	recent   *list.Element // position in the rate limiter's recent list, nil if never evicted
+}
+
+// full reports whether all buckets have refilled completely and so behave like new ones
+func (b *rateLimitBuckets) full(now time.Time) bool {
+	return b.requests.full(now) && b.bytes.full(now) && b.items.full(now)
+}
+
+// rateLimitScope names a set of buckets, such as the buckets of one tenant. A shared scope
+// holds the buckets of the unlisted tenants or services seen once no more can be kept.
+type rateLimitScope struct {
+	scope  string
+	name   string
+	shared bool
+}
+
+// rateLimitError reports a request over its request or byte rate limit
+type rateLimitError struct {
+	scope      string
+	limit      string
+	retryAfter time.Duration
+}
+
+// Error describes the exceeded limit
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit of the %s exceeded", e.limit, e.scope)
+}
+
+// rateLimiter enforces token-bucket limits on requests, bytes and items per second,
+// globally and per tenant and service.name. Requests over the request or byte limits are
+// refused as a whole; items over the item limits are dropped resource by resource.
+type rateLimiter struct {
+	config            RateLimitConfig
+	itemsLimited      bool
+	throttledRequests metric.Int64Counter
+	throttledBytes    metric.Int64Counter
+	throttledItems    metric.Int64Counter
+
+	mu         sync.Mutex
+	buckets    map[rateLimitScope]*rateLimitBuckets
+	recent     *list.List // scopes of unlisted tenants and services, most recently used first
+	maxBuckets int
+}
+
+// newRateLimiter creates the rate limiter for the configured limits and registers its metrics
+func newRateLimiter(config *Config, tm *TelemetryManager) (*rateLimiter, error) {
+	l := &rateLimiter{
+		config:     config.RateLimits,
+		buckets:    make(map[rateLimitScope]*rateLimitBuckets),
+		recent:     list.New(),
+		maxBuckets: rateLimitMaxBuckets,
+	}
+	l.itemsLimited = l.config.Global.ItemsPerSecond > 0 || l.config.Tenant.ItemsPerSecond > 0 || l.config.Service.ItemsPerSecond > 0
+	for _, limits := range l.config.Tenants {
+		l.itemsLimited = l.itemsLimited || limits.ItemsPerSecond > 0
+	}
+	for _, limits := range l.config.Services {
+		l.itemsLimited = l.itemsLimited || limits.ItemsPerSecond > 0
+	}
+
+	meter := tm.GetMeter()
+	var err error
+	l.throttledRequests, err = meter.Int64Counter("ingestion.ratelimit.throttled.requests",
+		metric.WithDescription("OTLP requests refused for exceeding a request or byte rate limit, by signal, scope and limit"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create throttled requests counter: %w", err)
+	}
+	l.throttledBytes, err = meter.Int64Counter("ingestion.ratelimit.throttled.bytes",
+		metric.WithDescription("Uncompressed size of OTLP requests refused by rate limits, by signal, scope and limit"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create throttled bytes counter: %w", err)
+	}
+	l.throttledItems, err = meter.Int64Counter("ingestion.ratelimit.throttled.items",
+		metric.WithDescription("Spans, metric data points and log records refused or dropped by rate limits, by signal, scope and limit"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create throttled items counter: %w", err)
+	}
+	return l, nil
+}
+
+// scopeBuckets returns the buckets of a scope, creating them on first use, or nil if the
+// scope has no limits. At most maxBuckets unlisted tenants and services get buckets of their
+// own: past that, the least recently used ones are evicted once they have refilled, and
+// otherwise new names share one bucket per scope. The caller must hold l.mu.
+func (l *rateLimiter) scopeBuckets(scope rateLimitScope, now time.Time) *rateLimitBuckets {
+	var limits RateLimitsConfig
+	listed := true
+	switch scope.scope {
+	case rateLimitScopeGlobal:
+		limits = l.config.Global
+	case rateLimitScopeTenant:
+		if limits, listed = l.config.Tenants[scope.name]; !listed {
+			limits = l.config.Tenant
+		}
+	case rateLimitScopeService:
+		if limits, listed = l.config.Services[scope.name]; !listed {
+			limits = l.config.Service
+		}
+	}
+	if limits.RequestsPerSecond <= 0 && limits.BytesPerSecond <= 0 && limits.ItemsPerSecond <= 0 {
+		return nil
+	}
+
+	if buckets, ok := l.buckets[scope]; ok {
+		if buckets.recent != nil {
+			l.recent.MoveToFront(buckets.recent)
+		}
+		return buckets
+	}
+	evictable := !listed
+	if evictable && l.recent.Len() >= l.maxBuckets {
+		oldest := l.recent.Back()
+		evicted := oldest.Value.(rateLimitScope)
+		if !l.buckets[evicted].full(now) {
+			scope = rateLimitScope{scope: scope.scope, shared: true}
+			if buckets, ok := l.buckets[scope]; ok {
+				return buckets
+			}
+			evictable = false
+		} else {
+			l.recent.Remove(oldest)
+			delete(l.buckets, evicted)
+		}
+	}
+
+	buckets := &rateLimitBuckets{
+		requests: newTokenBucket(limits.RequestsPerSecond, limits.RequestsBurst, now),
+		bytes:    newTokenBucket(limits.BytesPerSecond, limits.BytesBurst, now),
+		items:    newTokenBucket(limits.ItemsPerSecond, limits.ItemsBurst, now),
+	}
+	if evictable {
+		buckets.recent = l.recent.PushFront(scope)
+	}
+	l.buckets[scope] = buckets
+	return buckets
+}
+
+// allowRequest charges a request to the request and byte limits of the global scope and of
+// the tenant and services of each admitted tenant batch, so that tenants attributed from
+// resource attributes are limited too and services of refused batches are not charged. If
+// the request is within every limit it returns a function that refunds the charge when the
+// request fails and will be retried; otherwise it returns the exceeded limit with the delay
+// before a retry can succeed.
+func (l *rateLimiter) allowRequest(ctx context.Context, signal string, batches []tenantBatch, size int) (func(), *rateLimitError) {
+	if !l.config.Enabled {
+		return func() {}, nil
+	}
+
+	scopes := []rateLimitScope{{scope: rateLimitScopeGlobal}}
+	seen := make(map[rateLimitScope]bool)
+	var items int64
+	for _, batch := range batches {
+		batchScopes := []rateLimitScope{{scope: rateLimitScopeTenant, name: batch.tenant}}
+		services, _ := splitPayloadByResource(batch.payload, otlp.Resource.ServiceName)
+		for _, service := range services {
+			batchScopes = append(batchScopes, rateLimitScope{scope: rateLimitScopeService, name: service})
+		}
+		for _, scope := range batchScopes {
+			if (scope.scope == rateLimitScopeTenant && scope.name == "") || seen[scope] {
+				continue
+			}
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+		items += batch.payload.ItemCount()
+	}
+
+	now := time.Now()
+	var exceeded *rateLimitError
+	l.mu.Lock()
+	buckets := make([]*rateLimitBuckets, 0, len(scopes))
+	for _, scope := range scopes {
+		b := l.scopeBuckets(scope, now)
+		if b == nil {
+			continue
+		}
+		buckets = append(buckets, b)
+		if wait := b.requests.wait(1, now); wait > 0 && (exceeded == nil || wait > exceeded.retryAfter) {
+			exceeded = &rateLimitError{scope: scope.scope, limit: rateLimitRequests, retryAfter: wait}
+		}
+		if wait := b.bytes.wait(float64(size), now); wait > 0 && (exceeded == nil || wait > exceeded.retryAfter) {
+			exceeded = &rateLimitError{scope: scope.scope, limit: rateLimitBytes, retryAfter: wait}
+		}
+	}
+	if exceeded == nil {
+		for _, b := range buckets {
+			b.requests.take(1)
+			b.bytes.take(float64(size))
+		}
+	}
+	l.mu.Unlock()
+	if exceeded == nil {
+		return func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for _, b := range buckets {
+				b.requests.refund(1)
+				b.bytes.refund(float64(size))
+			}
+		}, nil
+	}
+
+	attrs := metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("scope", exceeded.scope),
+		attribute.String("limit", exceeded.limit),
+	)
+	l.throttledRequests.Add(ctx, 1, attrs)
+	l.throttledBytes.Add(ctx, int64(size), attrs)
+	l.throttledItems.Add(ctx, items, attrs)
+	trace.SpanFromContext(ctx).AddEvent("ratelimit.throttled", trace.WithAttributes(
+		attribute.String("scope", exceeded.scope),
+		attribute.String("limit", exceeded.limit),
+	))
+	return nil, exceeded
+}
+
+// takeItems charges a tenant's payload to the item limits of the global scope, the tenant
+// and each resource's service. Resources are admitted in order while every limit has room;
+// it returns the admitted part of the payload, the number of items dropped and a function
+// that refunds the admitted items when they could not be sent and will be retried.
+func (l *rateLimiter) takeItems(ctx context.Context, signal, tenant string, payload otlp.Payload) (otlp.Payload, int64, func()) {
+	if !l.config.Enabled || !l.itemsLimited {
+		return payload, 0, func() {}
+	}
+
+	now := time.Now()
+	throttled := make(map[string]int64)
+	var refunds []func()
+	l.mu.Lock()
+	kept, dropped := filterPayloadResources(payload, func(resource otlp.Resource, items int64) bool {
+		scopes := []rateLimitScope{
+			{scope: rateLimitScopeGlobal},
+			{scope: rateLimitScopeTenant, name: tenant},
+			{scope: rateLimitScopeService, name: resource.ServiceName()},
+		}
+		buckets := make([]*rateLimitBuckets, 0, len(scopes))
+		for _, scope := range scopes {
+			if scope.scope == rateLimitScopeTenant && tenant == "" {
+				continue
+			}
+			b := l.scopeBuckets(scope, now)
+			if b == nil {
+				continue
+			}
+			if b.items.wait(float64(items), now) > 0 {
+				throttled[scope.scope] += items
+				return false
+			}
+			buckets = append(buckets, b)
+		}
+		for _, b := range buckets {
+			b.items.take(float64(items))
+		}
+		refunds = append(refunds, func() {
+			for _, b := range buckets {
+				b.items.refund(float64(items))
+			}
+		})
+		return true
+	})
+	l.mu.Unlock()
+
+	for scope, items := range throttled {
+		l.throttledItems.Add(ctx, items, metric.WithAttributes(
+			attribute.String("signal", signal),
+			attribute.String("scope", scope),
+			attribute.String("limit", rateLimitItems),
+		))
+	}
+	// The refund keeps the charged buckets rather than reading the payload again, which
+	// may have been redacted by then
+	return kept, dropped, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, refund := range refunds {
+			refund()
+		}
+	}
+}
+
+// filterPayloadResources returns the payload with only the resources keep accepts, and
+// the number of items in the resources it removed. keep is called once per resource with
+// the resource's item count.
+func filterPayloadResources(payload otlp.Payload, keep func(resource otlp.Resource, items int64) bool) (otlp.Payload, int64) {
+	var dropped int64
+	switch p := payload.(type) {
+	case *otlp.Traces:
+		kept := &otlp.Traces{}
+		for _, rs := range p.ResourceSpans {
+			items := (&otlp.Traces{ResourceSpans: []otlp.ResourceSpans{rs}}).ItemCount()
+			if keep(rs.Resource, items) {
+				kept.ResourceSpans = append(kept.ResourceSpans, rs)
+			} else {
+				dropped += items
+			}
+		}
+		return kept, dropped
+	case *otlp.Metrics:
+		kept := &otlp.Metrics{}
+		for _, rm := range p.ResourceMetrics {
+			items := (&otlp.Metrics{ResourceMetrics: []otlp.ResourceMetrics{rm}}).ItemCount()
+			if keep(rm.Resource, items) {
+				kept.ResourceMetrics = append(kept.ResourceMetrics, rm)
+			} else {
+				dropped += items
+			}
+		}
+		return kept, dropped
+	case *otlp.Logs:
+		kept := &otlp.Logs{}
+		for _, rl := range p.ResourceLogs {
+			items := (&otlp.Logs{ResourceLogs: []otlp.ResourceLogs{rl}}).ItemCount()
+			if keep(rl.Resource, items) {
+				kept.ResourceLogs = append(kept.ResourceLogs, rl)
+			} else {
+				dropped += items
+			}
+		}
+		return kept, dropped
+	default:
+		return payload, 0
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// newTestTelemetryManager returns a telemetry manager that exports nothing
+func newTestTelemetryManager(t *testing.T, config *Config) *TelemetryManager {
+	t.Helper()
+	config.OpenTelemetry.Tracing.Exporter = "none"
+	tm, err := NewTelemetryManager(config, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tm.Shutdown(context.Background()) })
+	return tm
+}
+
+// testTraces returns a trace payload with one resource per service, each holding the given
+// number of valid spans
+func testTraces(t *testing.T, services []string, spans int) otlp.Payload {
+	t.Helper()
+	resources := make([]string, 0, len(services))
+	for i, service := range services {
+		spanList := make([]string, 0, spans)
+		for j := 0; j < spans; j++ {
+			spanList = append(spanList, fmt.Sprintf(
+				`{"traceId":"%032x","spanId":"%016x","name":"op","startTimeUnixNano":"1","endTimeUnixNano":"2"}`, i+1, j+1))
+		}
+		resources = append(resources, fmt.Sprintf(
+			`{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":%q}}]},"scopeSpans":[{"spans":[%s]}]}`,
+			service, strings.Join(spanList, ",")))
+	}
+	payload, err := otlp.Unmarshal(otlp.SignalTraces, otlp.EncodingJSON,
+		[]byte(`{"resourceSpans":[`+strings.Join(resources, ",")+`]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    int
+		steps    func(b *tokenBucket)
+		at       time.Duration
+		need     float64
+		wantWait time.Duration
+	}{
+		{name: "full bucket", rate: 10, need: 10},
+		{name: "burst defaults to one second of the rate", rate: 10, need: 11, steps: func(b *tokenBucket) { b.take(1) }, wantWait: 100 * time.Millisecond},
+		{name: "empty bucket waits for the refill", rate: 10, steps: func(b *tokenBucket) { b.take(10) }, need: 5, wantWait: 500 * time.Millisecond},
+		{name: "refills over time", rate: 10, steps: func(b *tokenBucket) { b.take(10) }, at: 500 * time.Millisecond, need: 5},
+		{name: "refill is capped at the burst", rate: 10, burst: 20, at: time.Hour, steps: func(b *tokenBucket) { b.wait(0, start.Add(time.Hour)); b.take(20) }, need: 20, wantWait: 2 * time.Second},
+		{name: "oversized cost only needs a full bucket", rate: 10, need: 100},
+		{name: "oversized cost goes into debt", rate: 10, steps: func(b *tokenBucket) { b.take(100) }, need: 1, wantWait: 9100 * time.Millisecond},
+		{name: "refund returns tokens", rate: 10, steps: func(b *tokenBucket) { b.take(10); b.refund(10) }, need: 10},
+		{name: "refund is capped at the burst", rate: 10, steps: func(b *tokenBucket) { b.refund(100); b.take(10) }, need: 1, wantWait: 100 * time.Millisecond},
+		{name: "zero rate is unlimited", rate: 0, steps: func(b *tokenBucket) { b.take(1000); b.refund(1) }, need: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.rate, tt.burst, start)
+			if tt.steps != nil {
+				tt.steps(b)
+			}
+			if got := b.wait(tt.need, start.Add(tt.at)); got != tt.wantWait {
+				t.Errorf("wait(%v) = %v, want %v", tt.need, got, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestTokenBucketFull(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := newTokenBucket(10, 0, start)
+	if !b.full(start) {
+		t.Error("new bucket is not full")
+	}
+	b.take(5)
+	if b.full(start.Add(100 * time.Millisecond)) {
+		t.Error("bucket full before refilling")
+	}
+	if !b.full(start.Add(500 * time.Millisecond)) {
+		t.Error("bucket not full after refilling")
+	}
+	if !(*tokenBucket)(nil).full(start) {
+		t.Error("nil bucket is not full")
+	}
+}
+
+func TestScopeBucketsBound(t *testing.T) {
+	tests := []struct {
+		name       string
+		used       []string // unlisted services whose buckets were charged, oldest first
+		refill     time.Duration
+		service    string
+		wantShared bool
+		wantKept   []string
+	}{
+		{
+			name:     "below the bound",
+			used:     []string{"a"},
+			service:  "b",
+			wantKept: []string{"a", "b"},
+		},
+		{
+			name:       "bound reached while buckets are in use",
+			used:       []string{"a", "b"},
+			service:    "c",
+			wantShared: true,
+			wantKept:   []string{"a", "b"},
+		},
+		{
+			name:     "least recently used bucket refilled",
+			used:     []string{"a", "b"},
+			refill:   2 * time.Second,
+			service:  "c",
+			wantKept: []string{"b", "c"},
+		},
+		{
+			name:     "listed services do not count",
+			used:     []string{"a", "b"},
+			service:  "listed",
+			wantKept: []string{"a", "b", "listed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			setDefaults(config)
+			config.RateLimits = RateLimitConfig{
+				Service:  RateLimitsConfig{RequestsPerSecond: 1},
+				Services: map[string]RateLimitsConfig{"listed": {RequestsPerSecond: 1}},
+			}
+			limiter, err := newRateLimiter(config, newTestTelemetryManager(t, config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			limiter.maxBuckets = 2
+
+			start := time.Unix(0, 0)
+			for _, service := range tt.used {
+				limiter.scopeBuckets(rateLimitScope{scope: rateLimitScopeService, name: service}, start).requests.take(1)
+			}
+			limiter.scopeBuckets(rateLimitScope{scope: rateLimitScopeService, name: tt.service}, start.Add(tt.refill))
+
+			_, shared := limiter.buckets[rateLimitScope{scope: rateLimitScopeService, shared: true}]
+			if shared != tt.wantShared {
+				t.Errorf("shared bucket created = %v, want %v", shared, tt.wantShared)
+			}
+			for _, service := range tt.wantKept {
+				if _, ok := limiter.buckets[rateLimitScope{scope: rateLimitScopeService, name: service}]; !ok {
+					t.Errorf("bucket of %q not kept", service)
+				}
+			}
+			want := len(tt.wantKept)
+			if tt.wantShared {
+				want++
+			}
+			if got := len(limiter.buckets); got != want {
+				t.Errorf("%d buckets kept, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestAllowRequest(t *testing.T) {
+	tests := []struct {
+		name              string
+		limits            RateLimitConfig
+		tenant            string
+		resourceAttribute string // attribute tenants are read from instead of the context
+		services          []string
+		size              int
+		refund            bool
+		wantLimit         string
+		wantScope         string
+	}{
+		{name: "disabled", limits: RateLimitConfig{Global: RateLimitsConfig{RequestsPerSecond: 1}}},
+		{name: "global request limit", limits: RateLimitConfig{Enabled: true, Global: RateLimitsConfig{RequestsPerSecond: 1}}, wantLimit: rateLimitRequests, wantScope: rateLimitScopeGlobal},
+		{name: "refunded request", limits: RateLimitConfig{Enabled: true, Global: RateLimitsConfig{RequestsPerSecond: 1}}, refund: true},
+		{name: "tenant byte limit", limits: RateLimitConfig{Enabled: true, Tenant: RateLimitsConfig{BytesPerSecond: 100}}, tenant: "team-a", size: 60, wantLimit: rateLimitBytes, wantScope: rateLimitScopeTenant},
+		{name: "listed tenant overrides the tenant limit", limits: RateLimitConfig{Enabled: true, Tenant: RateLimitsConfig{BytesPerSecond: 100}, Tenants: map[string]RateLimitsConfig{"team-a": {BytesPerSecond: 1000}}}, tenant: "team-a", size: 60},
+		{name: "requests without a tenant skip the tenant limit", limits: RateLimitConfig{Enabled: true, Tenant: RateLimitsConfig{RequestsPerSecond: 1}}},
+		{name: "service request limit", limits: RateLimitConfig{Enabled: true, Services: map[string]RateLimitsConfig{"noisy": {RequestsPerSecond: 1}}}, services: []string{"quiet", "noisy"}, wantLimit: rateLimitRequests, wantScope: rateLimitScopeService},
+		{name: "tenant from the resource attribute", limits: RateLimitConfig{Enabled: true, Tenants: map[string]RateLimitsConfig{"team-b": {RequestsPerSecond: 1}}}, resourceAttribute: "service.name", services: []string{"team-a", "team-b"}, wantLimit: rateLimitRequests, wantScope: rateLimitScopeTenant},
+		{name: "other tenants from the resource attribute are not limited", limits: RateLimitConfig{Enabled: true, Tenants: map[string]RateLimitsConfig{"team-b": {RequestsPerSecond: 1}}}, resourceAttribute: "service.name", services: []string{"team-a"}},
+		{name: "other services are not limited", limits: RateLimitConfig{Enabled: true, Services: map[string]RateLimitsConfig{"noisy": {RequestsPerSecond: 1}}}, services: []string{"quiet"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{RateLimits: tt.limits}
+			if tt.resourceAttribute != "" {
+				config.Tenancy = TenancyConfig{Enabled: true, ResourceAttribute: tt.resourceAttribute}
+			}
+			setDefaults(config)
+			tm := newTestTelemetryManager(t, config)
+			l, err := newRateLimiter(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tenants, err := newTenantRegistry(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = contextWithTenant(ctx, tt.tenant)
+			}
+			services := tt.services
+			if services == nil {
+				services = []string{"svc"}
+			}
+			batches := tenants.route(ctx, testTraces(t, services, 1))
+
+			refund, err1 := l.allowRequest(ctx, "traces", batches, tt.size)
+			if err1 != nil {
+				t.Fatalf("first allowRequest() error = %v", err1)
+			}
+			if tt.refund {
+				refund()
+			}
+			_, err2 := l.allowRequest(ctx, "traces", batches, tt.size)
+			if tt.wantLimit == "" {
+				if err2 != nil {
+					t.Fatalf("second allowRequest() error = %v", err2)
+				}
+				return
+			}
+			if err2 == nil || err2.limit != tt.wantLimit || err2.scope != tt.wantScope {
+				t.Fatalf("second allowRequest() error = %v, want the %s %s limit", err2, tt.wantScope, tt.wantLimit)
+			}
+			if err2.retryAfter <= 0 {
+				t.Errorf("retryAfter = %v, want a positive delay", err2.retryAfter)
+			}
+		})
+	}
+}
+
+func TestTakeItems(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      RateLimitConfig
+		tenant      string
+		services    []string
+		refund      bool
+		wantKept    int64
+		wantDropped int64
+		// items left for the same payload afterwards
+		wantKeptAgain int64
+	}{
+		{name: "no item limits", limits: RateLimitConfig{Enabled: true, Global: RateLimitsConfig{RequestsPerSecond: 1}}, services: []string{"a", "b"}, wantKept: 4, wantKeptAgain: 4},
+		{name: "drops whole resources over the global limit", limits: RateLimitConfig{Enabled: true, Global: RateLimitsConfig{ItemsPerSecond: 3}}, services: []string{"a", "b"}, wantKept: 2, wantDropped: 2, wantKeptAgain: 0},
+		{name: "service limit only throttles that service", limits: RateLimitConfig{Enabled: true, Services: map[string]RateLimitsConfig{"b": {ItemsPerSecond: 1}}}, services: []string{"a", "b", "c"}, wantKept: 6, wantKeptAgain: 4},
+		{name: "tenant limit", limits: RateLimitConfig{Enabled: true, Tenant: RateLimitsConfig{ItemsPerSecond: 2}}, tenant: "team-a", services: []string{"a", "b"}, wantKept: 2, wantDropped: 2, wantKeptAgain: 0},
+		{name: "refunded items can be taken again", limits: RateLimitConfig{Enabled: true, Global: RateLimitsConfig{ItemsPerSecond: 3}}, services: []string{"a", "b"}, refund: true, wantKept: 2, wantDropped: 2, wantKeptAgain: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{RateLimits: tt.limits}
+			setDefaults(config)
+			l, err := newRateLimiter(config, newTestTelemetryManager(t, config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload := testTraces(t, tt.services, 2)
+
+			kept, dropped, refund := l.takeItems(context.Background(), "traces", tt.tenant, payload)
+			if kept.ItemCount() != tt.wantKept || dropped != tt.wantDropped {
+				t.Fatalf("takeItems() kept %d, dropped %d; want %d, %d", kept.ItemCount(), dropped, tt.wantKept, tt.wantDropped)
+			}
+			if tt.refund {
+				refund()
+			}
+			if again, _, _ := l.takeItems(context.Background(), "traces", tt.tenant, payload); again.ItemCount() != tt.wantKeptAgain {
+				t.Errorf("second takeItems() kept %d, want %d", again.ItemCount(), tt.wantKeptAgain)
+			}
+		})
+	}
+}
+
+func TestRejectedRequestsAreNotCharged(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(config *Config)
+		ctx     func(ctx context.Context) context.Context
+		payload func(t *testing.T) otlp.Payload
+	}{
+		{
+			name:   "rejected by validation",
+			config: func(config *Config) { config.Validation.Traces = validationModeReject },
+			payload: func(t *testing.T) otlp.Payload {
+				payload := testTraces(t, []string{"svc"}, 1)
+				payload.(*otlp.Traces).ResourceSpans[0].ScopeSpans[0].Spans[0].TraceID = ""
+				return payload
+			},
+		},
+		{
+			name:   "every item dropped by validation",
+			config: func(config *Config) { config.Validation.Traces = validationModeDropItem },
+			payload: func(t *testing.T) otlp.Payload {
+				payload := testTraces(t, []string{"svc"}, 1)
+				payload.(*otlp.Traces).ResourceSpans[0].ScopeSpans[0].Spans[0].TraceID = ""
+				return payload
+			},
+		},
+		{
+			name: "signal disabled for the tenant",
+			config: func(config *Config) {
+				config.Tenancy.Enabled = true
+				config.Tenancy.Tenants = []TenantConfig{{ID: "team-a", Signals: []string{"logs"}}}
+			},
+			ctx: func(ctx context.Context) context.Context { return contextWithTenant(ctx, "team-a") },
+		},
+		{
+			name: "unknown tenant from the resource attribute",
+			config: func(config *Config) {
+				config.Tenancy.Enabled = true
+				config.Tenancy.ResourceAttribute = "service.name"
+				config.Tenancy.RejectUnknown = true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			config.RateLimits.Enabled = true
+			config.RateLimits.Global.RequestsPerSecond = 1
+			tt.config(config)
+			setDefaults(config)
+			tm := newTestTelemetryManager(t, config)
+			tenants, err := newTenantRegistry(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			limiter, err := newRateLimiter(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			validator, err := newPayloadValidator(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			redactor, err := newPayloadRedactor(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+			payload := testTraces(t, []string{"svc"}, 1)
+			if tt.payload != nil {
+				payload = tt.payload(t)
+			}
+
+			signal := newOTLPSignals(config).traces
+			for i := 0; i < 3; i++ {
+				result, err := processOTLPData(ctx, tm, nil, tenants, limiter, validator, redactor, signal, "http", payload, 100)
+				var limitErr *rateLimitError
+				if errors.As(err, &limitErr) {
+					t.Fatalf("request %d was rate limited: %v", i, err)
+				}
+				if err == nil && result.rejected == 0 {
+					t.Fatalf("request %d was accepted", i)
+				}
+			}
+			if _, limitErr := limiter.allowRequest(ctx, signal.name, tenants.route(ctx, payload), 100); limitErr != nil {
+				t.Errorf("allowRequest() after rejected requests error = %v", limitErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// otlpSignal describes the per-signal parts of the OTLP receivers
+type otlpSignal struct {
+	name         string
+	path         string
+	topic        string
+	partitionKey string
+	itemsField   string
+	newResponse  func(result exportResult) proto.Message
+}
+
+// exportResult summarizes the items of an export request that were not accepted
+type exportResult struct {
+	rejected     int64
+	errorMessage string
+}
+
+// otlpSignals groups the traces, metrics and logs signal descriptions
+type otlpSignals struct {
+	traces  otlpSignal
+	metrics otlpSignal
+	logs    otlpSignal
+}
+
+// newOTLPSignals returns the signal descriptions for the configured topics
+func newOTLPSignals(config *Config) otlpSignals {
+	return otlpSignals{
+		traces: otlpSignal{
+			name:         string(otlp.SignalTraces),
+			path:         "/v1/traces",
+			topic:        config.Kafka.Topics.Traces,
+			partitionKey: config.Kafka.PartitionKeys.Traces,
+			itemsField:   "spans",
+			newResponse:  newTracesExportResponse,
+		},
+		metrics: otlpSignal{
+			name:         string(otlp.SignalMetrics),
+			path:         "/v1/metrics",
+			topic:        config.Kafka.Topics.Metrics,
+			partitionKey: config.Kafka.PartitionKeys.Metrics,
+			itemsField:   "data_points",
+			newResponse:  newMetricsExportResponse,
+		},
+		logs: otlpSignal{
+			name:         string(otlp.SignalLogs),
+			path:         "/v1/logs",
+			topic:        config.Kafka.Topics.Logs,
+			partitionKey: config.Kafka.PartitionKeys.Logs,
+			itemsField:   "log_records",
+			newResponse:  newLogsExportResponse,
+		},
+	}
+}
+
+// all returns every signal description
+func (s otlpSignals) all() []otlpSignal {
+	return []otlpSignal{s.traces, s.metrics, s.logs}
+}
+
+// newTracesExportResponse builds a trace export response, reporting rejected spans as partial success
+func newTracesExportResponse(result exportResult) proto.Message {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if result.rejected > 0 || result.errorMessage != "" {
+		resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+			RejectedSpans: result.rejected,
+			ErrorMessage:  result.errorMessage,
+		}
+	}
+	return resp
+}
+
+// newMetricsExportResponse builds a metrics export response, reporting rejected data points as partial success
+func newMetricsExportResponse(result exportResult) proto.Message {
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if result.rejected > 0 || result.errorMessage != "" {
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: result.rejected,
+			ErrorMessage:       result.errorMessage,
+		}
+	}
+	return resp
+}
+
+// newLogsExportResponse builds a logs export response, reporting rejected log records as partial success
+func newLogsExportResponse(result exportResult) proto.Message {
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if result.rejected > 0 || result.errorMessage != "" {
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: result.rejected,
+			ErrorMessage:       result.errorMessage,
+		}
+	}
+	return resp
+}
+
+// merge combines the rejected items of two results
+func (r exportResult) merge(other exportResult) exportResult {
+	r.rejected += other.rejected
+	if other.errorMessage != "" {
+		if r.errorMessage != "" {
+			r.errorMessage += "; "
+		}
+		r.errorMessage += other.errorMessage
+	}
+	return r
+}
+
+// processOTLPData validates and redacts a decoded OTLP payload of size bytes and sends it
+// to Kafka with tracing, each tenant's part to that tenant's topic. Retryable Kafka failures,
+// payloads rejected by validation and requests over the request or byte rate limits are
+// returned as errors; invalid items, items a tenant may not send, items over the item rate
+// limits and payloads Kafka will never accept are reported as rejected items in the export
+// result instead, as are the items of a tenant batch that fails with a retryable error after
+// another tenant's batch was sent. A payload rejected by validation is redacted in place, so
+// that the caller can dead-letter it. Requests are only charged to the rate limits once
+// validation and the tenant checks have accepted some of their items, and retryable failures
+// are refunded.
+func processOTLPData(ctx context.Context, tm *TelemetryManager, kafkaProducer *KafkaProducer, tenants *tenantRegistry, limiter *rateLimiter, validator *payloadValidator, redactor *payloadRedactor, signal otlpSignal, protocol string, payload otlp.Payload, size int) (exportResult, error) {
+	ctx, processSpan := tm.CreateSpan(ctx, fmt.Sprintf("otlp.%s.process", signal.name))
+	defer processSpan.End()
+
+	items := payload.ItemCount()
+	tm.metrics.recordReceived(ctx, signal.name, protocol, items)
+	processSpan.SetAttributes(attribute.Int64(fmt.Sprintf("otlp.%s.count", signal.itemsField), items))
+
+	tm.LogWithTraceContext(ctx, zap.InfoLevel, fmt.Sprintf("Received %s data", signal.name),
+		zap.Int64(signal.itemsField, items),
+		zap.String("signal_type", signal.name),
+		zap.String("protocol", protocol),
+	)
+
+	valid, result, err := validator.validate(ctx, signal, payload)
+	if err != nil {
+		processSpan.RecordError(err)
+		processSpan.SetStatus(codes.Error, fmt.Sprintf("Rejected invalid %s", signal.name))
+		var invalid *validationError
+		if errors.As(err, &invalid) {
+			redactor.redact(ctx, signal.name, payload)
+		}
+		return exportResult{}, err
+	}
+	if valid == nil {
+		return result, nil
+	}
+	payload = valid
+
+	// Tenants never share a Kafka message: each tenant's part is sent on its own
+	var admitted []tenantBatch
+	for _, batch := range tenants.route(ctx, payload) {
+		release, reason := tenants.admitBatch(batch, signal.name)
+		if reason != "" {
+			result = result.merge(rejectTenantBatch(ctx, tm, signal, batch, reason))
+			continue
+		}
+		defer release()
+		admitted = append(admitted, batch)
+	}
+	if len(admitted) == 0 {
+		return result, nil
+	}
+
+	refund, limitErr := limiter.allowRequest(ctx, signal.name, admitted, size)
+	if limitErr != nil {
+		processSpan.SetStatus(codes.Error, "Rate limit exceeded")
+		return exportResult{}, limitErr
+	}
+	sent := false
+	for _, batch := range admitted {
+		batchResult, err := sendTenantBatch(ctx, tm, kafkaProducer, limiter, redactor, signal, batch)
+		switch {
+		case err != nil && !sent:
+			// Nothing reached Kafka yet, so the client retries the whole request, which
+			// will be charged again
+			refund()
+			return exportResult{}, err
+		case err != nil:
+			// Retrying the request would duplicate the batches already in Kafka, so this
+			// tenant's items are reported as rejected instead
+			result = result.merge(exportResult{
+				rejected:     batch.payload.ItemCount(),
+				errorMessage: fmt.Sprintf("%s for tenant %q not sent to Kafka: %v", signal.name, batch.tenant, err),
+			})
+		default:
+			sent = true
+			result = result.merge(batchResult)
+		}
+	}
+
+	if result.rejected == 0 {
+		processSpan.SetStatus(codes.Ok, fmt.Sprintf("Sent %s to Kafka successfully", signal.name))
+	}
+	return result, nil
+}
+
+// rejectTenantBatch reports the items of a tenant's batch that the tenant may not send
+func rejectTenantBatch(ctx context.Context, tm *TelemetryManager, signal otlpSignal, batch tenantBatch, reason string) exportResult {
+	items := batch.payload.ItemCount()
+	trace.SpanFromContext(ctx).SetStatus(codes.Error, fmt.Sprintf("Rejected %s for tenant", signal.name))
+	tm.LogWithTraceContext(ctx, zap.WarnLevel, fmt.Sprintf("Rejected %s for tenant", signal.name),
+		zap.String("tenant", batch.tenant),
+		zap.String("reason", reason),
+		zap.Int64(signal.itemsField, items),
+	)
+	tm.metrics.recordRejected(ctx, signal.name, reason, items)
+	message := fmt.Sprintf("%s rejected: %s", signal.name, reason)
+	if batch.tenant != "" {
+		message = fmt.Sprintf("%s rejected for tenant %q: %s", signal.name, batch.tenant, reason)
+	}
+	return exportResult{rejected: items, errorMessage: message}
+}
+
+// sendTenantBatch redacts one tenant's part of a payload and sends it to Kafka, reporting items over the
+// item rate limits and items Kafka permanently rejected in the export result. The item
+// tokens of a batch that fails with a retryable error are refunded.
+func sendTenantBatch(ctx context.Context, tm *TelemetryManager, kafkaProducer *KafkaProducer, limiter *rateLimiter, redactor *payloadRedactor, signal otlpSignal, batch tenantBatch) (exportResult, error) {
+	items := batch.payload.ItemCount()
+	if batch.tenant != "" {
+		ctx = contextWithTenant(ctx, batch.tenant)
+	}
+
+	// Resources over the item rate limits are dropped and reported as partial success
+	var result exportResult
+	payload, throttled, refund := limiter.takeItems(ctx, signal.name, batch.tenant, batch.payload)
+	if throttled > 0 {
+		tm.LogWithTraceContext(ctx, zap.WarnLevel, fmt.Sprintf("Dropped %s over the rate limit", signal.name),
+			zap.String("tenant", batch.tenant),
+			zap.Int64(signal.itemsField, throttled),
+		)
+		tm.metrics.recordRejected(ctx, signal.name, rejectReasonRateLimited, throttled)
+		result = exportResult{
+			rejected:     throttled,
+			errorMessage: fmt.Sprintf("%d %s dropped by rate limits", throttled, signal.itemsField),
+		}
+		items -= throttled
+		if items == 0 {
+			return result, nil
+		}
+	}
+
+	// Redact last, so that tenancy and rate limits read the attributes as received
+	redactor.redact(ctx, signal.name, payload)
+
+	// Send data to Kafka
+	if err := kafkaProducer.sendPayload(ctx, signal, payload); err != nil {
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, fmt.Sprintf("Failed to send %s to Kafka", signal.name))
+		tm.LogWithTraceContext(ctx, zap.ErrorLevel, fmt.Sprintf("Failed to send %s to Kafka", signal.name), zap.Error(err))
+
+		if rejected, ok := rejectedKafkaItems(err, items); ok {
+			tm.metrics.recordRejected(ctx, signal.name, rejectReasonKafkaRejected, rejected)
+			return result.merge(exportResult{
+				rejected:     rejected,
+				errorMessage: fmt.Sprintf("%s rejected by Kafka: %v", signal.name, err),
+			}), nil
+		}
+		tm.metrics.recordRejected(ctx, signal.name, rejectReasonKafkaUnavailable, items)
+		refund()
+		return exportResult{}, err
+	}
+	return result, nil
+}
+
+// sendPayload converts a payload to Kafka records for the configured output mode and
+// sends them to the topic of the signal and tenant, tagged with the tenant
+func (kp *KafkaProducer) sendPayload(ctx context.Context, signal otlpSignal, payload otlp.Payload) error {
+	var records []kafkaRecord
+	if kp.config.Kafka.OutputMode == outputModeFlatten {
+		records = flattenPayload(payload, signal.partitionKey, time.Now())
+	} else {
+		records = splitPayload(payload, signal.partitionKey)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("kafka.messages", len(records)))
+
+	headers := map[string]string{
+		"signal_type":  signal.name,
+		"content_type": "application/json",
+	}
+	tenant := tenantFromContext(ctx)
+	if tenant != "" {
+		headers["tenant_id"] = tenant
+	}
+	return kp.SendRecordsWithTracing(ctx, kp.signalTopic(signal, tenant), records, headers)
+}
+
+// signalTopic returns the topic a tenant's signal is written to: the tenant's topic
+// override or the signal's topic, with the tenant substituted into templates
+func (kp *KafkaProducer) signalTopic(signal otlpSignal, tenant string) string {
+	tenancy := kp.config.Tenancy
+	if !tenancy.Enabled {
+		return signal.topic
+	}
+
+	topic := signal.topic
+	config, _ := lookupTenant(tenancy, tenant)
+	var override string
+	switch otlp.Signal(signal.name) {
+	case otlp.SignalTraces:
+		override = config.Topics.Traces
+	case otlp.SignalMetrics:
+		override = config.Topics.Metrics
+	case otlp.SignalLogs:
+		override = config.Topics.Logs
+	}
+	if override != "" {
+		topic = override
+	}
+	return expandTenantTopic(topic, tenant)
+}
+
+// rejectedKafkaItems returns how many items Kafka permanently rejected, and false if any
+// failure is retryable. Each failed message counts the items in its item_count header,
+// or one item for flattened records.
+func rejectedKafkaItems(err error, items int64) (int64, bool) {
+	var producerErrs sarama.ProducerErrors
+	if !errors.As(err, &producerErrs) {
+		return items, isPermanentKafkaError(err)
+	}
+	var rejected int64
+	for _, producerErr := range producerErrs {
+		if !isPermanentKafkaError(producerErr.Err) {
+			return 0, false
+		}
+		rejected += messageItemCount(producerErr.Msg)
+	}
+	return rejected, true
+}
+
+// messageItemCount returns the number of OTLP items carried by a Kafka message
+func messageItemCount(message *sarama.ProducerMessage) int64 {
+	if v := messageHeader(message, "item_count"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return 1
+}
+
+// messageHeader returns the value of a Kafka message header, or an empty string
+func messageHeader(message *sarama.ProducerMessage, key string) string {
+	for _, h := range message.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// isPermanentKafkaError reports whether retrying a failed send can never succeed
+func isPermanentKafkaError(err error) bool {
+	var marshalerErr *json.MarshalerError
+	var unsupportedTypeErr *json.UnsupportedTypeError
+	var unsupportedValueErr *json.UnsupportedValueError
+	return errors.Is(err, sarama.ErrMessageSizeTooLarge) ||
+		errors.Is(err, sarama.ErrInvalidMessage) ||
+		errors.As(err, &marshalerErr) ||
+		errors.As(err, &unsupportedTypeErr) ||
+		errors.As(err, &unsupportedValueErr)
+}
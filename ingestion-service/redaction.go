@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Redaction processor types
+const (
+	redactAllowKeys = "allow_keys"
+	redactDenyKeys  = "deny_keys"
+	redactMask      = "mask"
+	redactHash      = "hash"
+	redactTruncate  = "truncate"
+)
+
+// redactionProcessor is one step of the redaction chain. Its keys are path.Match patterns;
+// without keys, masking and truncation apply to every attribute and to log bodies.
+type redactionProcessor struct {
+	kind        string
+	keys        []string
+	pattern     *regexp.Regexp
+	replacement string
+	salt        []byte
+	maxLength   int
+}
+
+// payloadRedactor runs the configured processors over the resource, scope and item
+// attributes and log bodies of a payload. Payloads are redacted just before they are
+// published, after tenancy and rate limits have read the attributes as received.
+type payloadRedactor struct {
+	processors []redactionProcessor
+	applied    metric.Int64Counter
+}
+
+// newPayloadRedactor builds the redaction chain and registers its metrics. With redaction
+// disabled the chain is empty and payloads pass through untouched.
+func newPayloadRedactor(config *Config, tm *TelemetryManager) (*payloadRedactor, error) {
+	r := &payloadRedactor{}
+
+	var err error
+	r.applied, err = tm.GetMeter().Int64Counter("ingestion.redaction.applied",
+		metric.WithDescription("Attribute values and log bodies removed or rewritten by redaction, by signal and processor"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redaction counter: %w", err)
+	}
+	if !config.Redaction.Enabled {
+		return r, nil
+	}
+
+	for i, pc := range config.Redaction.Processors {
+		p := redactionProcessor{
+			kind:        pc.Type,
+			keys:        pc.Keys,
+			replacement: pc.Replacement,
+			salt:        []byte(pc.Salt),
+			maxLength:   pc.MaxLength,
+		}
+		if pc.Pattern != "" {
+			if p.pattern, err = regexp.Compile(pc.Pattern); err != nil {
+				return nil, fmt.Errorf("failed to compile redaction.processors[%d].pattern: %w", i, err)
+			}
+		}
+		r.processors = append(r.processors, p)
+	}
+	return r, nil
+}
+
+// redact applies the chain in place to every resource, scope, span, data point and log
+// record of a payload
+func (r *payloadRedactor) redact(ctx context.Context, signal string, payload otlp.Payload) {
+	if len(r.processors) == 0 {
+		return
+	}
+
+	counts := make([]int64, len(r.processors))
+	switch p := payload.(type) {
+	case *otlp.Traces:
+		for i := range p.ResourceSpans {
+			rs := &p.ResourceSpans[i]
+			rs.Resource.Attributes = r.attributes(rs.Resource.Attributes, counts)
+			for j := range rs.ScopeSpans {
+				rs.ScopeSpans[j].Scope.Attributes = r.attributes(rs.ScopeSpans[j].Scope.Attributes, counts)
+			}
+		}
+		forEachSpan(p, func(span *otlp.Span) {
+			span.Attributes = r.attributes(span.Attributes, counts)
+			for i := range span.Events {
+				span.Events[i].Attributes = r.attributes(span.Events[i].Attributes, counts)
+			}
+			for i := range span.Links {
+				span.Links[i].Attributes = r.attributes(span.Links[i].Attributes, counts)
+			}
+		})
+	case *otlp.Metrics:
+		for i := range p.ResourceMetrics {
+			rm := &p.ResourceMetrics[i]
+			rm.Resource.Attributes = r.attributes(rm.Resource.Attributes, counts)
+			for j := range rm.ScopeMetrics {
+				rm.ScopeMetrics[j].Scope.Attributes = r.attributes(rm.ScopeMetrics[j].Scope.Attributes, counts)
+			}
+		}
+		forEachMetric(p, func(m *otlp.Metric) {
+			r.dataPoints(m, counts)
+		})
+	case *otlp.Logs:
+		for i := range p.ResourceLogs {
+			rl := &p.ResourceLogs[i]
+			rl.Resource.Attributes = r.attributes(rl.Resource.Attributes, counts)
+			for j := range rl.ScopeLogs {
+				rl.ScopeLogs[j].Scope.Attributes = r.attributes(rl.ScopeLogs[j].Scope.Attributes, counts)
+			}
+		}
+		forEachLogRecord(p, func(record *otlp.LogRecord) {
+			record.Attributes = r.attributes(record.Attributes, counts)
+			if record.Body != nil {
+				r.body(record.Body, counts)
+			}
+		})
+	}
+
+	for i, n := range counts {
+		if n > 0 {
+			r.applied.Add(ctx, n, metric.WithAttributes(
+				attribute.String("signal", signal),
+				attribute.String("processor", r.processors[i].kind),
+			))
+		}
+	}
+}
+
+// dataPoints applies the chain to the attributes of a metric's data points and exemplars
+func (r *payloadRedactor) dataPoints(m *otlp.Metric, counts []int64) {
+	exemplars := func(exemplars []otlp.Exemplar) {
+		for i := range exemplars {
+			exemplars[i].FilteredAttributes = r.attributes(exemplars[i].FilteredAttributes, counts)
+		}
+	}
+	switch {
+	case m.Gauge != nil:
+		for i := range m.Gauge.DataPoints {
+			m.Gauge.DataPoints[i].Attributes = r.attributes(m.Gauge.DataPoints[i].Attributes, counts)
+			exemplars(m.Gauge.DataPoints[i].Exemplars)
+		}
+	case m.Sum != nil:
+		for i := range m.Sum.DataPoints {
+			m.Sum.DataPoints[i].Attributes = r.attributes(m.Sum.DataPoints[i].Attributes, counts)
+			exemplars(m.Sum.DataPoints[i].Exemplars)
+		}
+	case m.Histogram != nil:
+		for i := range m.Histogram.DataPoints {
+			m.Histogram.DataPoints[i].Attributes = r.attributes(m.Histogram.DataPoints[i].Attributes, counts)
+			exemplars(m.Histogram.DataPoints[i].Exemplars)
+		}
+	case m.ExponentialHistogram != nil:
+		for i := range m.ExponentialHistogram.DataPoints {
+			m.ExponentialHistogram.DataPoints[i].Attributes = r.attributes(m.ExponentialHistogram.DataPoints[i].Attributes, counts)
+			exemplars(m.ExponentialHistogram.DataPoints[i].Exemplars)
+		}
+	case m.Summary != nil:
+		for i := range m.Summary.DataPoints {
+			m.Summary.DataPoints[i].Attributes = r.attributes(m.Summary.DataPoints[i].Attributes, counts)
+		}
+	}
+}
+
+// attributes runs every processor over attrs, counting the values each one changed
+func (r *payloadRedactor) attributes(attrs []otlp.KeyValue, counts []int64) []otlp.KeyValue {
+	if len(attrs) == 0 {
+		return attrs
+	}
+	for i := range r.processors {
+		p := &r.processors[i]
+		switch p.kind {
+		case redactAllowKeys, redactDenyKeys:
+			kept := attrs[:0]
+			for _, kv := range attrs {
+				if p.matches(kv.Key) == (p.kind == redactAllowKeys) {
+					kept = append(kept, kv)
+				}
+			}
+			counts[i] += int64(len(attrs) - len(kept))
+			attrs = kept
+		default:
+			for j := range attrs {
+				if p.matches(attrs[j].Key) && p.rewrite(&attrs[j].Value) {
+					counts[i]++
+				}
+			}
+		}
+	}
+	return attrs
+}
+
+// body runs the chain over a log body. The fields of a key-value list body are redacted
+// like attributes, so every processor applies to them by key; other bodies have no key, so
+// only the masking and truncation processors without keys apply.
+func (r *payloadRedactor) body(body *otlp.AnyValue, counts []int64) {
+	if body.KvlistValue != nil {
+		body.KvlistValue.Values = r.attributes(body.KvlistValue.Values, counts)
+		return
+	}
+	for i := range r.processors {
+		p := &r.processors[i]
+		if (p.kind == redactMask || p.kind == redactTruncate) && len(p.keys) == 0 && p.rewrite(body) {
+			counts[i]++
+		}
+	}
+}
+
+// matches reports whether key matches one of the processor's key patterns; a processor
+// without keys matches every key
+func (p *redactionProcessor) matches(key string) bool {
+	if len(p.keys) == 0 {
+		return true
+	}
+	for _, pattern := range p.keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrite masks, hashes or truncates a value in place, reporting whether it changed.
+// Masking and truncation rewrite strings, including those nested in arrays and lists.
+func (p *redactionProcessor) rewrite(v *otlp.AnyValue) bool {
+	if p.kind == redactHash {
+		if v.StringValue == nil && v.BoolValue == nil && v.IntValue == nil && v.DoubleValue == nil &&
+			v.ArrayValue == nil && v.KvlistValue == nil && v.BytesValue == nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, p.salt)
+		mac.Write([]byte(v.AsString()))
+		*v = otlp.StringAnyValue(hex.EncodeToString(mac.Sum(nil)))
+		return true
+	}
+
+	changed := false
+	switch {
+	case v.StringValue != nil:
+		s := *v.StringValue
+		if p.kind == redactMask {
+			s = p.pattern.ReplaceAllString(s, p.replacement)
+		} else if len(s) > p.maxLength {
+			s = truncateUTF8(s, p.maxLength)
+		}
+		if s != *v.StringValue {
+			v.StringValue = &s
+			changed = true
+		}
+	case v.ArrayValue != nil:
+		for i := range v.ArrayValue.Values {
+			changed = p.rewrite(&v.ArrayValue.Values[i]) || changed
+		}
+	case v.KvlistValue != nil:
+		for i := range v.KvlistValue.Values {
+			changed = p.rewrite(&v.KvlistValue.Values[i].Value) || changed
+		}
+	}
+	return changed
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// forEachSpan calls fn with every span of a trace payload
+func forEachSpan(traces *otlp.Traces, fn func(*otlp.Span)) {
+	for i := range traces.ResourceSpans {
+		for j := range traces.ResourceSpans[i].ScopeSpans {
+			spans := traces.ResourceSpans[i].ScopeSpans[j].Spans
+			for k := range spans {
+				fn(&spans[k])
+			}
+		}
+	}
+}
+
+// forEachMetric calls fn with every metric of a metrics payload
+func forEachMetric(metrics *otlp.Metrics, fn func(*otlp.Metric)) {
+	for i := range metrics.ResourceMetrics {
+		for j := range metrics.ResourceMetrics[i].ScopeMetrics {
+			ms := metrics.ResourceMetrics[i].ScopeMetrics[j].Metrics
+			for k := range ms {
+				fn(&ms[k])
+			}
+		}
+	}
+}
+
+// forEachLogRecord calls fn with every log record of a logs payload
+func forEachLogRecord(logs *otlp.Logs, fn func(*otlp.LogRecord)) {
+	for i := range logs.ResourceLogs {
+		for j := range logs.ResourceLogs[i].ScopeLogs {
+			records := logs.ResourceLogs[i].ScopeLogs[j].LogRecords
+			for k := range records {
+				fn(&records[k])
+			}
+		}
+	}
+}
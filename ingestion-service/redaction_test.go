@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// newTestRedactor returns an enabled redactor running processors
+func newTestRedactor(t *testing.T, processors ...RedactionProcessorConfig) *payloadRedactor {
+	t.Helper()
+	config := &Config{Redaction: RedactionConfig{Enabled: true, Processors: processors}}
+	setDefaults(config)
+	if err := validateRedactionConfig(config.Redaction); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newPayloadRedactor(config, newTestTelemetryManager(t, config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// testHMAC returns the hex HMAC-SHA256 the hash processor writes for value
+func testHMAC(salt, value string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encodeAttributes renders attributes as OTLP/JSON for comparisons
+func encodeAttributes(t *testing.T, attrs []otlp.KeyValue) string {
+	t.Helper()
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRedactAttributes(t *testing.T) {
+	const attrs = `[
+		{"key":"http.request.header.authorization","value":{"stringValue":"Bearer secret"}},
+		{"key":"user.email","value":{"stringValue":"mail jane@example.com now"}},
+		{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"jane@example.com"},{"intValue":"1"}]}}},
+		{"key":"enduser.id","value":{"intValue":"42"}},
+		{"key":"note","value":{"stringValue":"héllo"}}
+	]`
+	const email = `[a-z]+@[a-z.]+`
+
+	tests := []struct {
+		name       string
+		processors []RedactionProcessorConfig
+		want       string
+		wantCounts []int64
+	}{
+		{
+			name:       "deny_keys removes matching keys",
+			processors: []RedactionProcessorConfig{{Type: redactDenyKeys, Keys: []string{"http.request.header.*", "tags"}}},
+			want:       `[{"key":"user.email","value":{"stringValue":"mail jane@example.com now"}},{"key":"enduser.id","value":{"intValue":"42"}},{"key":"note","value":{"stringValue":"héllo"}}]`,
+			wantCounts: []int64{2},
+		},
+		{
+			name:       "allow_keys keeps only matching keys",
+			processors: []RedactionProcessorConfig{{Type: redactAllowKeys, Keys: []string{"note"}}},
+			want:       `[{"key":"note","value":{"stringValue":"héllo"}}]`,
+			wantCounts: []int64{4},
+		},
+		{
+			name:       "mask without keys rewrites every string, including nested ones",
+			processors: []RedactionProcessorConfig{{Type: redactMask, Pattern: email}},
+			want: `[{"key":"http.request.header.authorization","value":{"stringValue":"Bearer secret"}},{"key":"user.email","value":{"stringValue":"mail **** now"}},` +
+				`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"****"},{"intValue":"1"}]}}},{"key":"enduser.id","value":{"intValue":"42"}},{"key":"note","value":{"stringValue":"héllo"}}]`,
+			wantCounts: []int64{2},
+		},
+		{
+			name:       "mask with keys and a replacement",
+			processors: []RedactionProcessorConfig{{Type: redactMask, Keys: []string{"http.request.header.*"}, Pattern: `secret`, Replacement: "?"}},
+			want: `[{"key":"http.request.header.authorization","value":{"stringValue":"Bearer ?"}},{"key":"user.email","value":{"stringValue":"mail jane@example.com now"}},` +
+				`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"jane@example.com"},{"intValue":"1"}]}}},{"key":"enduser.id","value":{"intValue":"42"}},{"key":"note","value":{"stringValue":"héllo"}}]`,
+			wantCounts: []int64{1},
+		},
+		{
+			name:       "hash replaces values of any type",
+			processors: []RedactionProcessorConfig{{Type: redactHash, Keys: []string{"enduser.id"}, Salt: "salt"}},
+			want: `[{"key":"http.request.header.authorization","value":{"stringValue":"Bearer secret"}},{"key":"user.email","value":{"stringValue":"mail jane@example.com now"}},` +
+				`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"jane@example.com"},{"intValue":"1"}]}}},{"key":"enduser.id","value":{"stringValue":"` + testHMAC("salt", "42") + `"}},{"key":"note","value":{"stringValue":"héllo"}}]`,
+			wantCounts: []int64{1},
+		},
+		{
+			name:       "truncate does not split UTF-8 sequences",
+			processors: []RedactionProcessorConfig{{Type: redactTruncate, Keys: []string{"note"}, MaxLength: 2}},
+			want: `[{"key":"http.request.header.authorization","value":{"stringValue":"Bearer secret"}},{"key":"user.email","value":{"stringValue":"mail jane@example.com now"}},` +
+				`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"jane@example.com"},{"intValue":"1"}]}}},{"key":"enduser.id","value":{"intValue":"42"}},{"key":"note","value":{"stringValue":"h"}}]`,
+			wantCounts: []int64{1},
+		},
+		{
+			name: "processors run in order",
+			processors: []RedactionProcessorConfig{
+				{Type: redactAllowKeys, Keys: []string{"user.*"}},
+				{Type: redactMask, Pattern: email},
+				{Type: redactTruncate, MaxLength: 4},
+			},
+			want:       `[{"key":"user.email","value":{"stringValue":"mail"}}]`,
+			wantCounts: []int64{4, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedactor(t, tt.processors...)
+			var input []otlp.KeyValue
+			if err := json.Unmarshal([]byte(attrs), &input); err != nil {
+				t.Fatal(err)
+			}
+			counts := make([]int64, len(r.processors))
+			if got := encodeAttributes(t, r.attributes(input, counts)); got != tt.want {
+				t.Errorf("attributes() =\n%s\nwant\n%s", got, tt.want)
+			}
+			for i, want := range tt.wantCounts {
+				if counts[i] != want {
+					t.Errorf("processor %d changed %d values, want %d", i, counts[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	processors := []RedactionProcessorConfig{
+		{Type: redactDenyKeys, Keys: []string{"secret"}},
+		{Type: redactMask, Pattern: `[a-z]+@[a-z.]+`},
+		{Type: redactHash, Keys: []string{"user"}, Salt: "salt"},
+	}
+	// Every attribute list carries the same three keys: secret is removed, email is masked
+	// and user is hashed
+	const attrs = `"attributes":[{"key":"secret","value":{"stringValue":"s"}},{"key":"email","value":{"stringValue":"jane@example.com"}},{"key":"user","value":{"stringValue":"jane"}}]`
+	want := `[{"key":"email","value":{"stringValue":"****"}},{"key":"user","value":{"stringValue":"` + testHMAC("salt", "jane") + `"}}]`
+
+	tests := []struct {
+		name     string
+		signal   otlp.Signal
+		body     string
+		attrSets func(payload otlp.Payload) [][]otlp.KeyValue
+	}{
+		{
+			name:   "traces",
+			signal: otlp.SignalTraces,
+			body: `{"resourceSpans":[{"resource":{` + attrs + `},"scopeSpans":[{"scope":{` + attrs + `},"spans":[{` + testTraceID + `,` + testSpanID + `,` + attrs +
+				`,"events":[{"name":"e",` + attrs + `}],"links":[{` + testTraceID + `,` + testSpanID + `,` + attrs + `}]}]}]}]}`,
+			attrSets: func(payload otlp.Payload) [][]otlp.KeyValue {
+				rs := payload.(*otlp.Traces).ResourceSpans[0]
+				span := rs.ScopeSpans[0].Spans[0]
+				return [][]otlp.KeyValue{rs.Resource.Attributes, rs.ScopeSpans[0].Scope.Attributes, span.Attributes, span.Events[0].Attributes, span.Links[0].Attributes}
+			},
+		},
+		{
+			name:   "metrics",
+			signal: otlp.SignalMetrics,
+			body: `{"resourceMetrics":[{"resource":{` + attrs + `},"scopeMetrics":[{"scope":{` + attrs + `},"metrics":[{"name":"m","gauge":{"dataPoints":[{"asInt":"1",` + attrs +
+				`,"exemplars":[{"asInt":"1","filteredAttributes":[{"key":"secret","value":{"stringValue":"s"}},{"key":"email","value":{"stringValue":"jane@example.com"}},{"key":"user","value":{"stringValue":"jane"}}]}]}]}}]}]}]}`,
+			attrSets: func(payload otlp.Payload) [][]otlp.KeyValue {
+				rm := payload.(*otlp.Metrics).ResourceMetrics[0]
+				dp := rm.ScopeMetrics[0].Metrics[0].Gauge.DataPoints[0]
+				return [][]otlp.KeyValue{rm.Resource.Attributes, rm.ScopeMetrics[0].Scope.Attributes, dp.Attributes, dp.Exemplars[0].FilteredAttributes}
+			},
+		},
+		{
+			name:   "logs",
+			signal: otlp.SignalLogs,
+			body: `{"resourceLogs":[{"resource":{` + attrs + `},"scopeLogs":[{"scope":{` + attrs + `},"logRecords":[{` + attrs +
+				`,"body":{"kvlistValue":{"values":[{"key":"secret","value":{"stringValue":"s"}},{"key":"email","value":{"stringValue":"jane@example.com"}},{"key":"user","value":{"stringValue":"jane"}}]}}}]}]}]}`,
+			attrSets: func(payload otlp.Payload) [][]otlp.KeyValue {
+				rl := payload.(*otlp.Logs).ResourceLogs[0]
+				record := rl.ScopeLogs[0].LogRecords[0]
+				return [][]otlp.KeyValue{rl.Resource.Attributes, rl.ScopeLogs[0].Scope.Attributes, record.Attributes, record.Body.KvlistValue.Values}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedactor(t, processors...)
+			payload := decodeTestPayload(t, tt.signal, tt.body)
+			r.redact(context.Background(), string(tt.signal), payload)
+			for i, attrs := range tt.attrSets(payload) {
+				if got := encodeAttributes(t, attrs); got != want {
+					t.Errorf("attribute set %d =\n%s\nwant\n%s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactLogBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors []RedactionProcessorConfig
+		body       string
+		want       string
+	}{
+		{
+			name:       "mask without keys rewrites a string body",
+			processors: []RedactionProcessorConfig{{Type: redactMask, Pattern: `\d+`}},
+			body:       `{"stringValue":"card 4111"}`,
+			want:       `{"stringValue":"card ****"}`,
+		},
+		{
+			name:       "truncate without keys cuts a string body",
+			processors: []RedactionProcessorConfig{{Type: redactTruncate, MaxLength: 4}},
+			body:       `{"stringValue":"card 4111"}`,
+			want:       `{"stringValue":"card"}`,
+		},
+		{
+			name:       "keyed processors leave a string body alone",
+			processors: []RedactionProcessorConfig{{Type: redactMask, Keys: []string{"body"}, Pattern: `\d+`}, {Type: redactHash, Keys: []string{"*"}, Salt: "salt"}},
+			body:       `{"stringValue":"card 4111"}`,
+			want:       `{"stringValue":"card 4111"}`,
+		},
+		{
+			name:       "mask without keys rewrites strings nested in an array body",
+			processors: []RedactionProcessorConfig{{Type: redactMask, Pattern: `\d+`}},
+			body:       `{"arrayValue":{"values":[{"stringValue":"4111"}]}}`,
+			want:       `{"arrayValue":{"values":[{"stringValue":"****"}]}}`,
+		},
+		{
+			name:       "deny_keys removes key-value list body fields",
+			processors: []RedactionProcessorConfig{{Type: redactDenyKeys, Keys: []string{"password"}}},
+			body:       `{"kvlistValue":{"values":[{"key":"password","value":{"stringValue":"p"}},{"key":"msg","value":{"stringValue":"hi"}}]}}`,
+			want:       `{"kvlistValue":{"values":[{"key":"msg","value":{"stringValue":"hi"}}]}}`,
+		},
+		{
+			name:       "hash rewrites key-value list body fields by key",
+			processors: []RedactionProcessorConfig{{Type: redactHash, Keys: []string{"user"}, Salt: "salt"}},
+			body:       `{"kvlistValue":{"values":[{"key":"user","value":{"stringValue":"jane"}}]}}`,
+			want:       `{"kvlistValue":{"values":[{"key":"user","value":{"stringValue":"` + testHMAC("salt", "jane") + `"}}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedactor(t, tt.processors...)
+			payload := decodeTestPayload(t, otlp.SignalLogs, `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":`+tt.body+`}]}]}]}`)
+			r.redact(context.Background(), string(otlp.SignalLogs), payload)
+			got, err := json.Marshal(payload.(*otlp.Logs).ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactionDisabled(t *testing.T) {
+	config := &Config{Redaction: RedactionConfig{Processors: []RedactionProcessorConfig{{Type: redactDenyKeys, Keys: []string{"*"}}}}}
+	setDefaults(config)
+	r, err := newPayloadRedactor(config, newTestTelemetryManager(t, config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := testTraces(t, []string{"svc"}, 1)
+	r.redact(context.Background(), string(otlp.SignalTraces), payload)
+	if got := payload.(*otlp.Traces).ResourceSpans[0].Resource.ServiceName(); got != "svc" {
+		t.Errorf("disabled redaction changed service.name to %q", got)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "hello", n: 3, want: "hel"},
+		{s: "héllo", n: 2, want: "h"},
+		{s: "héllo", n: 3, want: "hé"},
+		{s: "日本", n: 5, want: "日"},
+		{s: "日本", n: 2, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.s, tt.n); got != tt.want || !strings.HasPrefix(tt.s, got) {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// suppressedTracer creates the non-recording spans used while exporting self-telemetry
+var suppressedTracer = noop.NewTracerProvider().Tracer("")
+
+// telemetrySuppressedKey is the context key marking work done to export the service's
+// own telemetry, which must not produce more telemetry to export
+type telemetrySuppressedKey struct{}
+
+// withoutTelemetry returns a copy of ctx under which CreateSpan records nothing
+func withoutTelemetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, telemetrySuppressedKey{}, true)
+}
+
+// telemetrySuppressed reports whether ctx was returned by withoutTelemetry
+func telemetrySuppressed(ctx context.Context) bool {
+	suppressed, _ := ctx.Value(telemetrySuppressedKey{}).(bool)
+	return suppressed
+}
+
+// resourceToProto converts an SDK resource to its OTLP form
+func resourceToProto(res *resource.Resource) *resourcepb.Resource {
+	if res == nil {
+		return &resourcepb.Resource{}
+	}
+	return &resourcepb.Resource{Attributes: attributesToProto(res.Attributes())}
+}
+
+// scopeToProto converts an instrumentation scope to its OTLP form
+func scopeToProto(scope instrumentation.Scope) *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: scope.Name, Version: scope.Version}
+}
+
+// attributesToProto converts attributes to OTLP key-values
+func attributesToProto(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: attributeValueToProto(kv.Value)})
+	}
+	return out
+}
+
+// attributeValueToProto converts an attribute value to an OTLP AnyValue
+func attributeValueToProto(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.BOOLSLICE:
+		values := make([]*commonpb.AnyValue, 0, len(v.AsBoolSlice()))
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: b}})
+		}
+		return arrayValueToProto(values)
+	case attribute.INT64SLICE:
+		values := make([]*commonpb.AnyValue, 0, len(v.AsInt64Slice()))
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}})
+		}
+		return arrayValueToProto(values)
+	case attribute.FLOAT64SLICE:
+		values := make([]*commonpb.AnyValue, 0, len(v.AsFloat64Slice()))
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: f}})
+		}
+		return arrayValueToProto(values)
+	case attribute.STRINGSLICE:
+		values := make([]*commonpb.AnyValue, 0, len(v.AsStringSlice()))
+		for _, s := range v.AsStringSlice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}})
+		}
+		return arrayValueToProto(values)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+	}
+}
+
+// arrayValueToProto wraps values in an OTLP array AnyValue
+func arrayValueToProto(values []*commonpb.AnyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+// spanContextIDs returns the trace and span IDs of a span context as OTLP byte slices
+func spanContextIDs(sc trace.SpanContext) ([]byte, []byte) {
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+	return traceID[:], spanID[:]
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Client certificate policies for TLS listeners
+const (
+	clientAuthNone     = "none"
+	clientAuthOptional = "optional"
+	clientAuthRequire  = "require"
+)
+
+// Cipher suite policies for TLS listeners
+const (
+	cipherPolicyDefault = "default"
+	cipherPolicyModern  = "modern"
+)
+
+// modernCipherSuites are the TLS 1.2 suites allowed by the modern policy: forward-secret
+// key exchange with AEAD ciphers only. TLS 1.3 suites are not configurable and always allowed.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsVersions maps the configurable minimum versions to their protocol constants
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsReloader serves a listener's TLS configuration, reloading the certificate, key and
+// client CA from disk when their files change so that rotated certificates are picked up
+// without a restart. A reload that fails keeps the previous configuration.
+type tlsReloader struct {
+	name       string
+	config     ServerTLSConfig
+	nextProtos []string
+	logger     *zap.Logger
+
+	current  atomic.Pointer[tls.Config]
+	modTimes map[string]time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// newTLSReloader loads a listener's TLS configuration and starts watching its files.
+// It returns nil if TLS is disabled for the listener. nextProtos lists the ALPN protocols
+// the listener serves.
+func newTLSReloader(name string, config ServerTLSConfig, nextProtos []string, logger *zap.Logger) (*tlsReloader, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	r := &tlsReloader{
+		name:       name,
+		config:     config,
+		nextProtos: nextProtos,
+		logger:     logger,
+		stop:       make(chan struct{}),
+	}
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s TLS configuration: %w", name, err)
+	}
+	tlsConfig, err := r.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s TLS configuration: %w", name, err)
+	}
+	r.current.Store(tlsConfig)
+	r.modTimes = modTimes
+
+	go r.watch()
+	return r, nil
+}
+
+// TLSConfig returns a configuration that resolves to the latest loaded one on every handshake
+func (r *tlsReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.current.Load().MinVersion,
+		NextProtos: r.nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.current.Load().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Close stops watching the certificate files
+func (r *tlsReloader) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// watch polls the certificate files and reloads the configuration when any of them changes
+func (r *tlsReloader) watch() {
+	ticker := time.NewTicker(r.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			modTimes, err := r.fileModTimes()
+			if err != nil {
+				r.logger.Warn("Failed to check TLS certificate files", zap.String("listener", r.name), zap.Error(err))
+				continue
+			}
+			if !r.changed(modTimes) {
+				continue
+			}
+			tlsConfig, err := r.load()
+			if err != nil {
+				r.logger.Error("Failed to reload TLS certificate, keeping the previous one", zap.String("listener", r.name), zap.Error(err))
+				continue
+			}
+			r.current.Store(tlsConfig)
+			r.modTimes = modTimes
+			r.logger.Info("Reloaded TLS certificate", zap.String("listener", r.name))
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// changed reports whether any file modification time differs from the loaded files
+func (r *tlsReloader) changed(modTimes map[string]time.Time) bool {
+	for path, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileModTimes returns the modification time of every file the configuration is built from
+func (r *tlsReloader) fileModTimes() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+	for _, path := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// load builds the TLS configuration from the files on disk
+func (r *tlsReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tlsVersions[r.config.MinVersion],
+		NextProtos:   r.nextProtos,
+	}
+	if r.config.CipherPolicy == cipherPolicyModern {
+		tlsConfig.CipherSuites = modernCipherSuites
+	}
+
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("failed to parse client CA file: no PEM certificates found")
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	switch r.config.ClientAuth {
+	case clientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case clientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		tlsConfig.ClientAuth = tls.NoClientCert
+	}
+	return tlsConfig, nil
+}
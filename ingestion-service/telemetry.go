@@ -3,21 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
@@ -39,7 +44,15 @@ func (e *noopExporter) Shutdown(ctx context.Context) error {
 // noopMetricExporter is a no-op metric exporter for when metrics are disabled
 type noopMetricExporter struct{}
 
-func (e *noopMetricExporter) Export(ctx context.Context, metrics *sdkmetric.ResourceMetrics) error {
+func (e *noopMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (e *noopMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *noopMetricExporter) Export(ctx context.Context, metrics *metricdata.ResourceMetrics) error {
 	return nil
 }
 
@@ -55,18 +68,30 @@ func (e *noopMetricExporter) Shutdown(ctx context.Context) error {
 type TelemetryManager struct {
 	config         *Config
 	logger         *zap.Logger
+	localLogger    *zap.Logger
 	tracerProvider *sdktrace.TracerProvider
 	tracer         trace.Tracer
 	meterProvider  *sdkmetric.MeterProvider
 	meter          metric.Meter
+	promRegistry   *prometheus.Registry
+	metrics        *ingestionMetrics
+	spanExporter   *kafkaSpanExporter
+	metricExporter *kafkaMetricExporter
+	logExporter    *kafkaLogExporter
 }
 
 // NewTelemetryManager creates a new TelemetryManager
 func NewTelemetryManager(config *Config, logger *zap.Logger) (*TelemetryManager, error) {
 	tm := &TelemetryManager{
-		config: config,
-		logger: logger,
+		config:       config,
+		logger:       logger,
+		localLogger:  logger,
+		promRegistry: prometheus.NewRegistry(),
 	}
+	tm.promRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
 
 	// Initialize tracer provider
 	if err := tm.initTracerProvider(); err != nil {
@@ -78,6 +103,11 @@ func NewTelemetryManager(config *Config, logger *zap.Logger) (*TelemetryManager,
 		return nil, fmt.Errorf("failed to initialize meter provider: %w", err)
 	}
 
+	// Initialize log exporter
+	if err := tm.initLogExporter(); err != nil {
+		return nil, fmt.Errorf("failed to initialize log exporter: %w", err)
+	}
+
 	// Set global providers
 	otel.SetTracerProvider(tm.tracerProvider)
 	otel.SetMeterProvider(tm.meterProvider)
@@ -89,6 +119,12 @@ func NewTelemetryManager(config *Config, logger *zap.Logger) (*TelemetryManager,
 	tm.tracer = otel.Tracer(config.OpenTelemetry.ServiceName)
 	tm.meter = otel.Meter(config.OpenTelemetry.ServiceName)
 
+	metrics, err := newIngestionMetrics(tm.meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ingestion metrics: %w", err)
+	}
+	tm.metrics = metrics
+
 	logger.Info("OpenTelemetry initialized successfully",
 		zap.String("service_name", config.OpenTelemetry.ServiceName),
 		zap.String("exporter_type", config.OpenTelemetry.Tracing.Exporter),
@@ -114,12 +150,9 @@ func (tm *TelemetryManager) initTracerProvider() error {
 	case "otlp":
 		exporter, err = tm.createOTLPExporter()
 	case "kafka":
-		// For now, use console exporter and send to Kafka separately
-		// TODO: Implement direct Kafka exporter
-		exporter, err = stdouttrace.New(
-			stdouttrace.WithWriter(os.Stdout),
-			stdouttrace.WithPrettyPrint(),
-		)
+		// Spans are sent once the Kafka producer is attached with AttachKafkaProducer
+		tm.spanExporter = newKafkaSpanExporter(tm.config)
+		exporter = tm.spanExporter
 	case "none":
 		// No-op exporter for when tracing is disabled
 		exporter = &noopExporter{}
@@ -163,20 +196,23 @@ func (tm *TelemetryManager) createOTLPExporter() (sdktrace.SpanExporter, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	otlpConfig := tm.config.OpenTelemetry.Tracing.OTLP
 	var client otlptrace.Client
-	switch tm.config.OpenTelemetry.Tracing.OTLP.Protocol {
+	switch otlpConfig.Protocol {
 	case "grpc":
-		client = otlptracegrpc.NewClient(
-			otlptracegrpc.WithEndpoint(tm.config.OpenTelemetry.Tracing.OTLP.Endpoint),
-			otlptracegrpc.WithInsecure(),
-		)
+		opts, err := otlpTraceGRPCOptions(otlpConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure OTLP exporter: %w", err)
+		}
+		client = otlptracegrpc.NewClient(opts...)
 	case "http":
-		client = otlptracehttp.NewClient(
-			otlptracehttp.WithEndpoint(tm.config.OpenTelemetry.Tracing.OTLP.Endpoint),
-			otlptracehttp.WithInsecure(),
-		)
+		opts, err := otlpTraceHTTPOptions(otlpConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure OTLP exporter: %w", err)
+		}
+		client = otlptracehttp.NewClient(opts...)
 	default:
-		return nil, fmt.Errorf("unsupported OTLP protocol: %s", tm.config.OpenTelemetry.Tracing.OTLP.Protocol)
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s", otlpConfig.Protocol)
 	}
 
 	exporter, err := otlptrace.New(ctx, client)
@@ -205,9 +241,8 @@ func (tm *TelemetryManager) initMeterProvider() error {
 	case "otlp":
 		exporter, err = tm.createOTLPMetricExporter()
 	case "kafka":
-		// For now, use no-op exporter and send to Kafka separately
-		// TODO: Implement direct Kafka metric exporter
-		exporter = &noopMetricExporter{}
+		tm.metricExporter = newKafkaMetricExporter(tm.config)
+		exporter = tm.metricExporter
 	case "none":
 		exporter = &noopMetricExporter{}
 	default:
@@ -218,9 +253,16 @@ func (tm *TelemetryManager) initMeterProvider() error {
 		return fmt.Errorf("failed to create metric exporter: %w", err)
 	}
 
+	// Metrics are pushed by the configured exporter and also served for Prometheus to scrape
+	promExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(tm.promRegistry))
+	if err != nil {
+		return fmt.Errorf("failed to create Prometheus exporter: %w", err)
+	}
+
 	tm.meterProvider = sdkmetric.NewMeterProvider(
 		sdkmetric.WithResource(res),
 		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(tm.config.OpenTelemetry.Metrics.Interval))),
+		sdkmetric.WithReader(promExporter),
 	)
 
 	return nil
@@ -231,28 +273,59 @@ func (tm *TelemetryManager) createOTLPMetricExporter() (sdkmetric.Exporter, erro
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var client otlpmetric.Client
-	switch tm.config.OpenTelemetry.Metrics.OTLP.Protocol {
+	otlpConfig := tm.config.OpenTelemetry.Metrics.OTLP
+	temporality := metricsTemporalitySelector(tm.config.OpenTelemetry.Metrics.Temporality)
+	switch otlpConfig.Protocol {
 	case "grpc":
-		client = otlpmetricgrpc.NewClient(
-			otlpmetricgrpc.WithEndpoint(tm.config.OpenTelemetry.Metrics.OTLP.Endpoint),
-			otlpmetricgrpc.WithInsecure(),
-		)
+		opts, err := otlpMetricGRPCOptions(otlpConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure OTLP metric exporter: %w", err)
+		}
+		exporter, err := otlpmetricgrpc.New(ctx, append(opts, otlpmetricgrpc.WithTemporalitySelector(temporality))...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		return exporter, nil
 	case "http":
-		client = otlpmetrichttp.NewClient(
-			otlpmetrichttp.WithEndpoint(tm.config.OpenTelemetry.Metrics.OTLP.Endpoint),
-			otlpmetrichttp.WithInsecure(),
-		)
+		opts, err := otlpMetricHTTPOptions(otlpConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure OTLP metric exporter: %w", err)
+		}
+		exporter, err := otlpmetrichttp.New(ctx, append(opts, otlpmetrichttp.WithTemporalitySelector(temporality))...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		return exporter, nil
 	default:
-		return nil, fmt.Errorf("unsupported OTLP protocol: %s", tm.config.OpenTelemetry.Metrics.OTLP.Protocol)
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s", otlpConfig.Protocol)
 	}
+}
 
-	exporter, err := otlpmetric.New(ctx, client)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+// initLogExporter tees the service logger into the configured log exporter
+func (tm *TelemetryManager) initLogExporter() error {
+	if !tm.config.OpenTelemetry.Logs.Enabled {
+		return nil
 	}
 
-	return exporter, nil
+	switch tm.config.OpenTelemetry.Logs.Exporter {
+	case "kafka":
+		res, err := tm.createResource()
+		if err != nil {
+			return fmt.Errorf("failed to create resource: %w", err)
+		}
+		exporter, err := newKafkaLogExporter(tm.config, res, tm.localLogger)
+		if err != nil {
+			return err
+		}
+		tm.logExporter = exporter
+		tm.logger = tm.localLogger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, exporter.core())
+		}))
+	case "none":
+	default:
+		return fmt.Errorf("unsupported logs exporter type: %s", tm.config.OpenTelemetry.Logs.Exporter)
+	}
+	return nil
 }
 
 // createResource creates the OpenTelemetry resource
@@ -274,7 +347,7 @@ func (tm *TelemetryManager) createResource() (*resource.Resource, error) {
 	return res, err
 }
 
-// Shutdown shuts down the tracer and meter providers
+// Shutdown shuts down the tracer and meter providers and the log exporter
 func (tm *TelemetryManager) Shutdown(ctx context.Context) {
 	if tm.tracerProvider != nil {
 		if err := tm.tracerProvider.Shutdown(ctx); err != nil {
@@ -286,13 +359,55 @@ func (tm *TelemetryManager) Shutdown(ctx context.Context) {
 			tm.logger.Error("Failed to shutdown meter provider", zap.Error(err))
 		}
 	}
+	if tm.logExporter != nil {
+		if err := tm.logExporter.Shutdown(ctx); err != nil {
+			tm.localLogger.Error("Failed to shutdown log exporter", zap.Error(err))
+		}
+	}
 }
 
-// CreateSpan creates a new span
+// CreateSpan creates a new span, or a non-recording one while exporting self-telemetry
 func (tm *TelemetryManager) CreateSpan(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if telemetrySuppressed(ctx) {
+		return suppressedTracer.Start(ctx, spanName, opts...)
+	}
 	return tm.tracer.Start(ctx, spanName, opts...)
 }
 
+// AttachKafkaProducer gives the Kafka exporters the producer to send self-telemetry through
+func (tm *TelemetryManager) AttachKafkaProducer(kafkaProducer *KafkaProducer) {
+	if tm.spanExporter != nil {
+		tm.spanExporter.attach(kafkaProducer)
+	}
+	if tm.metricExporter != nil {
+		tm.metricExporter.attach(kafkaProducer)
+	}
+	if tm.logExporter != nil {
+		tm.logExporter.attach(kafkaProducer)
+	}
+}
+
+// ForceFlush exports buffered spans, metrics and logs, so that self-telemetry sent through Kafka
+// goes out before the producer is closed
+func (tm *TelemetryManager) ForceFlush(ctx context.Context) {
+	if err := tm.tracerProvider.ForceFlush(ctx); err != nil {
+		tm.logger.Error("Failed to flush tracer provider", zap.Error(err))
+	}
+	if err := tm.meterProvider.ForceFlush(ctx); err != nil {
+		tm.logger.Error("Failed to flush meter provider", zap.Error(err))
+	}
+	if tm.logExporter != nil {
+		if err := tm.logExporter.ForceFlush(ctx); err != nil {
+			tm.localLogger.Error("Failed to flush log exporter", zap.Error(err))
+		}
+	}
+}
+
+// Logger returns the service logger, which also feeds the configured log exporter
+func (tm *TelemetryManager) Logger() *zap.Logger {
+	return tm.logger
+}
+
 // GetMeter returns the meter for creating metrics
 func (tm *TelemetryManager) GetMeter() metric.Meter {
 	return tm.meter
@@ -311,25 +426,29 @@ func (tm *TelemetryManager) LogWithTraceContext(ctx context.Context, level zapco
 		}
 	}
 
+	// Logs about exporting self-telemetry are not exported themselves
+	logger := tm.logger
+	if telemetrySuppressed(ctx) {
+		logger = tm.localLogger
+	}
+
 	switch level {
 	case zap.DebugLevel:
-		tm.logger.Debug(msg, fields...)
+		logger.Debug(msg, fields...)
 	case zap.InfoLevel:
-		tm.logger.Info(msg, fields...)
+		logger.Info(msg, fields...)
 	case zap.WarnLevel:
-		tm.logger.Warn(msg, fields...)
+		logger.Warn(msg, fields...)
 	case zap.ErrorLevel:
-		tm.logger.Error(msg, fields...)
+		logger.Error(msg, fields...)
 	case zap.FatalLevel:
-		tm.logger.Fatal(msg, fields...)
+		logger.Fatal(msg, fields...)
 	default:
-		tm.logger.Info(msg, fields...)
+		logger.Info(msg, fields...)
 	}
 }
 
-// StartTelemetryServer starts a simple HTTP server for exposing OpenTelemetry metrics (if needed)
-func (tm *TelemetryManager) StartTelemetryServer() {
-	// Currently, this is a placeholder. Prometheus exporter for metrics can be added here.
-	// For now, the OTel Collector handles receiving metrics.
-	tm.logger.Info("Telemetry server placeholder started")
+// MetricsHandler returns the handler serving the service's metrics in the Prometheus format
+func (tm *TelemetryManager) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(tm.promRegistry, promhttp.HandlerOpts{})
 }
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// tenantPlaceholder is replaced by the tenant ID in topic templates such as otel.{tenant}.traces
+const tenantPlaceholder = "{tenant}"
+
+// tenantUnknown is substituted into dead-letter topic templates for payloads whose tenant
+// could not be determined, such as request bodies that failed to decode
+const tenantUnknown = "unknown"
+
+// Reasons reported on the ingestion.tenant.rejected metric. Items refused after a request
+// was admitted are reported on ingestion.items.rejected with the same reasons.
+const (
+	tenantRejectMissing        = "tenant_missing"
+	tenantRejectInvalid        = "tenant_invalid"
+	tenantRejectUnknown        = "tenant_unknown"
+	tenantRejectMismatch       = "tenant_mismatch"
+	tenantRejectQuota          = "tenant_quota_exceeded"
+	tenantRejectSignalDisabled = "signal_disabled"
+)
+
+// tenantIDPattern restricts tenant IDs to characters that are valid in Kafka topic names
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// tenantContextKey is the context key for the tenant that sent a request
+type tenantContextKey struct{}
+
+// contextWithTenant returns a copy of ctx carrying the tenant that sent the request
+func contextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant that sent the request, or an empty string if unknown
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// validTenantID reports whether id can be used as a tenant ID
+func validTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+// lookupTenant returns the settings of a tenant, falling back to the defaults for tenants
+// that are not listed. The second result reports whether the tenant is listed.
+func lookupTenant(config TenancyConfig, id string) (TenantConfig, bool) {
+	for _, tenant := range config.Tenants {
+		if tenant.ID == id {
+			return tenant, true
+		}
+	}
+	return config.Defaults, false
+}
+
+// expandTenantTopic substitutes the tenant into a topic template
+func expandTenantTopic(topic, tenant string) string {
+	if tenant == "" {
+		tenant = tenantUnknown
+	}
+	return strings.ReplaceAll(topic, tenantPlaceholder, tenant)
+}
+
+// signalTopics returns the signal topics known from the configuration. Topic templates
+// are expanded for every listed tenant and the default tenant, since other tenants'
+// topics are only known once they send data.
+func signalTopics(config *Config) []string {
+	templates := []string{config.Kafka.Topics.Traces, config.Kafka.Topics.Metrics, config.Kafka.Topics.Logs}
+	if !config.Tenancy.Enabled {
+		return templates
+	}
+
+	tenants := make([]TenantConfig, 0, len(config.Tenancy.Tenants)+1)
+	tenants = append(tenants, config.Tenancy.Tenants...)
+	if config.Tenancy.DefaultTenant != "" {
+		defaults, _ := lookupTenant(config.Tenancy, config.Tenancy.DefaultTenant)
+		defaults.ID = config.Tenancy.DefaultTenant
+		tenants = append(tenants, defaults)
+	}
+
+	var topics []string
+	seen := make(map[string]bool)
+	add := func(topic string) {
+		if !strings.Contains(topic, tenantPlaceholder) && !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+	for _, template := range templates {
+		add(template)
+	}
+	for _, tenant := range tenants {
+		overrides := []string{tenant.Topics.Traces, tenant.Topics.Metrics, tenant.Topics.Logs}
+		for i, template := range templates {
+			if overrides[i] != "" {
+				template = overrides[i]
+			}
+			add(expandTenantTopic(template, tenant.ID))
+		}
+	}
+	return topics
+}
+
+// tenantBatch is the part of an export request that belongs to one tenant. perResource
+// marks batches attributed from the tenant resource attribute, whose tenant was not known
+// when the request was admitted.
+type tenantBatch struct {
+	tenant      string
+	payload     otlp.Payload
+	perResource bool
+}
+
+// tenantRegistry attributes OTLP requests to tenants and enforces each tenant's enabled
+// signals and quotas. A request's tenant comes from its authenticated credential or, failing
+// that, the tenant header; requests without either are attributed per resource from the
+// tenant resource attribute, falling back to the default tenant.
+type tenantRegistry struct {
+	config     TenancyConfig
+	retryAfter time.Duration
+	rejected   metric.Int64Counter
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// newTenantRegistry creates the tenant registry for the configured tenants and registers
+// its metrics
+func newTenantRegistry(config *Config, tm *TelemetryManager) (*tenantRegistry, error) {
+	r := &tenantRegistry{
+		config:     config.Tenancy,
+		retryAfter: config.Server.RetryAfter,
+		inFlight:   make(map[string]int),
+	}
+
+	var err error
+	r.rejected, err = tm.GetMeter().Int64Counter("ingestion.tenant.rejected",
+		metric.WithDescription("OTLP requests rejected by tenant resolution or tenant quotas, by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant rejected counter: %w", err)
+	}
+	return r, nil
+}
+
+// admit attributes a request to its tenant and takes one of the tenant's request slots.
+// On success it returns ctx carrying the tenant and a release function that must be called
+// when the request completes; otherwise it returns the rejection reason. Requests left
+// without a tenant are attributed per resource once their payload is decoded.
+func (r *tenantRegistry) admit(ctx context.Context, protocol, header string) (context.Context, func(), string) {
+	if !r.config.Enabled {
+		return ctx, func() {}, ""
+	}
+
+	tenant := tenantFromContext(ctx)
+	header = strings.TrimSpace(header)
+	reason := ""
+	switch {
+	case tenant != "" && header != "" && header != tenant:
+		reason = tenantRejectMismatch
+	case tenant == "" && header != "":
+		tenant = header
+	case tenant == "" && r.config.ResourceAttribute != "":
+		return ctx, func() {}, ""
+	case tenant == "":
+		tenant = r.config.DefaultTenant
+	}
+	if reason == "" {
+		reason = r.checkTenant(tenant)
+	}
+	if reason == "" && !r.acquire(tenant) {
+		reason = tenantRejectQuota
+	}
+	if reason != "" {
+		r.rejected.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("reason", reason),
+			attribute.String("protocol", protocol),
+		))
+		trace.SpanFromContext(ctx).AddEvent("tenant.rejected", trace.WithAttributes(attribute.String("reason", reason)))
+		return ctx, nil, reason
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", tenant))
+	return contextWithTenant(ctx, tenant), func() { r.release(tenant) }, ""
+}
+
+// checkTenant returns the reason a tenant may not send data, or an empty string
+func (r *tenantRegistry) checkTenant(tenant string) string {
+	if tenant == "" {
+		return tenantRejectMissing
+	}
+	if !validTenantID(tenant) {
+		return tenantRejectInvalid
+	}
+	if _, listed := lookupTenant(r.config, tenant); !listed && r.config.RejectUnknown {
+		return tenantRejectUnknown
+	}
+	return ""
+}
+
+// check returns the reason a tenant's batch of a signal is refused, or an empty string
+func (r *tenantRegistry) check(batch tenantBatch, signal string) string {
+	if !r.config.Enabled {
+		return ""
+	}
+	if reason := r.checkTenant(batch.tenant); reason != "" {
+		return reason
+	}
+	tenant, _ := lookupTenant(r.config, batch.tenant)
+	if len(tenant.Signals) > 0 && !slices.Contains(tenant.Signals, signal) {
+		return tenantRejectSignalDisabled
+	}
+	if tenant.Quota.MaxItemsPerRequest > 0 && batch.payload.ItemCount() > tenant.Quota.MaxItemsPerRequest {
+		return tenantRejectQuota
+	}
+	return ""
+}
+
+// admitBatch checks a tenant's batch of a signal and, for a batch attributed per resource,
+// takes one of the tenant's request slots. On success it returns a release function that
+// must be called once the batch is sent; otherwise it returns the rejection reason.
+func (r *tenantRegistry) admitBatch(batch tenantBatch, signal string) (func(), string) {
+	if reason := r.check(batch, signal); reason != "" {
+		return nil, reason
+	}
+	if !batch.perResource {
+		return func() {}, ""
+	}
+	if !r.acquire(batch.tenant) {
+		return nil, tenantRejectQuota
+	}
+	return func() { r.release(batch.tenant) }, ""
+}
+
+// acquire takes one of a tenant's concurrent request slots, reporting false if the tenant's
+// quota is exhausted
+func (r *tenantRegistry) acquire(tenant string) bool {
+	config, _ := lookupTenant(r.config, tenant)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit := config.Quota.MaxConcurrentRequests; limit > 0 && r.inFlight[tenant] >= limit {
+		return false
+	}
+	r.inFlight[tenant]++
+	return true
+}
+
+// release returns a tenant's request slot
+func (r *tenantRegistry) release(tenant string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inFlight[tenant]--; r.inFlight[tenant] <= 0 {
+		delete(r.inFlight, tenant)
+	}
+}
+
+// route splits a payload by tenant. A request attributed to a tenant as a whole forms a
+// single batch; otherwise each resource is attributed by the tenant resource attribute,
+// and resources without a valid one fall back to the default tenant or, if there is none,
+// form a batch without a tenant.
+func (r *tenantRegistry) route(ctx context.Context, payload otlp.Payload) []tenantBatch {
+	tenant := tenantFromContext(ctx)
+	if tenant != "" || !r.config.Enabled || r.config.ResourceAttribute == "" {
+		return []tenantBatch{{tenant: tenant, payload: payload}}
+	}
+
+	keys, parts := splitPayloadByResource(payload, r.resourceTenant)
+	batches := make([]tenantBatch, 0, len(keys))
+	for _, key := range keys {
+		batches = append(batches, tenantBatch{tenant: key, payload: parts[key], perResource: true})
+	}
+	return batches
+}
+
+// resourceTenant returns the tenant named by a resource's tenant attribute, or the default tenant
+func (r *tenantRegistry) resourceTenant(resource otlp.Resource) string {
+	if v, ok := otlp.Attribute(resource.Attributes, r.config.ResourceAttribute); ok {
+		if tenant := v.AsString(); validTenantID(tenant) {
+			return tenant
+		}
+	}
+	return r.config.DefaultTenant
+}
+
+// rejectStatus returns the HTTP status and gRPC code answering a request rejected for reason
+func (r *tenantRegistry) rejectStatus(reason string) (int, grpccodes.Code, string) {
+	switch reason {
+	case tenantRejectMissing:
+		return http.StatusUnauthorized, grpccodes.Unauthenticated, "no tenant ID in request"
+	case tenantRejectInvalid:
+		return http.StatusBadRequest, grpccodes.InvalidArgument, "invalid tenant ID"
+	case tenantRejectUnknown:
+		return http.StatusForbidden, grpccodes.PermissionDenied, "unknown tenant"
+	case tenantRejectMismatch:
+		return http.StatusForbidden, grpccodes.PermissionDenied, "tenant header does not match the authenticated tenant"
+	default:
+		return http.StatusTooManyRequests, grpccodes.ResourceExhausted, "too many concurrent requests for tenant"
+	}
+}
+
+// middleware attributes OTLP/HTTP requests to their tenant, answering 401, 400 or 403 when
+// the tenant is missing, invalid or not allowed and 429 with Retry-After when the tenant's
+// request slots are busy
+func (r *tenantRegistry) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, release, reason := r.admit(req.Context(), "http", req.Header.Get(r.config.Header))
+		if reason != "" {
+			contentType, supported := negotiateOTLPContentType(req.Header.Get("Content-Type"))
+			if !supported {
+				contentType = contentTypeJSON
+			}
+			statusCode, code, message := r.rejectStatus(reason)
+			if code == grpccodes.ResourceExhausted {
+				setRetryAfter(w, r.retryAfter)
+			}
+			writeOTLPHTTPError(w, contentType, statusCode, code, message)
+			return
+		}
+		defer release()
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// unaryInterceptor attributes OTLP/gRPC calls to their tenant from the tenant header
+// metadata, answering with the status matching the rejection reason
+func (r *tenantRegistry) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(r.config.Header); len(values) > 0 {
+			header = values[0]
+		}
+	}
+	ctx, release, reason := r.admit(ctx, "grpc", header)
+	if reason != "" {
+		_, code, message := r.rejectStatus(reason)
+		if code == grpccodes.ResourceExhausted {
+			return nil, retryableStatus(code, message, r.retryAfter)
+		}
+		return nil, status.Error(code, message)
+	}
+	defer release()
+
+	return handler(ctx, req)
+}
+
+// splitPayloadByResource groups the resources of a payload by key, returning the keys in
+// the order they first appear
+func splitPayloadByResource(payload otlp.Payload, key func(otlp.Resource) string) ([]string, map[string]otlp.Payload) {
+	var keys []string
+	parts := make(map[string]otlp.Payload)
+	add := func(k string, newPart func() otlp.Payload) otlp.Payload {
+		part, ok := parts[k]
+		if !ok {
+			part = newPart()
+			parts[k] = part
+			keys = append(keys, k)
+		}
+		return part
+	}
+
+	switch p := payload.(type) {
+	case *otlp.Traces:
+		for _, rs := range p.ResourceSpans {
+			part := add(key(rs.Resource), func() otlp.Payload { return &otlp.Traces{} }).(*otlp.Traces)
+			part.ResourceSpans = append(part.ResourceSpans, rs)
+		}
+	case *otlp.Metrics:
+		for _, rm := range p.ResourceMetrics {
+			part := add(key(rm.Resource), func() otlp.Payload { return &otlp.Metrics{} }).(*otlp.Metrics)
+			part.ResourceMetrics = append(part.ResourceMetrics, rm)
+		}
+	case *otlp.Logs:
+		for _, rl := range p.ResourceLogs {
+			part := add(key(rl.Resource), func() otlp.Payload { return &otlp.Logs{} }).(*otlp.Logs)
+			part.ResourceLogs = append(part.ResourceLogs, rl)
+		}
+	}
+	return keys, parts
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+// newTestTenantRegistry returns a tenant registry reading tenants from the service.name
+// resource attribute, so that testTraces services name the tenants
+func newTestTenantRegistry(t *testing.T, config *Config) *tenantRegistry {
+	t.Helper()
+	config.Tenancy.Enabled = true
+	config.Tenancy.ResourceAttribute = "service.name"
+	tenants, err := newTenantRegistry(config, newTestTelemetryManager(t, config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenants
+}
+
+func TestTenantRoute(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctxTenant     string
+		defaultTenant string
+		services      []string
+		want          []string // tenant and item count of each batch, in order
+		wantPer       bool
+	}{
+		{
+			name:      "request tenant takes every resource",
+			ctxTenant: "team-a",
+			services:  []string{"team-b", "team-c"},
+			want:      []string{"team-a:4"},
+		},
+		{
+			name:     "one batch per resource tenant",
+			services: []string{"team-a", "team-b", "team-a"},
+			want:     []string{"team-a:4", "team-b:2"},
+			wantPer:  true,
+		},
+		{
+			name:          "invalid resource tenant falls back to the default tenant",
+			defaultTenant: "shared",
+			services:      []string{"team-a", "bad tenant"},
+			want:          []string{"team-a:2", "shared:2"},
+			wantPer:       true,
+		},
+		{
+			name:     "invalid resource tenant without a default tenant",
+			services: []string{"bad tenant"},
+			want:     []string{":2"},
+			wantPer:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			setDefaults(config)
+			config.Tenancy.DefaultTenant = tt.defaultTenant
+			tenants := newTestTenantRegistry(t, config)
+
+			ctx := context.Background()
+			if tt.ctxTenant != "" {
+				ctx = contextWithTenant(ctx, tt.ctxTenant)
+			}
+			var got []string
+			for _, batch := range tenants.route(ctx, testTraces(t, tt.services, 2)) {
+				got = append(got, fmt.Sprintf("%s:%d", batch.tenant, batch.payload.ItemCount()))
+				if batch.perResource != tt.wantPer {
+					t.Errorf("batch of %q perResource = %v, want %v", batch.tenant, batch.perResource, tt.wantPer)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("route() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmitBatch(t *testing.T) {
+	tenantConfigs := []TenantConfig{
+		{ID: "team-a", Quota: TenantQuotaConfig{MaxConcurrentRequests: 1, MaxItemsPerRequest: 2}},
+		{ID: "team-logs", Signals: []string{"logs"}},
+	}
+	tests := []struct {
+		name          string
+		rejectUnknown bool
+		tenant        string
+		items         int
+		perResource   bool
+		busy          bool // the tenant's only request slot is already taken
+		wantReason    string
+		wantInFlight  int // slots the tenant holds once the batch is admitted
+	}{
+		{name: "per-resource batch takes a slot", tenant: "team-a", items: 1, perResource: true, wantInFlight: 1},
+		{name: "admitted request keeps its slot", tenant: "team-a", items: 1, busy: true, wantInFlight: 1},
+		{name: "per-resource batch over the concurrency quota", tenant: "team-a", items: 1, perResource: true, busy: true, wantReason: tenantRejectQuota},
+		{name: "over the item quota", tenant: "team-a", items: 3, perResource: true, wantReason: tenantRejectQuota},
+		{name: "signal disabled", tenant: "team-logs", items: 1, perResource: true, wantReason: tenantRejectSignalDisabled},
+		{name: "unknown tenant", rejectUnknown: true, tenant: "team-x", items: 1, perResource: true, wantReason: tenantRejectUnknown},
+		{name: "missing tenant", items: 1, perResource: true, wantReason: tenantRejectMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			setDefaults(config)
+			config.Tenancy.Tenants = tenantConfigs
+			config.Tenancy.RejectUnknown = tt.rejectUnknown
+			tenants := newTestTenantRegistry(t, config)
+			if tt.busy && !tenants.acquire(tt.tenant) {
+				t.Fatal("failed to take the tenant's request slot")
+			}
+
+			batch := tenantBatch{tenant: tt.tenant, payload: testTraces(t, []string{"svc"}, tt.items), perResource: tt.perResource}
+			release, reason := tenants.admitBatch(batch, "traces")
+			if reason != tt.wantReason {
+				t.Fatalf("admitBatch() reason = %q, want %q", reason, tt.wantReason)
+			}
+			if reason != "" {
+				return
+			}
+			if got := tenants.inFlight[tt.tenant]; got != tt.wantInFlight {
+				t.Errorf("%d request slots taken, want %d", got, tt.wantInFlight)
+			}
+			release()
+			if tt.busy {
+				tenants.release(tt.tenant)
+			}
+			if len(tenants.inFlight) != 0 {
+				t.Errorf("request slots still taken after release: %v", tenants.inFlight)
+			}
+		})
+	}
+}
+
+func TestProcessOTLPDataTenantBatches(t *testing.T) {
+	tests := []struct {
+		name         string
+		delivery     []error // outcome of each tenant's message, in order
+		wantErr      bool
+		wantRejected int64
+	}{
+		{name: "every tenant sent", delivery: []error{nil, nil}},
+		{name: "first tenant fails", delivery: []error{sarama.ErrOutOfBrokers}, wantErr: true},
+		{name: "later tenant fails after one was sent", delivery: []error{nil, sarama.ErrOutOfBrokers}, wantRejected: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp, producer := newTestProducer(t, durabilityBrokerAck, 10)
+			config := kp.config
+			tm := kp.telemetryManager
+			tenants := newTestTenantRegistry(t, config)
+			limiter, err := newRateLimiter(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			validator, err := newPayloadValidator(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			redactor, err := newPayloadRedactor(config, tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, err := range tt.delivery {
+				if err == nil {
+					producer.ExpectInputAndSucceed()
+				} else {
+					producer.ExpectInputAndFail(err)
+				}
+			}
+
+			result, err := processOTLPData(context.Background(), tm, kp, tenants, limiter, validator, redactor,
+				newOTLPSignals(config).traces, "http", testTraces(t, []string{"team-a", "team-b"}, 2), 100)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processOTLPData() error = %v, want error %v", err, tt.wantErr)
+			}
+			if result.rejected != tt.wantRejected {
+				t.Errorf("processOTLPData() rejected %d items, want %d", result.rejected, tt.wantRejected)
+			}
+			if err := kp.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Validation modes, configured per signal
+const (
+	validationModeReject   = "reject"
+	validationModeDropItem = "drop_item"
+	validationModeWarn     = "warn"
+	validationModeOff      = "off"
+)
+
+// Reasons reported on the ingestion.validation.invalid metric
+const (
+	validationInvalidTraceID    = "invalid_trace_id"
+	validationInvalidSpanID     = "invalid_span_id"
+	validationInvalidTimestamps = "invalid_timestamps"
+	validationInvalidMetric     = "invalid_metric"
+	validationInvalidDataPoint  = "invalid_data_point"
+	validationInvalidSeverity   = "invalid_severity"
+	validationInvalidAttribute  = "invalid_attribute"
+)
+
+const (
+	// maxValidationMessages caps the problems described in an export response or log entry
+	maxValidationMessages = 10
+	// maxAnyValueDepth bounds the nesting of array and key-value list values
+	maxAnyValueDepth = 32
+)
+
+// validationProblem describes why an item is invalid
+type validationProblem struct {
+	reason string
+	detail string
+}
+
+// newValidationProblem returns a problem with a formatted detail
+func newValidationProblem(reason, format string, args ...interface{}) *validationProblem {
+	return &validationProblem{reason: reason, detail: fmt.Sprintf(format, args...)}
+}
+
+// validationReport collects the problems found in a payload
+type validationReport struct {
+	problems int
+	invalid  int64
+	reasons  map[string]int64
+	messages []string
+}
+
+// add records a problem affecting items items at path
+func (r *validationReport) add(path string, problem *validationProblem, items int64) {
+	if r.reasons == nil {
+		r.reasons = make(map[string]int64)
+	}
+	r.problems++
+	r.invalid += items
+	r.reasons[problem.reason] += items
+	if len(r.messages) < maxValidationMessages {
+		r.messages = append(r.messages, fmt.Sprintf("%s: %s", path, problem.detail))
+	}
+}
+
+// summary describes the problems, listing the first few of them
+func (r *validationReport) summary(signal otlpSignal) string {
+	message := fmt.Sprintf("%d %s failed validation: %s", r.invalid, signal.itemsField, strings.Join(r.messages, "; "))
+	if more := r.problems - len(r.messages); more > 0 {
+		message += fmt.Sprintf(" (and %d more)", more)
+	}
+	return message
+}
+
+// validationError rejects a whole export request that failed validation
+type validationError struct {
+	message string
+}
+
+// Error returns the validation problems
+func (e *validationError) Error() string {
+	return e.message
+}
+
+// payloadValidator checks decoded payloads and applies each signal's validation mode:
+// reject refuses requests with any invalid item, drop_item publishes only the valid items
+// and reports the others as rejected, and warn publishes everything but logs the problems
+type payloadValidator struct {
+	modes   map[string]string
+	tm      *TelemetryManager
+	invalid metric.Int64Counter
+}
+
+// newPayloadValidator creates the validator for the configured modes and registers its metrics
+func newPayloadValidator(config *Config, tm *TelemetryManager) (*payloadValidator, error) {
+	v := &payloadValidator{
+		modes: map[string]string{
+			string(otlp.SignalTraces):  config.Validation.Traces,
+			string(otlp.SignalMetrics): config.Validation.Metrics,
+			string(otlp.SignalLogs):    config.Validation.Logs,
+		},
+		tm: tm,
+	}
+
+	var err error
+	v.invalid, err = tm.GetMeter().Int64Counter("ingestion.validation.invalid",
+		metric.WithDescription("Received items that failed validation, by signal, reason and mode"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create validation counter: %w", err)
+	}
+	return v, nil
+}
+
+// validate applies the signal's validation mode to a payload. It returns the payload to
+// publish, or nil if nothing is left to publish, with the dropped items as an export result.
+// In reject mode an invalid payload is returned as a validationError.
+func (v *payloadValidator) validate(ctx context.Context, signal otlpSignal, payload otlp.Payload) (otlp.Payload, exportResult, error) {
+	mode := v.modes[signal.name]
+	if mode == validationModeOff {
+		return payload, exportResult{}, nil
+	}
+
+	var report validationReport
+	var valid otlp.Payload
+	switch p := payload.(type) {
+	case *otlp.Traces:
+		valid = validateTraces(p, &report)
+	case *otlp.Metrics:
+		valid = validateMetrics(p, &report)
+	case *otlp.Logs:
+		valid = validateLogs(p, &report)
+	default:
+		valid = payload
+	}
+
+	if report.problems > 0 {
+		for reason, items := range report.reasons {
+			v.invalid.Add(ctx, items, metric.WithAttributes(
+				attribute.String("signal", signal.name),
+				attribute.String("reason", reason),
+				attribute.String("mode", mode),
+			))
+		}
+		v.tm.LogWithTraceContext(ctx, zap.WarnLevel, fmt.Sprintf("Received invalid %s", signal.name),
+			zap.String("mode", mode),
+			zap.Int64(signal.itemsField, report.invalid),
+			zap.Strings("problems", report.messages),
+		)
+	}
+
+	switch {
+	case mode == validationModeWarn:
+		// Publish the request exactly as received, as without validation
+		return payload, exportResult{}, nil
+	case report.problems == 0:
+	case mode == validationModeReject:
+		v.tm.metrics.recordRejected(ctx, signal.name, rejectReasonInvalid, payload.ItemCount())
+		return nil, exportResult{}, &validationError{message: report.summary(signal)}
+	default:
+		v.tm.metrics.recordRejected(ctx, signal.name, rejectReasonInvalid, report.invalid)
+	}
+
+	var result exportResult
+	if mode == validationModeDropItem && report.problems > 0 {
+		result = exportResult{rejected: report.invalid, errorMessage: report.summary(signal)}
+	}
+	if valid.ItemCount() == 0 {
+		return nil, result, nil
+	}
+	return valid, result, nil
+}
+
+// validateTraces returns the valid spans of a trace payload, reporting the others
+func validateTraces(traces *otlp.Traces, report *validationReport) *otlp.Traces {
+	out := &otlp.Traces{}
+	for i, rs := range traces.ResourceSpans {
+		path := fmt.Sprintf("resourceSpans[%d]", i)
+		resourceProblem := validateAttributes(rs.Resource.Attributes, "resource")
+		kept := otlp.ResourceSpans{Resource: rs.Resource, SchemaURL: rs.SchemaURL}
+		for j, ss := range rs.ScopeSpans {
+			scopePath := fmt.Sprintf("%s.scopeSpans[%d]", path, j)
+			problem := resourceProblem
+			if problem == nil {
+				problem = validateAttributes(ss.Scope.Attributes, "scope")
+			}
+			if problem != nil {
+				report.add(scopePath, problem, int64(len(ss.Spans)))
+				continue
+			}
+			ss.Spans = filterValid(ss.Spans, scopePath+".spans", report, validateSpan)
+			if len(ss.Spans) > 0 {
+				kept.ScopeSpans = append(kept.ScopeSpans, ss)
+			}
+		}
+		if len(kept.ScopeSpans) > 0 {
+			out.ResourceSpans = append(out.ResourceSpans, kept)
+		}
+	}
+	return out
+}
+
+// validateSpan checks the IDs, timestamps and attributes of a span
+func validateSpan(span otlp.Span) *validationProblem {
+	if problem := validateID(span.TraceID, 16, "trace ID", validationInvalidTraceID); problem != nil {
+		return problem
+	}
+	if problem := validateID(span.SpanID, 8, "span ID", validationInvalidSpanID); problem != nil {
+		return problem
+	}
+	if span.ParentSpanID != "" {
+		if problem := validateID(span.ParentSpanID, 8, "parent span ID", validationInvalidSpanID); problem != nil {
+			return problem
+		}
+	}
+	if span.StartTimeUnixNano > span.EndTimeUnixNano {
+		return newValidationProblem(validationInvalidTimestamps, "start time %d is after end time %d", span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+	if problem := validateAttributes(span.Attributes, "attributes"); problem != nil {
+		return problem
+	}
+	for i, event := range span.Events {
+		if problem := validateAttributes(event.Attributes, fmt.Sprintf("events[%d].attributes", i)); problem != nil {
+			return problem
+		}
+	}
+	for i, link := range span.Links {
+		if problem := validateID(link.TraceID, 16, fmt.Sprintf("links[%d] trace ID", i), validationInvalidTraceID); problem != nil {
+			return problem
+		}
+		if problem := validateID(link.SpanID, 8, fmt.Sprintf("links[%d] span ID", i), validationInvalidSpanID); problem != nil {
+			return problem
+		}
+		if problem := validateAttributes(link.Attributes, fmt.Sprintf("links[%d].attributes", i)); problem != nil {
+			return problem
+		}
+	}
+	return nil
+}
+
+// validateMetrics returns the valid data points of a metrics payload, reporting the others
+func validateMetrics(metrics *otlp.Metrics, report *validationReport) *otlp.Metrics {
+	out := &otlp.Metrics{}
+	for i, rm := range metrics.ResourceMetrics {
+		path := fmt.Sprintf("resourceMetrics[%d]", i)
+		resourceProblem := validateAttributes(rm.Resource.Attributes, "resource")
+		kept := otlp.ResourceMetrics{Resource: rm.Resource, SchemaURL: rm.SchemaURL}
+		for j, sm := range rm.ScopeMetrics {
+			scopePath := fmt.Sprintf("%s.scopeMetrics[%d]", path, j)
+			problem := resourceProblem
+			if problem == nil {
+				problem = validateAttributes(sm.Scope.Attributes, "scope")
+			}
+			keptScope := otlp.ScopeMetrics{Scope: sm.Scope, SchemaURL: sm.SchemaURL}
+			for k, m := range sm.Metrics {
+				metricPath := fmt.Sprintf("%s.metrics[%d]", scopePath, k)
+				metricProblem := problem
+				if metricProblem == nil {
+					metricProblem = validateMetric(m)
+				}
+				if metricProblem != nil {
+					report.add(metricPath, metricProblem, int64(m.DataPointCount()))
+					continue
+				}
+				if m = filterDataPoints(m, metricPath, report); m.DataPointCount() > 0 {
+					keptScope.Metrics = append(keptScope.Metrics, m)
+				}
+			}
+			if len(keptScope.Metrics) > 0 {
+				kept.ScopeMetrics = append(kept.ScopeMetrics, keptScope)
+			}
+		}
+		if len(kept.ScopeMetrics) > 0 {
+			out.ResourceMetrics = append(out.ResourceMetrics, kept)
+		}
+	}
+	return out
+}
+
+// validateMetric checks the name, data type and temporality of a metric
+func validateMetric(m otlp.Metric) *validationProblem {
+	if m.Name == "" {
+		return newValidationProblem(validationInvalidMetric, "metric has no name")
+	}
+	types := 0
+	for _, set := range []bool{m.Gauge != nil, m.Sum != nil, m.Histogram != nil, m.ExponentialHistogram != nil, m.Summary != nil} {
+		if set {
+			types++
+		}
+	}
+	if types != 1 {
+		return newValidationProblem(validationInvalidMetric, "metric %q has %d data types, expected exactly one", m.Name, types)
+	}
+
+	temporality := otlp.AggregationTemporalityDelta
+	switch {
+	case m.Sum != nil:
+		temporality = m.Sum.AggregationTemporality
+	case m.Histogram != nil:
+		temporality = m.Histogram.AggregationTemporality
+	case m.ExponentialHistogram != nil:
+		temporality = m.ExponentialHistogram.AggregationTemporality
+	}
+	if temporality != otlp.AggregationTemporalityDelta && temporality != otlp.AggregationTemporalityCumulative {
+		return newValidationProblem(validationInvalidMetric, "metric %q has invalid aggregation temporality %d", m.Name, temporality)
+	}
+	return nil
+}
+
+// filterDataPoints returns a metric with only its valid data points, reporting the others
+func filterDataPoints(m otlp.Metric, path string, report *validationReport) otlp.Metric {
+	switch {
+	case m.Gauge != nil:
+		gauge := *m.Gauge
+		gauge.DataPoints = filterValid(gauge.DataPoints, path+".gauge.dataPoints", report, validateNumberDataPoint)
+		m.Gauge = &gauge
+	case m.Sum != nil:
+		sum := *m.Sum
+		sum.DataPoints = filterValid(sum.DataPoints, path+".sum.dataPoints", report, validateNumberDataPoint)
+		m.Sum = &sum
+	case m.Histogram != nil:
+		histogram := *m.Histogram
+		histogram.DataPoints = filterValid(histogram.DataPoints, path+".histogram.dataPoints", report, validateHistogramDataPoint)
+		m.Histogram = &histogram
+	case m.ExponentialHistogram != nil:
+		histogram := *m.ExponentialHistogram
+		histogram.DataPoints = filterValid(histogram.DataPoints, path+".exponentialHistogram.dataPoints", report, validateExponentialHistogramDataPoint)
+		m.ExponentialHistogram = &histogram
+	case m.Summary != nil:
+		summary := *m.Summary
+		summary.DataPoints = filterValid(summary.DataPoints, path+".summary.dataPoints", report, validateSummaryDataPoint)
+		m.Summary = &summary
+	}
+	return m
+}
+
+// validateNumberDataPoint checks that a gauge or sum data point has exactly one value
+func validateNumberDataPoint(dp otlp.NumberDataPoint) *validationProblem {
+	if (dp.AsInt == nil) == (dp.AsDouble == nil) {
+		return newValidationProblem(validationInvalidDataPoint, "data point must have exactly one of asInt and asDouble")
+	}
+	return validateDataPoint(dp.StartTimeUnixNano, dp.TimeUnixNano, dp.Attributes)
+}
+
+// validateHistogramDataPoint checks the buckets, bounds and count of a histogram data point
+func validateHistogramDataPoint(dp otlp.HistogramDataPoint) *validationProblem {
+	if len(dp.BucketCounts) > 0 {
+		if len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+			return newValidationProblem(validationInvalidDataPoint, "histogram has %d bucket counts for %d explicit bounds, expected %d",
+				len(dp.BucketCounts), len(dp.ExplicitBounds), len(dp.ExplicitBounds)+1)
+		}
+		if total := sumCounts(dp.BucketCounts); total != uint64(dp.Count) {
+			return newValidationProblem(validationInvalidDataPoint, "histogram bucket counts add up to %d, but count is %d", total, dp.Count)
+		}
+	}
+	for i, bound := range dp.ExplicitBounds {
+		if math.IsNaN(bound) || (i > 0 && bound <= dp.ExplicitBounds[i-1]) {
+			return newValidationProblem(validationInvalidDataPoint, "histogram explicit bounds must be strictly increasing")
+		}
+	}
+	if dp.Min != nil && dp.Max != nil && *dp.Min > *dp.Max {
+		return newValidationProblem(validationInvalidDataPoint, "histogram min %g is greater than max %g", *dp.Min, *dp.Max)
+	}
+	return validateDataPoint(dp.StartTimeUnixNano, dp.TimeUnixNano, dp.Attributes)
+}
+
+// validateExponentialHistogramDataPoint checks the scale and count of an exponential histogram data point
+func validateExponentialHistogramDataPoint(dp otlp.ExponentialHistogramDataPoint) *validationProblem {
+	if dp.Scale < -10 || dp.Scale > 20 {
+		return newValidationProblem(validationInvalidDataPoint, "exponential histogram scale %d is outside [-10, 20]", dp.Scale)
+	}
+	total := uint64(dp.ZeroCount) + sumCounts(dp.Positive.BucketCounts) + sumCounts(dp.Negative.BucketCounts)
+	if total != uint64(dp.Count) {
+		return newValidationProblem(validationInvalidDataPoint, "exponential histogram bucket counts add up to %d, but count is %d", total, dp.Count)
+	}
+	if dp.Min != nil && dp.Max != nil && *dp.Min > *dp.Max {
+		return newValidationProblem(validationInvalidDataPoint, "exponential histogram min %g is greater than max %g", *dp.Min, *dp.Max)
+	}
+	return validateDataPoint(dp.StartTimeUnixNano, dp.TimeUnixNano, dp.Attributes)
+}
+
+// validateSummaryDataPoint checks the quantiles of a summary data point
+func validateSummaryDataPoint(dp otlp.SummaryDataPoint) *validationProblem {
+	for _, q := range dp.QuantileValues {
+		if !(q.Quantile >= 0 && q.Quantile <= 1) {
+			return newValidationProblem(validationInvalidDataPoint, "summary quantile %g is outside [0, 1]", q.Quantile)
+		}
+	}
+	return validateDataPoint(dp.StartTimeUnixNano, dp.TimeUnixNano, dp.Attributes)
+}
+
+// validateDataPoint checks the timestamps and attributes shared by every data point type
+func validateDataPoint(start, end otlp.Uint64, attrs []otlp.KeyValue) *validationProblem {
+	if start != 0 && start > end {
+		return newValidationProblem(validationInvalidTimestamps, "start time %d is after time %d", start, end)
+	}
+	return validateAttributes(attrs, "attributes")
+}
+
+// validateLogs returns the valid log records of a logs payload, reporting the others
+func validateLogs(logs *otlp.Logs, report *validationReport) *otlp.Logs {
+	out := &otlp.Logs{}
+	for i, rl := range logs.ResourceLogs {
+		path := fmt.Sprintf("resourceLogs[%d]", i)
+		resourceProblem := validateAttributes(rl.Resource.Attributes, "resource")
+		kept := otlp.ResourceLogs{Resource: rl.Resource, SchemaURL: rl.SchemaURL}
+		for j, sl := range rl.ScopeLogs {
+			scopePath := fmt.Sprintf("%s.scopeLogs[%d]", path, j)
+			problem := resourceProblem
+			if problem == nil {
+				problem = validateAttributes(sl.Scope.Attributes, "scope")
+			}
+			if problem != nil {
+				report.add(scopePath, problem, int64(len(sl.LogRecords)))
+				continue
+			}
+			sl.LogRecords = filterValid(sl.LogRecords, scopePath+".logRecords", report, validateLogRecord)
+			if len(sl.LogRecords) > 0 {
+				kept.ScopeLogs = append(kept.ScopeLogs, sl)
+			}
+		}
+		if len(kept.ScopeLogs) > 0 {
+			out.ResourceLogs = append(out.ResourceLogs, kept)
+		}
+	}
+	return out
+}
+
+// validateLogRecord checks the severity, trace context, attributes and body of a log record
+func validateLogRecord(record otlp.LogRecord) *validationProblem {
+	if record.SeverityNumber < 0 || record.SeverityNumber > 24 {
+		return newValidationProblem(validationInvalidSeverity, "severity number %d is outside [0, 24]", record.SeverityNumber)
+	}
+	if record.TraceID != "" {
+		if problem := validateID(record.TraceID, 16, "trace ID", validationInvalidTraceID); problem != nil {
+			return problem
+		}
+	}
+	if record.SpanID != "" {
+		if problem := validateID(record.SpanID, 8, "span ID", validationInvalidSpanID); problem != nil {
+			return problem
+		}
+	}
+	if problem := validateAttributes(record.Attributes, "attributes"); problem != nil {
+		return problem
+	}
+	if record.Body != nil {
+		if detail := validateAnyValue(*record.Body, 0); detail != "" {
+			return newValidationProblem(validationInvalidAttribute, "body %s", detail)
+		}
+	}
+	return nil
+}
+
+// validateID checks that id is the hex encoding of a non-zero ID of size bytes
+func validateID(id string, size int, name, reason string) *validationProblem {
+	decoded, err := hex.DecodeString(id)
+	if err != nil || len(decoded) != size {
+		return newValidationProblem(reason, "%s %q is not %d hex characters", name, id, 2*size)
+	}
+	for _, b := range decoded {
+		if b != 0 {
+			return nil
+		}
+	}
+	return newValidationProblem(reason, "%s is all zeros", name)
+}
+
+// validateAttributes checks that attribute keys are set and values have at most one type
+func validateAttributes(attrs []otlp.KeyValue, field string) *validationProblem {
+	for _, kv := range attrs {
+		if kv.Key == "" {
+			return newValidationProblem(validationInvalidAttribute, "%s has an attribute with an empty key", field)
+		}
+		if detail := validateAnyValue(kv.Value, 0); detail != "" {
+			return newValidationProblem(validationInvalidAttribute, "%s %q %s", field, kv.Key, detail)
+		}
+	}
+	return nil
+}
+
+// validateAnyValue describes what is wrong with a value, or returns an empty string
+func validateAnyValue(v otlp.AnyValue, depth int) string {
+	if depth > maxAnyValueDepth {
+		return fmt.Sprintf("is nested more than %d levels deep", maxAnyValueDepth)
+	}
+	types := 0
+	for _, set := range []bool{v.StringValue != nil, v.BoolValue != nil, v.IntValue != nil, v.DoubleValue != nil, v.ArrayValue != nil, v.KvlistValue != nil, v.BytesValue != nil} {
+		if set {
+			types++
+		}
+	}
+	if types > 1 {
+		return fmt.Sprintf("has %d value types, expected at most one", types)
+	}
+	if v.ArrayValue != nil {
+		for _, item := range v.ArrayValue.Values {
+			if detail := validateAnyValue(item, depth+1); detail != "" {
+				return detail
+			}
+		}
+	}
+	if v.KvlistValue != nil {
+		for _, kv := range v.KvlistValue.Values {
+			if kv.Key == "" {
+				return "has a nested attribute with an empty key"
+			}
+			if detail := validateAnyValue(kv.Value, depth+1); detail != "" {
+				return detail
+			}
+		}
+	}
+	return ""
+}
+
+// filterValid returns the values check accepts, reporting the others by index under path
+func filterValid[T any](values []T, path string, report *validationReport, check func(T) *validationProblem) []T {
+	var kept []T
+	for i, value := range values {
+		if problem := check(value); problem != nil {
+			report.add(fmt.Sprintf("%s[%d]", path, i), problem, 1)
+			continue
+		}
+		kept = append(kept, value)
+	}
+	return kept
+}
+
+// sumCounts adds up histogram bucket counts
+func sumCounts(counts []otlp.Uint64) uint64 {
+	var total uint64
+	for _, c := range counts {
+		total += uint64(c)
+	}
+	return total
+}
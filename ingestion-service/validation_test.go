@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"telemorph-prime/ingestion-service/internal/otlp"
+)
+
+// Valid trace and span IDs for test payloads
+const (
+	testTraceID = `"traceId":"0102030405060708090a0b0c0d0e0f10"`
+	testSpanID  = `"spanId":"0102030405060708"`
+)
+
+// decodeTestPayload decodes an OTLP/JSON request of a signal
+func decodeTestPayload(t *testing.T, signal otlp.Signal, body string) otlp.Payload {
+	t.Helper()
+	payload, err := otlp.Unmarshal(signal, otlp.EncodingJSON, []byte(body))
+	if err != nil {
+		t.Fatalf("failed to decode test payload: %v", err)
+	}
+	return payload
+}
+
+// checkReport compares the items kept and the invalid items reported by reason
+func checkReport(t *testing.T, kept otlp.Payload, report validationReport, wantKept int64, wantReason string, wantInvalid int64) {
+	t.Helper()
+	if got := kept.ItemCount(); got != wantKept {
+		t.Errorf("kept %d items, want %d", got, wantKept)
+	}
+	if report.invalid != wantInvalid {
+		t.Errorf("reported %d invalid items, want %d (%v)", report.invalid, wantInvalid, report.messages)
+	}
+	if wantReason != "" && report.reasons[wantReason] != wantInvalid {
+		t.Errorf("reasons = %v, want %d %s", report.reasons, wantInvalid, wantReason)
+	}
+}
+
+func TestValidateTraces(t *testing.T) {
+	tests := []struct {
+		name        string
+		resource    string
+		scope       string
+		span        string
+		wantReason  string
+		wantInvalid int64
+	}{
+		{name: "valid", span: testTraceID + `,` + testSpanID + `,"startTimeUnixNano":"1","endTimeUnixNano":"2"`},
+		{name: "missing trace ID", span: testSpanID, wantReason: validationInvalidTraceID, wantInvalid: 1},
+		{name: "short trace ID", span: `"traceId":"0102",` + testSpanID, wantReason: validationInvalidTraceID, wantInvalid: 1},
+		{name: "all-zero trace ID", span: `"traceId":"00000000000000000000000000000000",` + testSpanID, wantReason: validationInvalidTraceID, wantInvalid: 1},
+		{name: "all-zero span ID", span: testTraceID + `,"spanId":"0000000000000000"`, wantReason: validationInvalidSpanID, wantInvalid: 1},
+		{name: "ends before it starts", span: testTraceID + `,` + testSpanID + `,"startTimeUnixNano":"2","endTimeUnixNano":"1"`, wantReason: validationInvalidTimestamps, wantInvalid: 1},
+		{name: "empty attribute key", span: testTraceID + `,` + testSpanID + `,"attributes":[{"key":"","value":{"stringValue":"x"}}]`, wantReason: validationInvalidAttribute, wantInvalid: 1},
+		{name: "attribute with two value types", span: testTraceID + `,` + testSpanID + `,"attributes":[{"key":"k","value":{"stringValue":"x","intValue":"1"}}]`, wantReason: validationInvalidAttribute, wantInvalid: 1},
+		{name: "invalid event attribute", span: testTraceID + `,` + testSpanID + `,"events":[{"name":"e","attributes":[{"key":""}]}]`, wantReason: validationInvalidAttribute, wantInvalid: 1},
+		{name: "invalid resource attribute", resource: `{"key":"","value":{"stringValue":"x"}}`, span: testTraceID + `,` + testSpanID, wantReason: validationInvalidAttribute, wantInvalid: 2},
+		{name: "invalid scope attribute", scope: `{"key":"","value":{"stringValue":"x"}}`, span: testTraceID + `,` + testSpanID, wantReason: validationInvalidAttribute, wantInvalid: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The tested span is followed by a valid one in the same scope
+			body := `{"resourceSpans":[{"resource":{"attributes":[` + tt.resource + `]},"scopeSpans":[{"scope":{"attributes":[` + tt.scope + `]},"spans":[{` +
+				tt.span + `},{` + testTraceID + `,` + testSpanID + `}]}]}]}`
+			var report validationReport
+			kept := validateTraces(decodeTestPayload(t, otlp.SignalTraces, body).(*otlp.Traces), &report)
+			checkReport(t, kept, report, 2-tt.wantInvalid, tt.wantReason, tt.wantInvalid)
+		})
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		metric      string
+		wantReason  string
+		wantInvalid int64
+	}{
+		{name: "valid gauge", metric: `"name":"m","gauge":{"dataPoints":[{"asInt":"1"},{"asDouble":2}]}`},
+		{name: "valid histogram", metric: `"name":"m","histogram":{"aggregationTemporality":2,"dataPoints":[{"count":"3","bucketCounts":["1","2"],"explicitBounds":[1]},{"count":"0"}]}`},
+		{name: "missing name", metric: `"gauge":{"dataPoints":[{"asInt":"1"},{"asInt":"2"}]}`, wantReason: validationInvalidMetric, wantInvalid: 2},
+		{name: "no data type", metric: `"name":"m"`, wantReason: validationInvalidMetric},
+		{name: "unspecified temporality", metric: `"name":"m","sum":{"dataPoints":[{"asInt":"1"},{"asInt":"2"}]}`, wantReason: validationInvalidMetric, wantInvalid: 2},
+		{name: "number without a value", metric: `"name":"m","gauge":{"dataPoints":[{},{"asInt":"1"}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "number with two values", metric: `"name":"m","gauge":{"dataPoints":[{"asInt":"1","asDouble":1},{"asInt":"1"}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "starts after its time", metric: `"name":"m","gauge":{"dataPoints":[{"asInt":"1","startTimeUnixNano":"5","timeUnixNano":"1"},{"asInt":"1"}]}`, wantReason: validationInvalidTimestamps, wantInvalid: 1},
+		{name: "bucket counts do not match bounds", metric: `"name":"m","histogram":{"aggregationTemporality":1,"dataPoints":[{"count":"1","bucketCounts":["1"],"explicitBounds":[1]},{"count":"0"}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "bucket counts do not add up", metric: `"name":"m","histogram":{"aggregationTemporality":1,"dataPoints":[{"count":"5","bucketCounts":["1","1"],"explicitBounds":[1]},{"count":"0"}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "bounds not increasing", metric: `"name":"m","histogram":{"aggregationTemporality":1,"dataPoints":[{"explicitBounds":[2,1]},{"count":"0"}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "exponential histogram scale", metric: `"name":"m","exponentialHistogram":{"aggregationTemporality":1,"dataPoints":[{"scale":21},{"scale":0}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "summary quantile", metric: `"name":"m","summary":{"dataPoints":[{"quantileValues":[{"quantile":1.5}]},{"quantileValues":[{"quantile":0.5}]}]}`, wantReason: validationInvalidDataPoint, wantInvalid: 1},
+		{name: "data point attribute", metric: `"name":"m","gauge":{"dataPoints":[{"asInt":"1","attributes":[{"key":""}]},{"asInt":"1"}]}`, wantReason: validationInvalidAttribute, wantInvalid: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{` + tt.metric + `}]}]}]}`
+			payload := decodeTestPayload(t, otlp.SignalMetrics, body)
+			total := payload.ItemCount()
+			var report validationReport
+			kept := validateMetrics(payload.(*otlp.Metrics), &report)
+			if tt.wantInvalid == 0 && tt.wantReason != "" {
+				// A metric without data points has no items to report, but is still dropped
+				if report.problems == 0 || len(kept.ResourceMetrics) != 0 {
+					t.Errorf("metric was not reported invalid: %v", report.messages)
+				}
+				return
+			}
+			checkReport(t, kept, report, total-tt.wantInvalid, tt.wantReason, tt.wantInvalid)
+		})
+	}
+}
+
+func TestValidateLogs(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      string
+		wantReason  string
+		wantInvalid int64
+	}{
+		{name: "valid", record: `"severityNumber":9,"body":{"stringValue":"hello"}`},
+		{name: "valid trace context", record: testTraceID + `,` + testSpanID},
+		{name: "severity out of range", record: `"severityNumber":25`, wantReason: validationInvalidSeverity, wantInvalid: 1},
+		{name: "invalid trace ID", record: `"traceId":"zz"`, wantReason: validationInvalidTraceID, wantInvalid: 1},
+		{name: "invalid span ID", record: `"spanId":"01"`, wantReason: validationInvalidSpanID, wantInvalid: 1},
+		{name: "body with two value types", record: `"body":{"stringValue":"a","boolValue":true}`, wantReason: validationInvalidAttribute, wantInvalid: 1},
+		{name: "nested empty key in body", record: `"body":{"kvlistValue":{"values":[{"key":"","value":{"stringValue":"a"}}]}}`, wantReason: validationInvalidAttribute, wantInvalid: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{` + tt.record + `},{"severityNumber":1}]}]}]}`
+			var report validationReport
+			kept := validateLogs(decodeTestPayload(t, otlp.SignalLogs, body).(*otlp.Logs), &report)
+			checkReport(t, kept, report, 2-tt.wantInvalid, tt.wantReason, tt.wantInvalid)
+		})
+	}
+}
+
+func TestValidateAnyValueDepth(t *testing.T) {
+	value := otlp.StringAnyValue("leaf")
+	for i := 0; i <= maxAnyValueDepth+1; i++ {
+		value = otlp.AnyValue{ArrayValue: &otlp.ArrayValue{Values: []otlp.AnyValue{value}}}
+	}
+	if detail := validateAnyValue(value, 0); !strings.Contains(detail, "nested") {
+		t.Errorf("validateAnyValue() = %q, want a nesting error", detail)
+	}
+}
+
+func TestValidationModes(t *testing.T) {
+	const invalidSpan = `{"spanId":"0102030405060708"}`
+	const validSpan = `{` + testTraceID + `,` + testSpanID + `}`
+	body := func(spans ...string) string {
+		return `{"resourceSpans":[{"scopeSpans":[{"spans":[` + strings.Join(spans, ",") + `]}]}]}`
+	}
+
+	tests := []struct {
+		name         string
+		mode         string
+		body         string
+		wantItems    int64 // -1 when nothing is published
+		wantRejected int64
+		wantErr      bool
+	}{
+		{name: "reject valid", mode: validationModeReject, body: body(validSpan), wantItems: 1},
+		{name: "reject invalid", mode: validationModeReject, body: body(validSpan, invalidSpan), wantErr: true},
+		{name: "drop_item keeps the valid items", mode: validationModeDropItem, body: body(validSpan, invalidSpan), wantItems: 1, wantRejected: 1},
+		{name: "drop_item with nothing valid", mode: validationModeDropItem, body: body(invalidSpan), wantItems: -1, wantRejected: 1},
+		{name: "drop_item empty request", mode: validationModeDropItem, body: `{}`, wantItems: -1},
+		{name: "warn publishes everything", mode: validationModeWarn, body: body(validSpan, invalidSpan), wantItems: 2},
+		{name: "warn publishes empty requests", mode: validationModeWarn, body: `{}`, wantItems: 0},
+		{name: "off publishes everything", mode: validationModeOff, body: body(invalidSpan), wantItems: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Validation: ValidationConfig{Traces: tt.mode}}
+			setDefaults(config)
+			validator, err := newPayloadValidator(config, newTestTelemetryManager(t, config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			signal := newOTLPSignals(config).traces
+
+			payload, result, err := validator.validate(context.Background(), signal, decodeTestPayload(t, otlp.SignalTraces, tt.body))
+			if tt.wantErr {
+				var invalid *validationError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("validate() error = %v, want a validationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			switch {
+			case tt.wantItems < 0 && payload != nil:
+				t.Errorf("validate() published %d items, want nothing", payload.ItemCount())
+			case tt.wantItems >= 0 && (payload == nil || payload.ItemCount() != tt.wantItems):
+				t.Errorf("validate() payload = %v, want %d items", payload, tt.wantItems)
+			}
+			if result.rejected != tt.wantRejected {
+				t.Errorf("validate() rejected %d items, want %d", result.rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestValidationDefaultsToWarn(t *testing.T) {
+	config := &Config{}
+	setDefaults(config)
+	for name, mode := range map[string]string{"traces": config.Validation.Traces, "metrics": config.Validation.Metrics, "logs": config.Validation.Logs} {
+		if mode != validationModeWarn {
+			t.Errorf("default %s validation mode = %q, want %q", name, mode, validationModeWarn)
+		}
+	}
+}